@@ -0,0 +1,10 @@
+module ceesarcode/go-modules
+
+go 1.21
+
+require (
+	github.com/emirpasic/gods v1.18.1
+	github.com/google/btree v1.1.3
+	golang.org/x/exp v0.0.0-20240904232852-e7e105dedf7e
+	gonum.org/v1/gonum v0.12.0
+)
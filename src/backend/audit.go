@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Every mutation of a problem or its test cases is appended to an audit log
+// at data/problems/.audit/audit.jsonl, one AuditEntry per line. The file is
+// only ever appended to; GET /api/audit and GET /api/problems/{id}/history
+// read it back.
+
+const (
+	auditDirName       = ".audit"
+	auditFileName      = "audit.jsonl"
+	maxAuditValueBytes = 64 << 10 // larger before/after values are replaced by a digest
+	defaultAuditLimit  = 100
+	maxAuditLimit      = 1000
+)
+
+// AuditEntry is one recorded mutation
+type AuditEntry struct {
+	Seq        int64                  `json:"seq"`
+	Time       time.Time              `json:"time"`
+	Actor      string                 `json:"actor"`
+	RemoteAddr string                 `json:"remoteAddr,omitempty"`
+	Action     string                 `json:"action"` // e.g. "problem.delete", "testcases.update"
+	ProblemID  string                 `json:"problemId,omitempty"`
+	Version    int                    `json:"version,omitempty"` // revision of the problem after this change
+	Changes    []AuditChange          `json:"changes,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// AuditChange is one changed value; Path is a dotted path into the
+// manifest or test set ("" when the whole object was created or removed)
+type AuditChange struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// auditLog appends entries to the log file of the current dataDir
+type auditLog struct {
+	mu       sync.Mutex
+	path     string         // file the counters below were loaded from
+	seq      int64          // last sequence number
+	versions map[string]int // latest revision per problem
+}
+
+var audit auditLog
+
+func auditLogPath() string {
+	return filepath.Join(dataDir, auditDirName, auditFileName)
+}
+
+// load reads the counters from an existing log; called with mu held
+func (a *auditLog) load(path string) {
+	a.path = path
+	a.seq = 0
+	a.versions = make(map[string]int)
+	_ = scanAuditLog(path, func(e AuditEntry) bool {
+		a.seq = e.Seq
+		if e.ProblemID != "" {
+			a.versions[e.ProblemID] = e.Version
+		}
+		return true
+	})
+}
+
+// append assigns the sequence number and version and writes the entry
+func (a *auditLog) append(e AuditEntry) (AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path := auditLogPath()
+	if a.path != path {
+		a.load(path)
+	}
+
+	e.Seq = a.seq + 1
+	if e.ProblemID != "" {
+		e.Version = a.versions[e.ProblemID] + 1
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return e, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return e, err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return e, err
+	}
+
+	a.seq = e.Seq
+	if e.ProblemID != "" {
+		a.versions[e.ProblemID] = e.Version
+	}
+	return e, nil
+}
+
+// recordAudit logs a mutation made by the request's actor. before and after
+// are diffed field by field; either may be nil. Failures are logged but never
+// fail the request.
+func recordAudit(r *http.Request, action, problemID string, before, after interface{}, details map[string]interface{}) {
+	e := AuditEntry{
+		Time:      time.Now().UTC(),
+		Actor:     auditActor(r),
+		Action:    action,
+		ProblemID: problemID,
+		Changes:   diffAuditValues(before, after),
+		Details:   details,
+	}
+	if r != nil {
+		e.RemoteAddr = r.RemoteAddr
+	}
+	if _, err := audit.append(e); err != nil {
+		log.Printf("Failed to write audit entry %s %s: %v", action, problemID, err)
+	}
+}
+
+// auditActor identifies who made a request. There are no user accounts, so
+// clients identify themselves with the X-Actor header.
+func auditActor(r *http.Request) string {
+	if r == nil {
+		return "system"
+	}
+	for _, h := range []string{"X-Actor", "X-User"} {
+		if v := strings.TrimSpace(r.Header.Get(h)); v != "" {
+			return v
+		}
+	}
+	return "anonymous"
+}
+
+// diffAuditValues compares two values through their JSON form, descending
+// into objects so that only the changed fields are recorded
+func diffAuditValues(before, after interface{}) []AuditChange {
+	b, a := toJSONValue(before), toJSONValue(after)
+	var changes []AuditChange
+	diffJSONValues("", b, a, &changes)
+	return changes
+}
+
+func toJSONValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<unserializable: %v>", err)
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func diffJSONValues(path string, before, after interface{}, out *[]AuditChange) {
+	bm, bok := before.(map[string]interface{})
+	am, aok := after.(map[string]interface{})
+	if bok && aok {
+		keys := make(map[string]bool)
+		for k := range bm {
+			keys[k] = true
+		}
+		for k := range am {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			diffJSONValues(p, bm[k], am[k], out)
+		}
+		return
+	}
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	*out = append(*out, AuditChange{Path: path, Before: capAuditValue(before), After: capAuditValue(after)})
+}
+
+// capAuditValue replaces values too large to keep in the log (e.g. drawings)
+// with their size and digest
+func capAuditValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	data, _ := json.Marshal(v)
+	if len(data) <= maxAuditValueBytes {
+		return v
+	}
+	return fmt.Sprintf("<%d bytes, sha256 %x>", len(data), sha256.Sum256(data))
+}
+
+// snapshotTestCases returns the public test cases of a problem by name
+func snapshotTestCases(problemID string) map[string]TestCase {
+	tests := make(map[string]TestCase)
+	dir := findPublicTestDir(problemID)
+	if dir == "" {
+		return tests
+	}
+	for _, name := range listTestNames(dir) {
+		in, _ := os.ReadFile(filepath.Join(dir, name+".in"))
+		out, _ := os.ReadFile(filepath.Join(dir, name+".out"))
+		tests[name] = TestCase{Input: string(in), Output: string(out)}
+	}
+	return tests
+}
+
+// scanAuditLog calls fn for every entry in file order until fn returns false
+func scanAuditLog(path string, fn func(AuditEntry) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for sc.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue // a torn last line after a crash
+		}
+		if !fn(e) {
+			break
+		}
+	}
+	return sc.Err()
+}
+
+// AuditQuery filters audit entries
+type AuditQuery struct {
+	ProblemID string
+	Actor     string
+	Action    string // exact action or prefix ending in "." (e.g. "testcases.")
+	Since     time.Time
+	Until     time.Time
+	BeforeSeq int64 // only entries older than this sequence number (paging)
+	Limit     int
+}
+
+func parseAuditQuery(r *http.Request) (AuditQuery, error) {
+	v := r.URL.Query()
+	q := AuditQuery{
+		ProblemID: v.Get("problemId"),
+		Actor:     v.Get("actor"),
+		Action:    v.Get("action"),
+		Limit:     defaultAuditLimit,
+	}
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if s := v.Get(name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return q, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*dst = t
+		}
+	}
+	if s := v.Get("before"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("before must be a sequence number")
+		}
+		q.BeforeSeq = n
+	}
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("limit must be a positive integer")
+		}
+		q.Limit = min(n, maxAuditLimit)
+	}
+	return q, nil
+}
+
+func (q AuditQuery) matches(e AuditEntry) bool {
+	if q.ProblemID != "" && e.ProblemID != q.ProblemID {
+		return false
+	}
+	if q.Actor != "" && e.Actor != q.Actor {
+		return false
+	}
+	if q.Action != "" {
+		if strings.HasSuffix(q.Action, ".") {
+			if !strings.HasPrefix(e.Action, q.Action) {
+				return false
+			}
+		} else if e.Action != q.Action {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	if q.BeforeSeq > 0 && e.Seq >= q.BeforeSeq {
+		return false
+	}
+	return true
+}
+
+// queryAuditLog returns the newest matching entries first
+func queryAuditLog(q AuditQuery) ([]AuditEntry, error) {
+	var matched []AuditEntry
+	err := scanAuditLog(auditLogPath(), func(e AuditEntry) bool {
+		if q.matches(e) {
+			matched = append(matched, e)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AuditEntry, 0, min(len(matched), q.Limit))
+	for i := len(matched) - 1; i >= 0 && len(out) < q.Limit; i-- {
+		out = append(out, matched[i])
+	}
+	return out, nil
+}
+
+// writeAuditEntries responds with a page of entries; nextBefore is the
+// "before" value for the next page
+func writeAuditEntries(w http.ResponseWriter, q AuditQuery) {
+	entries, err := queryAuditLog(q)
+	if err != nil {
+		log.Printf("Error reading audit log: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	resp := map[string]interface{}{
+		"status":  "success",
+		"entries": entries,
+	}
+	if len(entries) == q.Limit {
+		resp["nextBefore"] = entries[len(entries)-1].Seq
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// getAuditLog handles GET /api/audit?problemId=&actor=&action=&since=&until=&before=&limit=
+func getAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+	q, err := parseAuditQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	writeAuditEntries(w, q)
+}
+
+// getProblemHistory handles GET /api/problems/{id}/history
+func getProblemHistory(w http.ResponseWriter, r *http.Request, problemID string) {
+	if decoded, err := url.QueryUnescape(problemID); err == nil {
+		problemID = decoded
+	}
+	q, err := parseAuditQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	q.ProblemID = problemID
+	writeAuditEntries(w, q)
+}
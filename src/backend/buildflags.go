@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Programs are compiled and run with the flags of a build profile. Requests
+// pick one with buildProfile; "release" is used when they don't. A problem's
+// manifest can replace a profile's flags for a language, or add profiles
+// of its own, in BuildProfiles.
+
+const defaultBuildProfile = "release"
+
+// BuildFlags are the flags a language is compiled and run with, on top of
+// the ones the runner needs
+type BuildFlags struct {
+	CompileFlags []string `json:"CompileFlags,omitempty"` // passed to the compiler before the sources
+	RunFlags     []string `json:"RunFlags,omitempty"`     // passed to the interpreter or VM before the program
+	Env          []string `json:"Env,omitempty"`          // NAME=value pairs set when the program runs
+}
+
+// BuildProfiles are build flags by profile and language
+type BuildProfiles map[string]map[string]BuildFlags
+
+// sanitizerEnv makes the sanitizers stop at the first error with a stack
+// trace. Leak checking is off: it needs ptrace, which sandboxes deny.
+var sanitizerEnv = []string{
+	"ASAN_OPTIONS=detect_leaks=0:abort_on_error=0:print_summary=1",
+	"UBSAN_OPTIONS=print_stacktrace=1:halt_on_error=1",
+}
+
+// buildProfiles are the server's profiles, by profile and language.
+// Languages without an entry are built without extra flags.
+var buildProfiles = BuildProfiles{
+	"release": {
+		"cpp":   {CompileFlags: []string{"-O2", "-std=c++17", "-Wall"}},
+		"c":     {CompileFlags: []string{"-O2", "-std=gnu11", "-Wall"}},
+		"rust":  {CompileFlags: []string{"-O", "--edition", "2021"}},
+		"swift": {CompileFlags: []string{"-O"}},
+	},
+	"debug": {
+		"cpp":        {CompileFlags: []string{"-O0", "-g", "-std=c++17", "-Wall", "-Wextra", "-fsanitize=address,undefined", "-fno-omit-frame-pointer"}, Env: sanitizerEnv},
+		"c":          {CompileFlags: []string{"-O0", "-g", "-std=gnu11", "-Wall", "-Wextra", "-fsanitize=address,undefined", "-fno-omit-frame-pointer"}, Env: sanitizerEnv},
+		"rust":       {CompileFlags: []string{"-g", "--edition", "2021", "-C", "debug-assertions=on", "-C", "overflow-checks=on"}, Env: []string{"RUST_BACKTRACE=1"}},
+		"go":         {CompileFlags: []string{"-gcflags=all=-N -l"}},
+		"swift":      {CompileFlags: []string{"-Onone", "-g"}},
+		"java":       {CompileFlags: []string{"-g"}, RunFlags: []string{"-ea"}},
+		"python":     {RunFlags: []string{"-X", "dev"}},
+		"javascript": {RunFlags: []string{"--stack-trace-limit=100"}},
+		"typescript": {RunFlags: []string{"--stack-trace-limit=100"}},
+	},
+}
+
+// buildProfileNames lists the profiles a problem can be built with
+func (p Problem) buildProfileNames() []string {
+	seen := map[string]bool{}
+	var names []string
+	for name := range buildProfiles {
+		seen[name] = true
+		names = append(names, name)
+	}
+	for name := range p.BuildProfiles {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildFlagsFor resolves the flags of a profile for a language: the
+// problem's override, then the server's profile. An empty profile is the
+// default one.
+func (p Problem) buildFlagsFor(profile, language string) (BuildFlags, error) {
+	if profile == "" {
+		profile = defaultBuildProfile
+	}
+	if language == "sh" {
+		language = "bash"
+	}
+	override, inProblem := p.BuildProfiles[profile]
+	defaults, inServer := buildProfiles[profile]
+	if !inProblem && !inServer {
+		return BuildFlags{}, fmt.Errorf("unknown build profile %q (available: %s)", profile, strings.Join(p.buildProfileNames(), ", "))
+	}
+	if flags, ok := override[language]; ok {
+		return flags, nil
+	}
+	return defaults[language], nil
+}
+
+// checkBuildProfiles checks the profiles of a manifest
+func checkBuildProfiles(profiles BuildProfiles) error {
+	for name, byLanguage := range profiles {
+		if name == "" {
+			return fmt.Errorf("BuildProfiles: profile name must not be empty")
+		}
+		for lang := range byLanguage {
+			if _, ok := sourceExtensions[lang]; !ok {
+				return fmt.Errorf("BuildProfiles[%q]: language %q is not supported", name, lang)
+			}
+		}
+	}
+	return nil
+}
+
+// withFlags returns flags followed by args, in a new slice
+func withFlags(flags []string, args ...string) []string {
+	return append(append([]string{}, flags...), args...)
+}
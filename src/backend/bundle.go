@@ -0,0 +1,627 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Problem bundles are zip archives that carry one or more problems between
+// environments (dev, gamma, prod). Layout:
+//
+//	ceesarcode-bundle.json        bundle header (format, version, problem list)
+//	problems/{id}/manifest.json   problem manifest (including DrawingData)
+//	problems/{id}/v1/...          every version's tests, checkers, etc.
+//	problems/{id}/uploads/...     uploaded datasets
+const (
+	bundleHeaderName    = "ceesarcode-bundle.json"
+	bundleFormat        = "ceesarcode-bundle"
+	bundleFormatVersion = 1
+
+	maxBundleBytes             = 512 << 20 // compressed archive size accepted by import
+	maxBundleUncompressedBytes = 2 << 30   // guards against zip bombs
+)
+
+// BundleHeader is stored at the root of every exported bundle
+type BundleHeader struct {
+	Format     string                `json:"format"`
+	Version    int                   `json:"version"`
+	ExportedAt string                `json:"exportedAt"`
+	Source     string                `json:"source,omitempty"` // APP_ENV of the exporting server
+	Problems   []BundleProblemHeader `json:"problems"`
+}
+
+// BundleProblemHeader describes a single problem inside a bundle
+type BundleProblemHeader struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Files int    `json:"files"`
+}
+
+// ImportedProblem reports what happened to one problem of an imported bundle
+type ImportedProblem struct {
+	SourceID string   `json:"sourceId"`           // ID inside the bundle
+	ID       string   `json:"id,omitempty"`       // ID the problem was stored under
+	Action   string   `json:"action"`             // "created", "renamed", "overwritten" or "skipped"
+	Files    int      `json:"files,omitempty"`    // number of files written
+	Reason   string   `json:"reason,omitempty"`   // why a problem was skipped
+	Warnings []string `json:"warnings,omitempty"` // parts of a judge package that could not be converted
+
+	trashID string // trash entry of the problem an "overwritten" import replaced
+}
+
+// isValidProblemID rejects IDs that would escape dataDir or collide with
+// internal directories (uploads, dot-directories)
+func isValidProblemID(id string) bool {
+	if id == "" || id == "." || id == ".." || id == "uploads" {
+		return false
+	}
+	if strings.HasPrefix(id, ".") || strings.ContainsAny(id, `/\`) || strings.ContainsRune(id, 0) {
+		return false
+	}
+	return true
+}
+
+// problemExists reports whether a problem directory exists in dataDir
+func problemExists(id string) bool {
+	info, err := os.Stat(filepath.Join(dataDir, id))
+	return err == nil && info.IsDir()
+}
+
+// exportProblems handles GET /api/problems/export?id=a&id=b (all problems when no id is given)
+func exportProblems(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", 405)
+		return
+	}
+
+	var ids []string
+	for _, v := range r.URL.Query()["id"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		entries, err := os.ReadDir(dataDir)
+		if err != nil {
+			log.Printf("Error reading dataDir %s: %v", dataDir, err)
+			http.Error(w, "Internal server error", 500)
+			return
+		}
+		for _, e := range entries {
+			if e.IsDir() && isValidProblemID(e.Name()) && loadProblem(e.Name()).ID != "" {
+				ids = append(ids, e.Name())
+			}
+		}
+	}
+
+	filename := fmt.Sprintf("ceesarcode-problems-%s.zip", time.Now().UTC().Format("20060102-150405"))
+	if len(ids) == 1 {
+		filename = ids[0] + ".zip"
+	}
+	writeProblemBundle(w, ids, filename)
+}
+
+// exportProblem handles GET /api/problems/{id}/export
+func exportProblem(w http.ResponseWriter, r *http.Request, problemID string) {
+	if decoded, err := url.QueryUnescape(problemID); err == nil {
+		problemID = decoded
+	}
+	if !isValidProblemID(problemID) || !problemExists(problemID) {
+		http.Error(w, fmt.Sprintf("Problem not found: %s", problemID), http.StatusNotFound)
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "bundle":
+		writeProblemBundle(w, []string{problemID}, problemID+".zip")
+	case "kattis":
+		writeKattisPackage(w, problemID)
+	case "polygon":
+		writePolygonPackage(w, problemID)
+	default:
+		http.Error(w, "format must be one of bundle, kattis, polygon", 400)
+	}
+}
+
+func writeProblemBundle(w http.ResponseWriter, ids []string, filename string) {
+	for _, id := range ids {
+		if !isValidProblemID(id) || !problemExists(id) {
+			http.Error(w, fmt.Sprintf("Problem not found: %s", id), http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Headers are already sent once the first entry is written, so errors
+	// after this point can only be logged
+	if err := buildProblemBundle(w, ids); err != nil {
+		log.Printf("Error writing problem bundle: %v", err)
+	}
+}
+
+// buildProblemBundle streams a zip containing the given problems to out
+func buildProblemBundle(out io.Writer, ids []string) error {
+	zw := zip.NewWriter(out)
+	header := BundleHeader{
+		Format:     bundleFormat,
+		Version:    bundleFormatVersion,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		Source:     config.AppEnv,
+	}
+
+	for _, id := range ids {
+		problemDir := filepath.Join(dataDir, id)
+		count := 0
+		err := filepath.WalkDir(problemDir, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil // directories are implied, symlinks are never exported
+			}
+			rel, err := filepath.Rel(problemDir, p)
+			if err != nil {
+				return err
+			}
+			if err := addFileToZip(zw, p, path.Join("problems", id, filepath.ToSlash(rel))); err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add problem %s: %v", id, err)
+		}
+		header.Problems = append(header.Problems, BundleProblemHeader{ID: id, Title: loadProblem(id).Title, Files: count})
+	}
+
+	headerData, err := json.MarshalIndent(header, "", "  ")
+	if err != nil {
+		return err
+	}
+	hw, err := zw.Create(bundleHeaderName)
+	if err != nil {
+		return err
+	}
+	if _, err := hw.Write(headerData); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func addFileToZip(zw *zip.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	fh, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	fh.Name = name
+	fh.Method = zip.Deflate
+
+	dst, err := zw.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+// importProblems handles POST /api/problems/import. The archive is sent either
+// as multipart form field "file" or as the raw request body.
+// ?onConflict=rename (default), skip or overwrite controls what happens when a
+// problem ID already exists.
+func importProblems(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", 405)
+		return
+	}
+
+	onConflict := r.URL.Query().Get("onConflict")
+	if onConflict == "" {
+		onConflict = "rename"
+	}
+	if onConflict != "rename" && onConflict != "skip" && onConflict != "overwrite" {
+		http.Error(w, "onConflict must be one of rename, skip, overwrite", 400)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "auto" && format != "bundle" && format != "kattis" && format != "polygon" {
+		http.Error(w, "format must be one of auto, bundle, kattis, polygon", 400)
+		return
+	}
+
+	archivePath, err := receiveArchive(w, r)
+	if err != nil {
+		log.Printf("Failed to receive bundle: %v", err)
+		http.Error(w, "Failed to read archive: "+err.Error(), 400)
+		return
+	}
+	defer os.Remove(archivePath)
+
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		http.Error(w, "Invalid zip archive: "+err.Error(), 400)
+		return
+	}
+	defer zr.Close()
+
+	if format == "" || format == "auto" {
+		format = detectArchiveFormat(&zr.Reader)
+	}
+
+	var results []ImportedProblem
+	switch format {
+	case "bundle":
+		results, err = importProblemBundle(&zr.Reader, onConflict)
+	case "kattis":
+		results, err = importKattisPackage(&zr.Reader, onConflict)
+	case "polygon":
+		results, err = importPolygonPackage(&zr.Reader, onConflict)
+	default:
+		err = fmt.Errorf("unrecognized archive: expected a CeesarCode bundle, a Kattis package or a Polygon package")
+	}
+	if err != nil {
+		log.Printf("Import of %s archive rejected: %v", format, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+		return
+	}
+
+	created := 0
+	for _, res := range results {
+		if res.Action != "skipped" {
+			created++
+			recordAudit(r, "problem.import", res.ID, nil, loadProblem(res.ID), map[string]interface{}{
+				"format":   format,
+				"sourceId": res.SourceID,
+				"action":   res.Action,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"message":  fmt.Sprintf("Imported %d of %d problems", created, len(results)),
+		"problems": results,
+	})
+}
+
+// receiveArchive stores the uploaded archive in a temporary file and returns its path
+func receiveArchive(w http.ResponseWriter, r *http.Request) (string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBundleBytes)
+
+	var src io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return "", err
+		}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return "", fmt.Errorf("multipart form has no \"file\" field")
+			}
+			if err != nil {
+				return "", err
+			}
+			if part.FormName() == "file" {
+				src = part
+				break
+			}
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "ceesarcode-import-*.zip")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// importProblemBundle validates the whole archive before writing anything,
+// extracts every problem into a staging directory and then moves them all
+// into dataDir. The import is all or nothing: if any problem cannot be
+// stored, the ones already moved are rolled back.
+func importProblemBundle(zr *zip.Reader, onConflict string) ([]ImportedProblem, error) {
+	var header *BundleHeader
+	files := make(map[string][]*zip.File) // problem ID -> files
+	var order []string
+	var total uint64
+
+	for _, f := range zr.File {
+		name := f.Name
+		if name == bundleHeaderName {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			var h BundleHeader
+			err = json.NewDecoder(io.LimitReader(rc, 1<<20)).Decode(&h)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", bundleHeaderName, err)
+			}
+			header = &h
+			continue
+		}
+		if strings.HasSuffix(name, "/") {
+			continue // directory entry
+		}
+		if !f.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s: only regular files are allowed in a bundle", name)
+		}
+		if strings.Contains(name, `\`) || path.IsAbs(name) || path.Clean(name) != name {
+			return nil, fmt.Errorf("%s: invalid path", name)
+		}
+		segments := strings.Split(name, "/")
+		if len(segments) < 3 || segments[0] != "problems" {
+			return nil, fmt.Errorf("%s: unexpected file outside problems/", name)
+		}
+		for _, s := range segments {
+			if s == ".." {
+				return nil, fmt.Errorf("%s: invalid path", name)
+			}
+		}
+		id := segments[1]
+		if !isValidProblemID(id) {
+			return nil, fmt.Errorf("%s: invalid problem ID %q", name, id)
+		}
+
+		total += f.UncompressedSize64
+		if total > maxBundleUncompressedBytes {
+			return nil, fmt.Errorf("bundle expands to more than %d bytes", uint64(maxBundleUncompressedBytes))
+		}
+		if _, ok := files[id]; !ok {
+			order = append(order, id)
+		}
+		files[id] = append(files[id], f)
+	}
+
+	if header == nil {
+		return nil, fmt.Errorf("missing %s - not a CeesarCode problem bundle", bundleHeaderName)
+	}
+	if header.Format != bundleFormat {
+		return nil, fmt.Errorf("unknown bundle format %q", header.Format)
+	}
+	if header.Version < 1 || header.Version > bundleFormatVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d (this server supports up to %d)", header.Version, bundleFormatVersion)
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("bundle contains no problems")
+	}
+
+	// Every problem needs a parseable manifest with an ID
+	for _, id := range order {
+		var manifest *zip.File
+		for _, f := range files[id] {
+			if f.Name == path.Join("problems", id, "manifest.json") {
+				manifest = f
+				break
+			}
+		}
+		if manifest == nil {
+			return nil, fmt.Errorf("problem %s has no manifest.json", id)
+		}
+		rc, err := manifest.Open()
+		if err != nil {
+			return nil, err
+		}
+		var p Problem
+		err = json.NewDecoder(rc).Decode(&p)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("problem %s: invalid manifest.json: %v", id, err)
+		}
+		if p.ID == "" {
+			return nil, fmt.Errorf("problem %s: manifest.json has no ID", id)
+		}
+		if p.ID != id {
+			return nil, fmt.Errorf("problem %s: manifest.json has ID %q, expected it to match its directory", id, p.ID)
+		}
+	}
+
+	stagingRoot := filepath.Join(dataDir, ".import-"+uuid.NewString())
+	if err := os.MkdirAll(stagingRoot, 0755); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stagingRoot)
+
+	// Extract every problem before touching dataDir so that a broken entry
+	// late in the archive cannot leave a partial import behind
+	counts := make(map[string]int, len(order))
+	for _, sourceID := range order {
+		n, err := extractBundleProblem(files[sourceID], sourceID, filepath.Join(stagingRoot, sourceID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %v", sourceID, err)
+		}
+		counts[sourceID] = n
+	}
+
+	var results []ImportedProblem
+	for _, sourceID := range order {
+		res, err := commitImportedProblem(filepath.Join(stagingRoot, sourceID), sourceID, onConflict)
+		if err != nil {
+			rollbackImportedProblems(results)
+			return nil, fmt.Errorf("%v; no problems were imported", err)
+		}
+		if res.Action != "skipped" {
+			res.Files = counts[sourceID]
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// rollbackImportedProblems undoes already committed problems of a failed
+// import: new problems are removed and overwritten ones restored from the trash
+func rollbackImportedProblems(results []ImportedProblem) {
+	for i := len(results) - 1; i >= 0; i-- {
+		res := results[i]
+		if res.Action == "skipped" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dataDir, res.ID)); err != nil {
+			log.Printf("Failed to roll back imported problem %s: %v", res.ID, err)
+			continue
+		}
+		problemCache.invalidate(res.ID)
+		if res.trashID != "" {
+			if _, err := restoreFromTrash(res.trashID, "fail"); err != nil {
+				log.Printf("Failed to restore %s from trash %s: %v", res.ID, res.trashID, err)
+			}
+		}
+		log.Printf("Rolled back imported problem %s", res.ID)
+	}
+}
+
+// commitImportedProblem moves a fully extracted problem from its staging
+// directory into dataDir, resolving ID conflicts according to onConflict
+func commitImportedProblem(stagingDir, sourceID, onConflict string) (ImportedProblem, error) {
+	res := ImportedProblem{SourceID: sourceID, ID: sourceID, Action: "created"}
+	if problemExists(sourceID) {
+		switch onConflict {
+		case "skip":
+			res.ID = ""
+			res.Action = "skipped"
+			res.Reason = "a problem with this ID already exists"
+			return res, nil
+		case "overwrite":
+			res.Action = "overwritten"
+		default:
+			res.ID = nextFreeProblemID(sourceID)
+			res.Action = "renamed"
+		}
+	}
+
+	if res.ID != sourceID {
+		if err := rewriteManifestID(filepath.Join(stagingDir, "manifest.json"), res.ID); err != nil {
+			return res, fmt.Errorf("failed to rename %s: %v", sourceID, err)
+		}
+	}
+
+	target := filepath.Join(dataDir, res.ID)
+	if res.Action == "overwritten" {
+		entry, err := moveToTrash(res.ID, "overwritten by import")
+		if err != nil {
+			return res, fmt.Errorf("failed to replace %s: %v", res.ID, err)
+		}
+		res.trashID = entry.TrashID
+	}
+	if err := os.Rename(stagingDir, target); err != nil {
+		if res.trashID != "" {
+			restoreFromTrash(res.trashID, "fail")
+		}
+		return res, fmt.Errorf("failed to store %s: %v", res.ID, err)
+	}
+	problemCache.invalidate(res.ID)
+	log.Printf("Imported problem %s as %s (%s)", sourceID, res.ID, res.Action)
+	return res, nil
+}
+
+func extractBundleProblem(files []*zip.File, id, dest string) (int, error) {
+	prefix := path.Join("problems", id) + "/"
+	count := 0
+	for _, f := range files {
+		rel := strings.TrimPrefix(f.Name, prefix)
+		target := filepath.Join(dest, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return count, err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return count, err
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			rc.Close()
+			return count, err
+		}
+		_, err = io.Copy(out, io.LimitReader(rc, int64(f.UncompressedSize64)+1))
+		rc.Close()
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// nextFreeProblemID returns id-2, id-3, ... whichever is not taken yet
+func nextFreeProblemID(id string) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", id, i)
+		if !problemExists(candidate) {
+			return candidate
+		}
+	}
+}
+
+// rewriteManifestID changes the ID of a manifest while keeping every other
+// field (including ones this server does not know about) untouched
+func rewriteManifestID(manifestPath, newID string) error {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	// Manifests use both "ID" and "id" - keep whichever spelling is present
+	key := "ID"
+	for k := range m {
+		if strings.EqualFold(k, "id") {
+			key = k
+			break
+		}
+	}
+	m[key] = newID
+
+	updated, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(manifestPath, updated, 0644)
+}
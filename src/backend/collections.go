@@ -0,0 +1,394 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A collection is an ordered list of problems (or single parts of
+// multi-part problems) that interviewers prepare for a candidate, e.g. two
+// coding problems followed by a system design. Collections are stored as
+// data/problems/.collections/{id}.json.
+
+const collectionsDirName = ".collections"
+
+// Collection is an interview loop
+type Collection struct {
+	ID           string           `json:"id"`
+	Title        string           `json:"title"`
+	Description  string           `json:"description,omitempty"`
+	Company      string           `json:"company,omitempty"`
+	Role         string           `json:"role,omitempty"`
+	Level        string           `json:"level,omitempty"`
+	Items        []CollectionItem `json:"items"`
+	TotalMinutes int              `json:"totalMinutes"` // sum of the item budgets
+	CreatedAt    time.Time        `json:"createdAt"`
+	UpdatedAt    time.Time        `json:"updatedAt"`
+}
+
+// CollectionItem is one step of a loop
+type CollectionItem struct {
+	ProblemID string `json:"problemId"`
+	Part      int    `json:"part,omitempty"`    // only this part of a multi-part problem (0 = all parts)
+	Minutes   int    `json:"minutes,omitempty"` // time budget; defaults to the problem's EstimatedMinutes
+	Notes     string `json:"notes,omitempty"`   // interviewer notes, e.g. follow-up questions
+
+	// Filled in by GET with ?expand=problems
+	Problem *ProblemSummary `json:"problem,omitempty"`
+	Missing bool            `json:"missing,omitempty"` // the problem was deleted since
+}
+
+var (
+	errCollectionNotFound = errors.New("collection not found")
+
+	// collectionsMu serializes collection writes
+	collectionsMu sync.Mutex
+)
+
+func collectionsRoot() string {
+	return filepath.Join(dataDir, collectionsDirName)
+}
+
+func collectionPath(id string) string {
+	return filepath.Join(collectionsRoot(), id+".json")
+}
+
+func readCollection(id string) (Collection, error) {
+	if !isValidProblemID(id) {
+		return Collection{}, errCollectionNotFound
+	}
+	data, err := os.ReadFile(collectionPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Collection{}, errCollectionNotFound
+		}
+		return Collection{}, err
+	}
+	var c Collection
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Collection{}, fmt.Errorf("invalid collection %s: %v", id, err)
+	}
+	return c, nil
+}
+
+// writeCollection stores a collection atomically
+func writeCollection(c Collection) error {
+	if err := os.MkdirAll(collectionsRoot(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := collectionPath(c.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, collectionPath(c.ID)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// listCollections returns every collection ordered by title
+func listCollections() ([]Collection, error) {
+	ents, err := os.ReadDir(collectionsRoot())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Collection{}, nil
+		}
+		return nil, err
+	}
+	out := make([]Collection, 0, len(ents))
+	for _, e := range ents {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		c, err := readCollection(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			log.Printf("Skipping collection %s: %v", e.Name(), err)
+			continue
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ti, tj := strings.ToLower(out[i].Title), strings.ToLower(out[j].Title)
+		if ti != tj {
+			return ti < tj
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+// nextFreeCollectionID returns id, or id-2, id-3, ... whichever is not taken
+func nextFreeCollectionID(id string) string {
+	candidate := id
+	for i := 2; ; i++ {
+		if _, err := os.Stat(collectionPath(candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", id, i)
+	}
+}
+
+// validateCollection checks that every item refers to an existing problem
+// and part, fills in default time budgets and recomputes the total
+func validateCollection(c *Collection) error {
+	c.Title = strings.TrimSpace(c.Title)
+	if c.Title == "" {
+		return fmt.Errorf("title must not be empty")
+	}
+	if c.Items == nil {
+		c.Items = []CollectionItem{}
+	}
+	c.TotalMinutes = 0
+	for i := range c.Items {
+		item := &c.Items[i]
+		item.Problem, item.Missing = nil, false
+		p, ok := problemCache.get(item.ProblemID)
+		if !ok {
+			return fmt.Errorf("items[%d]: problem %q not found", i, item.ProblemID)
+		}
+		if item.Part < 0 {
+			return fmt.Errorf("items[%d]: part must not be negative", i)
+		}
+		if item.Part > 1 && !problemHasPart(p, item.Part) {
+			return fmt.Errorf("items[%d]: problem %q has no part %d", i, item.ProblemID, item.Part)
+		}
+		if item.Minutes < 0 {
+			return fmt.Errorf("items[%d]: minutes must not be negative", i)
+		}
+		if item.Minutes == 0 {
+			item.Minutes = p.EstimatedMinutes
+		}
+		c.TotalMinutes += item.Minutes
+	}
+	return nil
+}
+
+func problemHasPart(p Problem, part int) bool {
+	for _, pt := range p.Parts {
+		if pt.PartNumber == part {
+			return true
+		}
+	}
+	return false
+}
+
+// expandCollection attaches a summary of each item's problem
+func expandCollection(c *Collection) {
+	for i := range c.Items {
+		if p, ok := problemCache.get(c.Items[i].ProblemID); ok {
+			s := summarizeProblem(p)
+			c.Items[i].Problem = &s
+		} else {
+			c.Items[i].Missing = true
+		}
+	}
+}
+
+// createCollection stores a new collection, deriving its ID from the title
+func createCollection(c Collection) (Collection, error) {
+	if err := validateCollection(&c); err != nil {
+		return c, err
+	}
+
+	collectionsMu.Lock()
+	defer collectionsMu.Unlock()
+
+	base := problemIDFromTitle(c.Title)
+	if !isValidProblemID(base) {
+		base = "collection"
+	}
+	c.ID = nextFreeCollectionID(base)
+	c.CreatedAt = time.Now().UTC()
+	c.UpdatedAt = c.CreatedAt
+	if err := writeCollection(c); err != nil {
+		return c, err
+	}
+	log.Printf("Created collection %s with %d items", c.ID, len(c.Items))
+	return c, nil
+}
+
+// collectionFromProblems builds a loop out of freshly generated problems,
+// in generation order
+func collectionFromProblems(title string, req AgentRequest, problems []Problem) Collection {
+	if title == "" {
+		title = fmt.Sprintf("%s %s %s loop", req.Company, req.Level, req.Role)
+	}
+	c := Collection{
+		Title:   title,
+		Company: req.Company,
+		Role:    req.Role,
+		Level:   req.Level,
+		Items:   make([]CollectionItem, 0, len(problems)),
+	}
+	for _, p := range problems {
+		c.Items = append(c.Items, CollectionItem{ProblemID: p.ID, Minutes: p.EstimatedMinutes})
+	}
+	return c
+}
+
+func writeCollectionJSON(w http.ResponseWriter, status int, c Collection) {
+	w.Header().Set("ETag", collectionETag(c))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(c)
+}
+
+// collectionETag changes with every write; it ignores ?expand=problems
+func collectionETag(c Collection) string {
+	return etagFor([]interface{}{c.ID, c.UpdatedAt})
+}
+
+func writeCollectionError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+}
+
+// handleCollectionRoutes serves /api/collections and /api/collections/{id}
+//
+//	GET    /api/collections                       list collections
+//	POST   /api/collections                       create a collection
+//	GET    /api/collections/{id}[?expand=problems] get one collection
+//	PUT    /api/collections/{id}                  replace it (If-Match required)
+//	DELETE /api/collections/{id}                  delete it
+func handleCollectionRoutes(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/collections"), "/")
+	if decoded, err := url.QueryUnescape(id); err == nil {
+		id = decoded
+	}
+
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			collections, err := listCollections()
+			if err != nil {
+				log.Printf("Error listing collections: %v", err)
+				http.Error(w, "Internal server error", 500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(collections)
+		case http.MethodPost:
+			var req Collection
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid JSON", 400)
+				return
+			}
+			c, err := createCollection(req)
+			if err != nil {
+				writeCollectionError(w, err)
+				return
+			}
+			recordAudit(r, "collection.create", "", nil, c, map[string]interface{}{"collectionId": c.ID})
+			writeCollectionJSON(w, http.StatusCreated, c)
+		default:
+			http.Error(w, "GET or POST only", 405)
+		}
+		return
+	}
+
+	if strings.Contains(id, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		c, err := readCollection(id)
+		if err != nil {
+			writeCollectionReadError(w, id, err)
+			return
+		}
+		if r.URL.Query().Get("expand") == "problems" {
+			expandCollection(&c)
+		}
+		writeCollectionJSON(w, 200, c)
+
+	case http.MethodPut:
+		var req Collection
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", 400)
+			return
+		}
+
+		collectionsMu.Lock()
+		defer collectionsMu.Unlock()
+
+		current, err := readCollection(id)
+		if err != nil {
+			writeCollectionReadError(w, id, err)
+			return
+		}
+		etag := collectionETag(current)
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch == "" || !etagMatches(ifMatch, etag) {
+			w.Header().Set("ETag", etag)
+			if ifMatch == "" {
+				http.Error(w, "If-Match header required; fetch the collection to get its ETag", http.StatusPreconditionRequired)
+			} else {
+				http.Error(w, "Collection was modified by someone else; reload it and retry", http.StatusPreconditionFailed)
+			}
+			return
+		}
+
+		req.ID = current.ID
+		req.CreatedAt = current.CreatedAt
+		if err := validateCollection(&req); err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+		req.UpdatedAt = time.Now().UTC()
+		if err := writeCollection(req); err != nil {
+			log.Printf("Failed to save collection %s: %v", id, err)
+			http.Error(w, "Failed to save collection", 500)
+			return
+		}
+		recordAudit(r, "collection.update", "", current, req, map[string]interface{}{"collectionId": id})
+		writeCollectionJSON(w, 200, req)
+
+	case http.MethodDelete:
+		collectionsMu.Lock()
+		defer collectionsMu.Unlock()
+
+		current, err := readCollection(id)
+		if err != nil {
+			writeCollectionReadError(w, id, err)
+			return
+		}
+		if err := os.Remove(collectionPath(id)); err != nil {
+			log.Printf("Failed to delete collection %s: %v", id, err)
+			http.Error(w, "Failed to delete collection", 500)
+			return
+		}
+		recordAudit(r, "collection.delete", "", current, nil, map[string]interface{}{"collectionId": id})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+
+	default:
+		http.Error(w, "GET, PUT or DELETE only", 405)
+	}
+}
+
+func writeCollectionReadError(w http.ResponseWriter, id string, err error) {
+	if errors.Is(err, errCollectionNotFound) {
+		http.Error(w, "Collection not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Error reading collection %s: %v", id, err)
+	http.Error(w, "Internal server error", 500)
+}
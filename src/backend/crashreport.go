@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// When a run fails, its output is searched for an AddressSanitizer or
+// UndefinedBehaviorSanitizer report, a Rust panic, or a fatal signal, and
+// the first one found is returned as a CrashReport. The debug build profile
+// compiles C and C++ with the sanitizers and Rust with debug assertions, so
+// that crashes there come with a report instead of just an exit status.
+
+// CrashReport describes why a program crashed
+type CrashReport struct {
+	Tool        string        `json:"tool"`    // "AddressSanitizer", "UndefinedBehaviorSanitizer", "rust" or "signal"
+	Kind        string        `json:"kind"`    // e.g. "heap-buffer-overflow", "signed-integer-overflow", "SIGSEGV"
+	Message     string        `json:"message"` // one-line summary
+	Location    *StackFrame   `json:"location,omitempty"`
+	Access      *MemoryAccess `json:"access,omitempty"`
+	Frames      []StackFrame  `json:"frames,omitempty"`      // where it crashed, innermost first
+	AllocatedAt []StackFrame  `json:"allocatedAt,omitempty"` // where the memory was allocated
+	FreedAt     []StackFrame  `json:"freedAt,omitempty"`     // where the memory was freed
+}
+
+// MemoryAccess is the access a memory error was reported for
+type MemoryAccess struct {
+	Type     string `json:"type,omitempty"` // "READ" or "WRITE"
+	Size     int    `json:"size,omitempty"` // in bytes
+	Address  string `json:"address"`
+	Region   string `json:"region,omitempty"`   // where the address is, as the sanitizer describes it
+	Variable string `json:"variable,omitempty"` // stack variable the access overflowed
+}
+
+// StackFrame is one frame of a crash's stack trace. File is relative to
+// the project for the submission's own files.
+type StackFrame struct {
+	Function string `json:"function,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Module   string `json:"module,omitempty"` // binary and offset, for frames without debug info
+	Source   string `json:"source,omitempty"` // the line of code, for the submission's files
+	User     bool   `json:"user"`             // in the submission rather than a library
+}
+
+var (
+	asanErrorRe  = regexp.MustCompile(`(?m)^==\d+==ERROR: AddressSanitizer: (\S+) on (?:unknown )?address (0x[0-9a-fA-F]+)`)
+	asanAccessRe = regexp.MustCompile(`(?m)^(READ|WRITE) of size (\d+) at (0x[0-9a-fA-F]+)`)
+	asanCauseRe  = regexp.MustCompile(`(?m)^==\d+==The signal is caused by a (READ|WRITE) memory access`)
+	asanRegionRe = regexp.MustCompile(`(?m)^(?:0x[0-9a-fA-F]+|Address 0x[0-9a-fA-F]+) is located .*$`)
+	asanVarRe    = regexp.MustCompile(`(?m)\[\d+, \d+\) '([^']+)'.*<== Memory access`)
+	summaryRe    = regexp.MustCompile(`(?m)^SUMMARY: \S+: (.*)$`)
+	ubsanRe      = regexp.MustCompile(`(?m)^(.+?):(\d+):(\d+): runtime error: (.+)$`)
+	frameRe      = regexp.MustCompile(`^\s*#\d+ 0x[0-9a-fA-F]+(?: in (.+?))?(?:\s+\(([^()]*)\)|\s+(\S+?):(\d+)(?::(\d+))?)?(?:\s+\(BuildId: [0-9a-fA-F]+\))?\s*$`)
+	rustPanicRe  = regexp.MustCompile(`(?m)^thread '[^']*' panicked at (?:'(.*)', )?(.+?):(\d+):(\d+):?$`)
+	rustStackRe  = regexp.MustCompile(`(?m)^thread '[^']*' has overflowed its stack`)
+	rustFrameRe  = regexp.MustCompile(`^\s+\d+: (.+)$`)
+	rustAtRe     = regexp.MustCompile(`^\s+at (.+?):(\d+):(\d+)$`)
+	signalRe     = regexp.MustCompile(`signal: ([a-z/ ]+)`)
+)
+
+// ubsanKinds names UBSan errors by their message
+var ubsanKinds = []struct{ prefix, kind string }{
+	{"signed integer overflow", "signed-integer-overflow"},
+	{"index ", "index-out-of-bounds"},
+	{"load of null pointer", "null-pointer-dereference"},
+	{"store to null pointer", "null-pointer-dereference"},
+	{"member access within null pointer", "null-pointer-dereference"},
+	{"member call on null pointer", "null-pointer-dereference"},
+	{"reference binding to null pointer", "null-pointer-dereference"},
+	{"division by zero", "division-by-zero"},
+	{"shift exponent", "invalid-shift"},
+	{"left shift of", "invalid-shift"},
+	{"load of misaligned address", "misaligned-access"},
+	{"store to misaligned address", "misaligned-access"},
+	{"load of value", "invalid-value"},
+	{"execution reached the end of a value-returning function", "missing-return"},
+	{"execution reached an unreachable program point", "unreachable"},
+	{"pointer index expression", "pointer-overflow"},
+	{"applying non-zero offset", "pointer-overflow"},
+	{"negation of", "signed-integer-overflow"},
+}
+
+// rustKinds names Rust panics by their message
+var rustKinds = []struct{ prefix, kind string }{
+	{"index out of bounds", "index-out-of-bounds"},
+	{"attempt to add with overflow", "integer-overflow"},
+	{"attempt to subtract with overflow", "integer-overflow"},
+	{"attempt to multiply with overflow", "integer-overflow"},
+	{"attempt to negate with overflow", "integer-overflow"},
+	{"attempt to shift", "integer-overflow"},
+	{"attempt to divide by zero", "division-by-zero"},
+	{"attempt to calculate the remainder with a divisor of zero", "division-by-zero"},
+	{"called `Option::unwrap()` on a `None` value", "unwrap-none"},
+	{"called `Result::unwrap()` on an `Err` value", "unwrap-err"},
+	{"byte index", "string-index"},
+	{"range end index", "index-out-of-bounds"},
+	{"range start index", "index-out-of-bounds"},
+	{"assertion", "assertion-failed"},
+}
+
+// signalNames are the signals a crashed process is reported as, by the
+// description in its exit error
+var signalNames = map[string]string{
+	"segmentation fault":       "SIGSEGV",
+	"aborted":                  "SIGABRT",
+	"floating point exception": "SIGFPE",
+	"bus error":                "SIGBUS",
+	"illegal instruction":      "SIGILL",
+	"trace/breakpoint trap":    "SIGTRAP",
+}
+
+// parseCrashReport returns the report of a failed run of the project in
+// dir, or nil when output and runErr don't show a crash
+func parseCrashReport(output string, runErr error, dir string) *CrashReport {
+	files := newSourceFiles(dir)
+	var report *CrashReport
+	at := -1
+	// Several tools may report; the earliest report is the cause
+	consider := func(pos int, parse func() *CrashReport) {
+		if pos >= 0 && (at < 0 || pos < at) {
+			if r := parse(); r != nil {
+				report, at = r, pos
+			}
+		}
+	}
+	if loc := asanErrorRe.FindStringIndex(output); loc != nil {
+		consider(loc[0], func() *CrashReport { return parseASanReport(output[loc[0]:], files) })
+	}
+	if loc := ubsanRe.FindStringIndex(output); loc != nil {
+		consider(loc[0], func() *CrashReport { return parseUBSanReport(output[loc[0]:], files) })
+	}
+	if loc := rustPanicRe.FindStringIndex(output); loc != nil {
+		consider(loc[0], func() *CrashReport { return parseRustPanic(output[loc[0]:], files) })
+	}
+	if loc := rustStackRe.FindStringIndex(output); loc != nil {
+		consider(loc[0], func() *CrashReport {
+			return &CrashReport{Tool: "rust", Kind: "stack-overflow", Message: "stack overflow"}
+		})
+	}
+	if report != nil {
+		if report.Location == nil {
+			report.Location = firstUserFrame(report.Frames)
+		}
+		return report
+	}
+	if runErr != nil {
+		if m := signalRe.FindStringSubmatch(runErr.Error()); m != nil {
+			desc := strings.TrimSpace(strings.TrimSuffix(m[1], " core dumped"))
+			if name, ok := signalNames[desc]; ok {
+				return &CrashReport{Tool: "signal", Kind: name, Message: "program crashed with " + name + " (" + desc + ")"}
+			}
+		}
+	}
+	return nil
+}
+
+// parseASanReport parses an AddressSanitizer report starting at its
+// ERROR line
+func parseASanReport(text string, files *sourceFiles) *CrashReport {
+	m := asanErrorRe.FindStringSubmatch(text)
+	if m == nil {
+		return nil
+	}
+	r := &CrashReport{Tool: "AddressSanitizer", Kind: m[1], Access: &MemoryAccess{Address: m[2]}}
+	if a := asanAccessRe.FindStringSubmatch(text); a != nil {
+		r.Access.Type = a[1]
+		r.Access.Size, _ = strconv.Atoi(a[2])
+		r.Access.Address = a[3]
+	} else if c := asanCauseRe.FindStringSubmatch(text); c != nil {
+		r.Access.Type = c[1]
+	}
+	if region := asanRegionRe.FindString(text); region != "" {
+		r.Access.Region = strings.TrimSpace(region)
+	}
+	if v := asanVarRe.FindStringSubmatch(text); v != nil {
+		r.Access.Variable = v[1]
+	}
+	if s := summaryRe.FindStringSubmatch(text); s != nil {
+		r.Message = files.shorten(s[1])
+	} else {
+		r.Message = r.Kind
+	}
+
+	lines := strings.Split(text, "\n")
+	r.Frames = parseFrames(lines[1:], files)
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "freed by thread"):
+			r.FreedAt = parseFrames(lines[i+1:], files)
+		case strings.HasPrefix(line, "previously allocated by thread"), strings.HasPrefix(line, "allocated by thread"):
+			r.AllocatedAt = parseFrames(lines[i+1:], files)
+		}
+	}
+	return r
+}
+
+// parseUBSanReport parses an UndefinedBehaviorSanitizer error starting at
+// its "runtime error" line
+func parseUBSanReport(text string, files *sourceFiles) *CrashReport {
+	m := ubsanRe.FindStringSubmatch(text)
+	if m == nil {
+		return nil
+	}
+	r := &CrashReport{Tool: "UndefinedBehaviorSanitizer", Kind: "undefined-behavior", Message: m[4]}
+	for _, k := range ubsanKinds {
+		if strings.HasPrefix(m[4], k.prefix) {
+			r.Kind = k.kind
+			break
+		}
+	}
+	line, _ := strconv.Atoi(m[2])
+	col, _ := strconv.Atoi(m[3])
+	loc := files.frame("", m[1], line, col)
+	r.Location = &loc
+	r.Frames = parseFrames(strings.Split(text, "\n")[1:], files)
+	return r
+}
+
+// parseRustPanic parses a panic message and the backtrace after it
+func parseRustPanic(text string, files *sourceFiles) *CrashReport {
+	m := rustPanicRe.FindStringSubmatch(text)
+	if m == nil {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	message := m[1]
+	rest := lines[1:]
+	if message == "" && len(rest) > 0 {
+		// Since Rust 1.73 the message follows on its own line
+		message, rest = rest[0], rest[1:]
+	}
+	r := &CrashReport{Tool: "rust", Kind: "panic", Message: message}
+	for _, k := range rustKinds {
+		if strings.HasPrefix(message, k.prefix) {
+			r.Kind = k.kind
+			break
+		}
+	}
+	line, _ := strconv.Atoi(m[3])
+	col, _ := strconv.Atoi(m[4])
+	loc := files.frame("", m[2], line, col)
+	r.Location = &loc
+
+	// With RUST_BACKTRACE=1 the frames follow "stack backtrace:", each
+	// with its location on the next line
+	for i, l := range rest {
+		if strings.TrimSpace(l) != "stack backtrace:" {
+			continue
+		}
+		for _, l := range rest[i+1:] {
+			if f := rustFrameRe.FindStringSubmatch(l); f != nil {
+				r.Frames = append(r.Frames, StackFrame{Function: strings.TrimSpace(f[1])})
+			} else if a := rustAtRe.FindStringSubmatch(l); a != nil && len(r.Frames) > 0 {
+				line, _ := strconv.Atoi(a[2])
+				col, _ := strconv.Atoi(a[3])
+				last := &r.Frames[len(r.Frames)-1]
+				*last = files.frame(last.Function, a[1], line, col)
+			} else if !strings.HasPrefix(l, " ") {
+				break
+			}
+		}
+		break
+	}
+	return r
+}
+
+// parseFrames parses the sanitizer stack trace at the start of lines,
+// skipping lines before its first frame
+func parseFrames(lines []string, files *sourceFiles) []StackFrame {
+	var frames []StackFrame
+	for _, line := range lines {
+		m := frameRe.FindStringSubmatch(line)
+		if m == nil {
+			if len(frames) > 0 {
+				break
+			}
+			continue
+		}
+		if m[3] == "" {
+			// no debug info: "(/lib/x86_64-linux-gnu/libc.so.6+0x27249)",
+			// with "(BuildId: ...)" after it since GCC 12 and clang 15
+			frames = append(frames, StackFrame{Function: m[1], Module: m[2]})
+			continue
+		}
+		ln, _ := strconv.Atoi(m[4])
+		col, _ := strconv.Atoi(m[5])
+		frames = append(frames, files.frame(m[1], m[3], ln, col))
+	}
+	return frames
+}
+
+// firstUserFrame returns the innermost frame in the submission's code
+func firstUserFrame(frames []StackFrame) *StackFrame {
+	for i := range frames {
+		if frames[i].User {
+			f := frames[i]
+			return &f
+		}
+	}
+	return nil
+}
+
+// sourceFiles maps paths in crash reports to the project's files
+type sourceFiles struct {
+	roots []string // the project directory, as given and with symlinks resolved
+	lines map[string][]string
+}
+
+func newSourceFiles(dir string) *sourceFiles {
+	sf := &sourceFiles{lines: map[string][]string{}}
+	if dir == "" {
+		return sf
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		sf.roots = append(sf.roots, abs)
+		if real, err := filepath.EvalSymlinks(abs); err == nil && real != abs {
+			sf.roots = append(sf.roots, real)
+		}
+	}
+	return sf
+}
+
+// relative returns path relative to the project, or "" when it isn't one
+// of the project's files
+func (sf *sourceFiles) relative(path string) string {
+	if len(sf.roots) == 0 {
+		return ""
+	}
+	rel := strings.TrimPrefix(path, "./")
+	for _, root := range sf.roots {
+		if strings.HasPrefix(path, root+string(filepath.Separator)) {
+			rel = strings.TrimPrefix(path, root+string(filepath.Separator))
+			break
+		}
+	}
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return ""
+	}
+	if info, err := os.Stat(filepath.Join(sf.roots[0], rel)); err != nil || info.IsDir() {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// frame builds a frame, mapping the file to the project's source
+func (sf *sourceFiles) frame(function, path string, line, col int) StackFrame {
+	f := StackFrame{Function: function, File: path, Line: line, Column: col}
+	if rel := sf.relative(path); rel != "" {
+		f.File, f.User = rel, true
+		f.Source = sf.line(rel, line)
+	}
+	return f
+}
+
+// line returns a line of a project file, without surrounding whitespace
+func (sf *sourceFiles) line(rel string, n int) string {
+	lines, ok := sf.lines[rel]
+	if !ok {
+		if f, err := os.Open(filepath.Join(sf.roots[0], filepath.FromSlash(rel))); err == nil {
+			sc := bufio.NewScanner(f)
+			sc.Buffer(make([]byte, 64*1024), 1024*1024)
+			for sc.Scan() {
+				lines = append(lines, sc.Text())
+			}
+			f.Close()
+		}
+		sf.lines[rel] = lines
+	}
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[n-1])
+}
+
+// shorten replaces the project directory in a message with nothing, so
+// paths read like the submission's
+func (sf *sourceFiles) shorten(s string) string {
+	for _, root := range sf.roots {
+		s = strings.ReplaceAll(s, root+string(filepath.Separator), "")
+	}
+	return s
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCrashProject writes the files of a crashed project and returns its
+// directory
+func writeCrashProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const crashCppSource = `#include <cstdio>
+int main() {
+    int* a = new int[4];
+    int i = 4;
+    printf("%d\n", a[i]);
+    return 0;
+}
+`
+
+// ASan output of g++ -fsanitize=address with the BuildId suffix of GCC 12
+// and later; {dir} is the project directory
+const asanHeapOverflow = `=================================================================
+==10782==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x602000000020 at pc 0x55b9819f9281 bp 0x7ffc2f427ba0 sp 0x7ffc2f427b98
+READ of size 4 at 0x602000000020 thread T0
+    #0 0x55b9819f9280 in main {dir}/Main.cpp:5
+    #1 0x7fee75a45249  (/lib/x86_64-linux-gnu/libc.so.6+0x27249) (BuildId: 82ce4e6e4ef08fa58a3535f7437bd3e592db5ac0)
+    #2 0x7fee75a45304 in __libc_start_main (/lib/x86_64-linux-gnu/libc.so.6+0x27304) (BuildId: 82ce4e6e4ef08fa58a3535f7437bd3e592db5ac0)
+    #3 0x55b9819f90f0 in _start ({dir}/main+0x10f0) (BuildId: 9b2d5e1c4f0a7a3b2c1d0e9f8a7b6c5d4e3f2a1b)
+
+0x602000000020 is located 0 bytes to the right of 16-byte region [0x602000000010,0x602000000020)
+allocated by thread T0 here:
+    #0 0x7fee768b9628 in operator new[](unsigned long) ../../../../src/libsanitizer/asan/asan_new_delete.cpp:98
+    #1 0x55b9819f91cb in main {dir}/Main.cpp:3
+    #2 0x7fee75a45249  (/lib/x86_64-linux-gnu/libc.so.6+0x27249) (BuildId: 82ce4e6e4ef08fa58a3535f7437bd3e592db5ac0)
+
+SUMMARY: AddressSanitizer: heap-buffer-overflow {dir}/Main.cpp:5 in main
+Shadow bytes around the buggy address:
+  0x0c047fff7ff0: 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+=>0x0c047fff8000: fa fa 00 00[fa]fa fa fa fa fa fa fa fa fa fa fa
+==10782==ABORTING
+`
+
+func TestParseCrashReportASan(t *testing.T) {
+	dir := writeCrashProject(t, map[string]string{"Main.cpp": crashCppSource})
+	output := strings.ReplaceAll(asanHeapOverflow, "{dir}", dir)
+
+	r := parseCrashReport(output, errors.New("exit status 1"), dir)
+	if r == nil {
+		t.Fatal("no report")
+	}
+	if r.Tool != "AddressSanitizer" || r.Kind != "heap-buffer-overflow" {
+		t.Errorf("tool, kind = %q, %q", r.Tool, r.Kind)
+	}
+	if r.Message != "heap-buffer-overflow Main.cpp:5 in main" {
+		t.Errorf("message = %q", r.Message)
+	}
+	if a := r.Access; a == nil || a.Type != "READ" || a.Size != 4 || a.Address != "0x602000000020" ||
+		a.Region != "0x602000000020 is located 0 bytes to the right of 16-byte region [0x602000000010,0x602000000020)" {
+		t.Errorf("access = %+v", r.Access)
+	}
+
+	want := []StackFrame{
+		{Function: "main", File: "Main.cpp", Line: 5, Source: `printf("%d\n", a[i]);`, User: true},
+		{Module: "/lib/x86_64-linux-gnu/libc.so.6+0x27249"},
+		{Function: "__libc_start_main", Module: "/lib/x86_64-linux-gnu/libc.so.6+0x27304"},
+		{Function: "_start", Module: dir + "/main+0x10f0"},
+	}
+	if len(r.Frames) != len(want) {
+		t.Fatalf("frames = %+v, want %d", r.Frames, len(want))
+	}
+	for i := range want {
+		if r.Frames[i] != want[i] {
+			t.Errorf("frame %d = %+v, want %+v", i, r.Frames[i], want[i])
+		}
+	}
+	if r.Location == nil || *r.Location != want[0] {
+		t.Errorf("location = %+v", r.Location)
+	}
+	if len(r.AllocatedAt) != 3 || r.AllocatedAt[0].Function != "operator new[](unsigned long)" || r.AllocatedAt[0].User ||
+		r.AllocatedAt[1].Line != 3 || !r.AllocatedAt[1].User {
+		t.Errorf("allocatedAt = %+v", r.AllocatedAt)
+	}
+}
+
+func TestParseCrashReportFrames(t *testing.T) {
+	tests := []struct {
+		line string
+		want StackFrame
+	}{
+		{"    #0 0x55b9819f9280 in main /src/Main.cpp:6", StackFrame{Function: "main", File: "/src/Main.cpp", Line: 6}},
+		{"    #0 0x55b9819f9280 in main /src/Main.cpp:6:15", StackFrame{Function: "main", File: "/src/Main.cpp", Line: 6, Column: 15}},
+		{"    #1 0x7fee75a45249  (/lib/x86_64-linux-gnu/libc.so.6+0x27249)", StackFrame{Module: "/lib/x86_64-linux-gnu/libc.so.6+0x27249"}},
+		{"    #2 0x7f in __libc_start_main (/lib/x86_64-linux-gnu/libc.so.6+0x29e40) (BuildId: 0123abcd)", StackFrame{Function: "__libc_start_main", Module: "/lib/x86_64-linux-gnu/libc.so.6+0x29e40"}},
+		{"    #3 0x5 in _start (/tmp/x/main+0x10f0) (BuildId: 9b2d5e1c)", StackFrame{Function: "_start", Module: "/tmp/x/main+0x10f0"}},
+		{"    #4 0x7f in std::vector<int, std::allocator<int> >::operator[](unsigned long) /usr/include/c++/12/bits/stl_vector.h:1123", StackFrame{Function: "std::vector<int, std::allocator<int> >::operator[](unsigned long)", File: "/usr/include/c++/12/bits/stl_vector.h", Line: 1123}},
+	}
+	for _, tt := range tests {
+		frames := parseFrames([]string{tt.line}, newSourceFiles(""))
+		if len(frames) != 1 || frames[0] != tt.want {
+			t.Errorf("parseFrames(%q) = %+v, want %+v", tt.line, frames, tt.want)
+		}
+	}
+}
+
+func TestParseCrashReportUBSan(t *testing.T) {
+	dir := writeCrashProject(t, map[string]string{"ub.cpp": "#include <climits>\n#include <cstdio>\nint main() {\n    int x = INT_MAX;\n    x += 1;\n    printf(\"%d\\n\", x);\n}\n"})
+	output := `ub.cpp:5:7: runtime error: signed integer overflow: 2147483647 + 1 cannot be represented in type 'int'
+    #0 0x558a210ed17e in main ` + dir + `/ub.cpp:5
+    #1 0x7fbe28245249  (/lib/x86_64-linux-gnu/libc.so.6+0x27249) (BuildId: 82ce4e6e4ef08fa58a3535f7437bd3e592db5ac0)
+    #2 0x7fbe28245304 in __libc_start_main (/lib/x86_64-linux-gnu/libc.so.6+0x27304) (BuildId: 82ce4e6e4ef08fa58a3535f7437bd3e592db5ac0)
+    #3 0x558a210ed080 in _start (` + dir + `/main+0x1080)
+`
+	r := parseCrashReport(output, errors.New("exit status 1"), dir)
+	if r == nil {
+		t.Fatal("no report")
+	}
+	if r.Tool != "UndefinedBehaviorSanitizer" || r.Kind != "signed-integer-overflow" {
+		t.Errorf("tool, kind = %q, %q", r.Tool, r.Kind)
+	}
+	if r.Message != "signed integer overflow: 2147483647 + 1 cannot be represented in type 'int'" {
+		t.Errorf("message = %q", r.Message)
+	}
+	want := StackFrame{File: "ub.cpp", Line: 5, Column: 7, Source: "x += 1;", User: true}
+	if r.Location == nil || *r.Location != want {
+		t.Errorf("location = %+v, want %+v", r.Location, want)
+	}
+	if len(r.Frames) != 4 || r.Frames[2].Function != "__libc_start_main" || r.Frames[2].Module != "/lib/x86_64-linux-gnu/libc.so.6+0x27304" {
+		t.Errorf("frames = %+v", r.Frames)
+	}
+}
+
+// A panic of Rust 1.73 and later, with the message on its own line, run
+// with RUST_BACKTRACE=1
+const rustIndexPanic = `thread 'main' panicked at main.rs:4:21:
+index out of bounds: the len is 3 but the index is 3
+stack backtrace:
+   0: __rustc::rust_begin_unwind
+             at /rustc/1159e78c4747b02ef996e55082b704c09b970588/library/std/src/panicking.rs:697:5
+   1: core::panicking::panic_fmt
+             at /rustc/1159e78c4747b02ef996e55082b704c09b970588/library/core/src/panicking.rs:75:14
+   2: core::panicking::panic_bounds_check
+             at /rustc/1159e78c4747b02ef996e55082b704c09b970588/library/core/src/panicking.rs:280:5
+   3: main::main
+             at ./main.rs:4:21
+   4: core::ops::function::FnOnce::call_once
+             at /rustc/1159e78c4747b02ef996e55082b704c09b970588/library/core/src/ops/function.rs:253:5
+note: Some details are omitted, run with ` + "`RUST_BACKTRACE=full`" + ` for a verbose backtrace.
+`
+
+func TestParseCrashReportRust(t *testing.T) {
+	dir := writeCrashProject(t, map[string]string{"main.rs": "fn main() {\n    let v = vec![1, 2, 3];\n    let i = v.len();\n    println!(\"{}\", v[i]);\n}\n"})
+
+	r := parseCrashReport(rustIndexPanic, errors.New("exit status 101"), dir)
+	if r == nil {
+		t.Fatal("no report")
+	}
+	if r.Tool != "rust" || r.Kind != "index-out-of-bounds" || r.Message != "index out of bounds: the len is 3 but the index is 3" {
+		t.Errorf("report = %q, %q, %q", r.Tool, r.Kind, r.Message)
+	}
+	want := StackFrame{File: "main.rs", Line: 4, Column: 21, Source: `println!("{}", v[i]);`, User: true}
+	if r.Location == nil || *r.Location != want {
+		t.Errorf("location = %+v, want %+v", r.Location, want)
+	}
+	if len(r.Frames) != 5 {
+		t.Fatalf("frames = %+v", r.Frames)
+	}
+	user := StackFrame{Function: "main::main", File: "main.rs", Line: 4, Column: 21, Source: `println!("{}", v[i]);`, User: true}
+	if r.Frames[3] != user || r.Frames[0].Function != "__rustc::rust_begin_unwind" || r.Frames[0].User {
+		t.Errorf("frames = %+v", r.Frames)
+	}
+
+	// Before Rust 1.73 the message was quoted on the panic line
+	old := "thread 'main' panicked at 'attempt to add with overflow', src/main.rs:3:5\nnote: run with `RUST_BACKTRACE=1` environment variable to display a backtrace\n"
+	r = parseCrashReport(old, errors.New("exit status 101"), dir)
+	if r == nil || r.Kind != "integer-overflow" || r.Message != "attempt to add with overflow" || r.Location == nil || r.Location.Line != 3 {
+		t.Errorf("old panic = %+v", r)
+	}
+}
+
+func TestParseCrashReportSignal(t *testing.T) {
+	r := parseCrashReport("", errors.New("signal: segmentation fault (core dumped)"), "")
+	if r == nil || r.Tool != "signal" || r.Kind != "SIGSEGV" {
+		t.Errorf("report = %+v", r)
+	}
+	if r := parseCrashReport("Traceback (most recent call last):\n", errors.New("exit status 1"), ""); r != nil {
+		t.Errorf("report for a plain failure = %+v", r)
+	}
+}
@@ -0,0 +1,670 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Uploaded datasets can be inspected without downloading them:
+// GET /api/problem/{id}/files/{name}/preview reads CSV, TSV, JSON lines,
+// JSON arrays of objects and Excel workbooks, and returns the first rows
+// together with a summary of every column.
+
+const (
+	defaultPreviewRows = 20
+	maxPreviewRows     = 500
+	maxPreviewScanRows = 1000000  // the summary covers at most this many rows
+	maxPreviewColumns  = 1000     // and this many columns; later ones are left out
+	maxDistinctValues  = 1000     // distinct values are counted up to this many
+	maxXLSXPartBytes   = 64 << 20 // largest workbook.xml or sharedStrings.xml read
+)
+
+// DatasetPreview is the beginning and a column summary of a dataset
+type DatasetPreview struct {
+	File     string          `json:"file"`
+	Format   string          `json:"format"`           // csv, tsv, jsonl, json or xlsx
+	Sheet    string          `json:"sheet,omitempty"`  // the previewed sheet of a workbook
+	Sheets   []string        `json:"sheets,omitempty"` // all sheets of a workbook
+	Columns  []ColumnSummary `json:"columns"`
+	Rows     [][]string      `json:"rows"`              // the first rows, one value per column
+	RowCount int             `json:"rowCount"`          // data rows, not counting the header
+	Partial  bool            `json:"partial,omitempty"` // the file has more than maxPreviewScanRows rows or maxPreviewColumns columns
+}
+
+// ColumnSummary describes the values of one column
+type ColumnSummary struct {
+	Name           string   `json:"name"`
+	Type           string   `json:"type"`  // integer, number, boolean, datetime, string or empty
+	Count          int      `json:"count"` // values that aren't null
+	Nulls          int      `json:"nulls"` // empty, NA, N/A, null, None and NaN
+	Distinct       int      `json:"distinct"`
+	DistinctCapped bool     `json:"distinctCapped,omitempty"` // there are more than maxDistinctValues
+	Min            *float64 `json:"min,omitempty"`            // integer and number columns only
+	Max            *float64 `json:"max,omitempty"`
+	Mean           *float64 `json:"mean,omitempty"`
+	StdDev         *float64 `json:"stdDev,omitempty"`
+}
+
+// datasetFormat tells how a file is previewed; "" means it can't be
+func datasetFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return "csv"
+	case ".tsv", ".tab":
+		return "tsv"
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	case ".json":
+		return "json"
+	case ".xlsx":
+		return "xlsx"
+	}
+	return ""
+}
+
+// previewUploadedFile handles GET /api/problem/{id}/files/{name}/preview
+func previewUploadedFile(w http.ResponseWriter, r *http.Request, problemID, name string) {
+	if !isValidProblemID(problemID) || !isValidUploadName(name) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	file := filepath.Join(uploadsDir(problemID), name)
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	rows := defaultPreviewRows
+	if s := r.URL.Query().Get("rows"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "rows must be a non-negative integer", 400)
+			return
+		}
+		rows = min(n, maxPreviewRows)
+	}
+
+	preview, err := previewDataset(file, rows, r.URL.Query().Get("sheet"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// previewDataset reads the first rows of a dataset and summarizes its
+// columns; sheet picks the sheet of a workbook (the first by default)
+func previewDataset(file string, rows int, sheet string) (DatasetPreview, error) {
+	format := datasetFormat(file)
+	if format == "" {
+		return DatasetPreview{}, fmt.Errorf("preview is not available for %s files; supported are .csv, .tsv, .jsonl, .json and .xlsx", filepath.Ext(file))
+	}
+	b := &previewBuilder{preview: DatasetPreview{File: filepath.Base(file), Format: format}, rows: rows}
+
+	var err error
+	if format == "xlsx" {
+		err = previewXLSX(b, file, sheet)
+	} else {
+		var f *os.File
+		if f, err = os.Open(file); err != nil {
+			return DatasetPreview{}, err
+		}
+		defer f.Close()
+		switch format {
+		case "csv":
+			err = previewCSV(b, f, ',')
+		case "tsv":
+			err = previewCSV(b, f, '\t')
+		default:
+			err = previewJSON(b, f)
+		}
+	}
+	if err != nil {
+		return DatasetPreview{}, err
+	}
+	return b.finish(), nil
+}
+
+// previewBuilder collects the rows of a dataset as they are read
+type previewBuilder struct {
+	preview DatasetPreview
+	rows    int // rows to keep for the preview
+	columns []*columnStats
+	index   map[string]int // column by name, for JSON objects
+}
+
+// setColumns names the columns from a header row
+func (b *previewBuilder) setColumns(names []string) {
+	for _, name := range names {
+		if b.addColumn(name) < 0 {
+			return
+		}
+	}
+}
+
+// addColumn adds a column and returns its index, or -1 when there are
+// maxPreviewColumns already
+func (b *previewBuilder) addColumn(name string) int {
+	if len(b.columns) >= maxPreviewColumns {
+		b.preview.Partial = true
+		return -1
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = fmt.Sprintf("column %d", len(b.columns)+1)
+	}
+	// rows read before the column showed up have no value in it
+	c := &columnStats{distinct: make(map[string]bool)}
+	c.Name = name
+	c.Nulls = b.preview.RowCount
+	b.columns = append(b.columns, c)
+	return len(b.columns) - 1
+}
+
+// addRow adds a data row and tells whether to keep reading
+func (b *previewBuilder) addRow(values []string) bool {
+	if b.preview.RowCount >= maxPreviewScanRows {
+		b.preview.Partial = true
+		return false
+	}
+	for len(b.columns) < len(values) {
+		if b.addColumn("") < 0 {
+			values = values[:len(b.columns)]
+		}
+	}
+	for i, c := range b.columns {
+		if i < len(values) {
+			c.add(values[i])
+		} else {
+			c.add("")
+		}
+	}
+	if len(b.preview.Rows) < b.rows {
+		b.preview.Rows = append(b.preview.Rows, append([]string(nil), values...))
+	}
+	b.preview.RowCount++
+	return true
+}
+
+// addObject adds a JSON object as a row; keys seen for the first time
+// become new columns, in sorted order
+func (b *previewBuilder) addObject(obj map[string]interface{}) bool {
+	if b.index == nil {
+		b.index = make(map[string]int)
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := b.index[k]; !ok {
+			// keys past maxPreviewColumns are left out
+			if i := b.addColumn(k); i >= 0 {
+				b.index[k] = i
+			}
+		}
+	}
+	values := make([]string, len(b.columns))
+	for k, v := range obj {
+		if i, ok := b.index[k]; ok {
+			values[i] = jsonValueString(v)
+		}
+	}
+	return b.addRow(values)
+}
+
+// finish summarizes the columns and pads the preview rows to their number
+func (b *previewBuilder) finish() DatasetPreview {
+	p := b.preview
+	p.Columns = make([]ColumnSummary, len(b.columns))
+	for i, c := range b.columns {
+		p.Columns[i] = c.summary()
+	}
+	if p.Rows == nil {
+		p.Rows = [][]string{}
+	}
+	for i, row := range p.Rows {
+		for len(row) < len(p.Columns) {
+			row = append(row, "")
+		}
+		p.Rows[i] = row
+	}
+	return p
+}
+
+// columnStats accumulates the values of a column
+type columnStats struct {
+	ColumnSummary
+	distinct                     map[string]bool
+	ints, numbers, bools, dates  int
+	mean, m2, minValue, maxValue float64 // of the numbers, m2 as in Welford's algorithm
+}
+
+// nullValues are the spellings of a missing value, lower-cased
+var nullValues = map[string]bool{"": true, "na": true, "n/a": true, "null": true, "none": true, "nan": true}
+
+// dateLayouts are the date and time formats a datetime column may use
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", "2006/01/02", "01/02/2006"}
+
+func (c *columnStats) add(v string) {
+	v = strings.TrimSpace(v)
+	if nullValues[strings.ToLower(v)] {
+		c.Nulls++
+		return
+	}
+	c.Count++
+	if len(c.distinct) < maxDistinctValues {
+		c.distinct[v] = true
+	} else if !c.distinct[v] {
+		c.DistinctCapped = true
+	}
+
+	if f, err := strconv.ParseFloat(v, 64); err == nil && !math.IsInf(f, 0) {
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			c.ints++
+		}
+		c.numbers++
+		if c.numbers == 1 || f < c.minValue {
+			c.minValue = f
+		}
+		if c.numbers == 1 || f > c.maxValue {
+			c.maxValue = f
+		}
+		delta := f - c.mean
+		c.mean += delta / float64(c.numbers)
+		c.m2 += delta * (f - c.mean)
+		return
+	}
+	if lower := strings.ToLower(v); lower == "true" || lower == "false" {
+		c.bools++
+		return
+	}
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, v); err == nil {
+			c.dates++
+			return
+		}
+	}
+}
+
+// summary infers the column's type from its values: the narrowest type
+// every value fits
+func (c *columnStats) summary() ColumnSummary {
+	s := c.ColumnSummary
+	s.Distinct = len(c.distinct)
+	switch {
+	case s.Count == 0:
+		s.Type = "empty"
+	case c.ints == s.Count:
+		s.Type = "integer"
+	case c.numbers == s.Count:
+		s.Type = "number"
+	case c.bools == s.Count:
+		s.Type = "boolean"
+	case c.dates == s.Count:
+		s.Type = "datetime"
+	default:
+		s.Type = "string"
+	}
+	if s.Type == "integer" || s.Type == "number" {
+		minValue, maxValue, mean := c.minValue, c.maxValue, c.mean
+		stdDev := 0.0
+		if c.numbers > 1 {
+			stdDev = math.Sqrt(c.m2 / float64(c.numbers-1))
+		}
+		s.Min, s.Max, s.Mean, s.StdDev = &minValue, &maxValue, &mean, &stdDev
+	}
+	return s
+}
+
+// jsonValueString renders a JSON value as a cell; nested values stay JSON
+func jsonValueString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// previewCSV reads comma- or tab-separated values with a header row
+func previewCSV(b *previewBuilder, r io.Reader, comma rune) error {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	b.setColumns(header)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !b.addRow(record) {
+			return nil
+		}
+	}
+}
+
+// previewJSON reads an array of objects, or a stream of objects as in JSON
+// lines
+func previewJSON(b *previewBuilder, r io.Reader) error {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\ufeff" {
+		br.Discard(3)
+	}
+	first := byte(0)
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			first = c
+			br.UnreadByte()
+			break
+		}
+	}
+
+	dec := json.NewDecoder(br)
+	dec.UseNumber()
+	inArray := first == '['
+	if inArray {
+		dec.Token()
+	}
+	for n := 1; ; n++ {
+		if inArray && !dec.More() {
+			return nil
+		}
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF && !inArray {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row %d: %v", n, err)
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("row %d is not a JSON object", n)
+		}
+		if !b.addObject(obj) {
+			return nil
+		}
+	}
+}
+
+// xlsxSheet is a worksheet of a workbook and the zip entry holding it
+type xlsxSheet struct {
+	Name string
+	Part string
+}
+
+// previewXLSX reads a sheet of an Excel workbook; its first row is the
+// header. Dates are stored as serial numbers and read as such.
+func previewXLSX(b *previewBuilder, file, sheet string) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return fmt.Errorf("not a valid .xlsx workbook: %v", err)
+	}
+	defer zr.Close()
+
+	sheets, err := xlsxSheets(&zr.Reader)
+	if err != nil {
+		return err
+	}
+	if len(sheets) == 0 {
+		return fmt.Errorf("the workbook has no sheets")
+	}
+	chosen := sheets[0]
+	for _, s := range sheets {
+		b.preview.Sheets = append(b.preview.Sheets, s.Name)
+		if sheet != "" && s.Name == sheet {
+			chosen = s
+		}
+	}
+	if sheet != "" && chosen.Name != sheet {
+		return fmt.Errorf("the workbook has no sheet %q", sheet)
+	}
+	b.preview.Sheet = chosen.Name
+
+	shared, err := xlsxSharedStrings(&zr.Reader)
+	if err != nil {
+		return err
+	}
+	f, err := openZipEntry(&zr.Reader, chosen.Part)
+	if err != nil {
+		return fmt.Errorf("sheet %q: %v", chosen.Name, err)
+	}
+	defer f.Close()
+
+	dec := xml.NewDecoder(f)
+	header := true
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("sheet %q: %v", chosen.Name, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row xlsxRow
+		if err := dec.DecodeElement(&row, &start); err != nil {
+			return fmt.Errorf("sheet %q: %v", chosen.Name, err)
+		}
+		values := row.values(shared)
+		if len(values) == 0 {
+			continue
+		}
+		if header {
+			b.setColumns(values)
+			header = false
+		} else if !b.addRow(values) {
+			return nil
+		}
+	}
+}
+
+type xlsxRow struct {
+	Cells []struct {
+		Ref    string       `xml:"r,attr"`
+		Type   string       `xml:"t,attr"`
+		Value  string       `xml:"v"`
+		Inline xlsxRichText `xml:"is"`
+	} `xml:"c"`
+}
+
+// xlsxRichText is a string that may be split into formatted runs
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxRichText) String() string {
+	s := t.Text
+	for _, r := range t.Runs {
+		s += r.Text
+	}
+	return s
+}
+
+// values returns the cells of a row by column, "" for missing ones
+func (row xlsxRow) values(shared []string) []string {
+	var values []string
+	for i, c := range row.Cells {
+		col := xlsxColumn(c.Ref)
+		if col < 0 {
+			col = i
+		}
+		var v string
+		switch c.Type {
+		case "s":
+			if n, err := strconv.Atoi(c.Value); err == nil && n >= 0 && n < len(shared) {
+				v = shared[n]
+			}
+		case "inlineStr":
+			v = c.Inline.String()
+		case "b":
+			v = strconv.FormatBool(c.Value == "1")
+		default:
+			v = c.Value
+		}
+		if v == "" {
+			continue
+		}
+		for len(values) <= col {
+			values = append(values, "")
+		}
+		values[col] = v
+	}
+	return values
+}
+
+// xlsxColumn returns the column index of a cell reference such as "AB12",
+// or -1 when there is none
+func xlsxColumn(ref string) int {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return -1
+	}
+	return col - 1
+}
+
+// xlsxSheets lists the sheets of a workbook in order
+func xlsxSheets(zr *zip.Reader) ([]xlsxSheet, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := readZipXML(zr, "xl/workbook.xml", &workbook); err != nil {
+		return nil, fmt.Errorf("not a valid .xlsx workbook: %v", err)
+	}
+	if err := readZipXML(zr, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, fmt.Errorf("not a valid .xlsx workbook: %v", err)
+	}
+	targets := make(map[string]string)
+	for _, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+	var sheets []xlsxSheet
+	for _, s := range workbook.Sheets {
+		if part, ok := targets[s.ID]; ok {
+			sheets = append(sheets, xlsxSheet{Name: s.Name, Part: part})
+		}
+	}
+	return sheets, nil
+}
+
+// xlsxSharedStrings reads the string table cells of type "s" index into
+func xlsxSharedStrings(zr *zip.Reader) ([]string, error) {
+	f, err := openZipEntry(zr, "xl/sharedStrings.xml")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var shared []string
+	dec := xml.NewDecoder(io.LimitReader(f, maxXLSXPartBytes))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return shared, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("shared strings: %v", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "si" {
+			var si xlsxRichText
+			if err := dec.DecodeElement(&si, &start); err != nil {
+				return nil, fmt.Errorf("shared strings: %v", err)
+			}
+			shared = append(shared, si.String())
+		}
+	}
+}
+
+func openZipEntry(zr *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f.Open()
+		}
+	}
+	return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
+
+func readZipXML(zr *zip.Reader, name string, v interface{}) error {
+	f, err := openZipEntry(zr, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(io.LimitReader(f, maxXLSXPartBytes)).Decode(v)
+}
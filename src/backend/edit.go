@@ -0,0 +1,546 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Problems are edited in place through PUT and PATCH /api/problems/{id}.
+// Every representation carries an ETag derived from the manifest, and
+// edits must send it back in If-Match so that two editors working from the
+// same version can't silently overwrite each other.
+//
+//	PUT   replaces every manifest field (ID excepted)
+//	PATCH application/merge-patch+json (RFC 7396, also the default for
+//	      application/json) or application/json-patch+json (RFC 6902)
+
+const maxManifestBytes = 8 << 20
+
+var (
+	errInvalidPatch         = errors.New("invalid patch")
+	errUnsupportedPatchType = errors.New("PATCH supports application/merge-patch+json and application/json-patch+json")
+
+	// manifestMu serializes read-modify-write cycles on manifests
+	manifestMu sync.Mutex
+)
+
+// etagFor returns a strong entity tag for the JSON form of v
+func etagFor(v interface{}) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("\"%x\"", sum[:8])
+}
+
+// problemETag returns the entity tag of a problem's manifest
+func problemETag(p Problem) string {
+	return etagFor(p)
+}
+
+// etagMatches evaluates an If-Match/If-None-Match header against etag
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// saveProblemManifest writes a manifest atomically (temp file and rename)
+// and refreshes the index
+func saveProblemManifest(p Problem) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+	dir := filepath.Join(dataDir, p.ID)
+	tmp, err := os.CreateTemp(dir, ".manifest-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	os.Chmod(tmp.Name(), 0644)
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, "manifest.json")); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	problemCache.invalidate(p.ID)
+	return nil
+}
+
+// validateProblemEdit normalizes an edited manifest and rejects values the
+// rest of the backend can't handle
+func validateProblemEdit(p *Problem) error {
+	p.Title = strings.TrimSpace(p.Title)
+	if p.Title == "" {
+		return fmt.Errorf("Title must not be empty")
+	}
+	switch p.Type {
+	case "", "coding", "system_design":
+	default:
+		return fmt.Errorf("Type must be coding or system_design")
+	}
+	if p.Difficulty != "" {
+		d := normalizeDifficulty(p.Difficulty)
+		if d == "" {
+			return fmt.Errorf("unknown Difficulty %q", p.Difficulty)
+		}
+		p.Difficulty = d
+	}
+	p.Tags = normalizeTags(p.Tags)
+	if p.EstimatedMinutes < 0 || p.TimeLimitMs < 0 || p.MemoryLimitMB < 0 {
+		return fmt.Errorf("EstimatedMinutes, TimeLimitMs and MemoryLimitMB must not be negative")
+	}
+	for lang, l := range p.LanguageLimits {
+		if l.TimeLimitMs < 0 || l.MemoryLimitMB < 0 {
+			return fmt.Errorf("LanguageLimits.%s must not be negative", lang)
+		}
+	}
+	seen := make(map[int]bool)
+	for i, part := range p.Parts {
+		if part.PartNumber <= 0 {
+			part.PartNumber = i + 2 // Part 1 is the main statement
+			p.Parts[i].PartNumber = part.PartNumber
+		}
+		if seen[part.PartNumber] {
+			return fmt.Errorf("duplicate partNumber %d", part.PartNumber)
+		}
+		seen[part.PartNumber] = true
+	}
+	if len(p.Parts) > 0 {
+		p.IsMultiPart = true
+	}
+	p.Examples = normalizeExamples(p.Examples)
+	constraints, err := normalizeConstraints(p.Constraints)
+	if err != nil {
+		return err
+	}
+	p.Constraints = constraints
+	if err := checkValidator(p.Validator); err != nil {
+		return err
+	}
+	if err := checkTestGroups(p.TestGroups); err != nil {
+		return err
+	}
+	if err := checkBuildProfiles(p.BuildProfiles); err != nil {
+		return err
+	}
+	p.SampleTests = nil
+	p.AvailableLanguages = nil
+	return validateProblemLocales(p)
+}
+
+// ensurePartDirs creates the test directories of parts added by an edit
+func ensurePartDirs(p Problem) {
+	for _, part := range p.Parts {
+		dir := filepath.Join(dataDir, p.ID, "v1", fmt.Sprintf("part%d", part.PartNumber), "public")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Printf("Failed to create part%d directory for %s: %v", part.PartNumber, p.ID, err)
+		}
+	}
+}
+
+// editProblem handles PUT and PATCH /api/problems/{id}
+func editProblem(w http.ResponseWriter, r *http.Request, problemID string) {
+	if decoded, err := url.QueryUnescape(problemID); err == nil {
+		problemID = decoded
+	}
+	if !isValidProblemID(problemID) {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxManifestBytes+1))
+	if err != nil {
+		http.Error(w, "Failed to read request body", 400)
+		return
+	}
+	if len(body) > maxManifestBytes {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	current, ok := readProblemManifest(dataDir, problemID)
+	if !ok {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+	etag := problemETag(current)
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		w.Header().Set("ETag", etag)
+		http.Error(w, "If-Match header required; fetch the problem to get its ETag", http.StatusPreconditionRequired)
+		return
+	}
+	if !etagMatches(ifMatch, etag) {
+		w.Header().Set("ETag", etag)
+		http.Error(w, "Problem was modified by someone else; reload it and retry", http.StatusPreconditionFailed)
+		return
+	}
+
+	var updated Problem
+	if r.Method == http.MethodPut {
+		dec := json.NewDecoder(strings.NewReader(string(body)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&updated); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), 400)
+			return
+		}
+	} else {
+		updated, err = patchProblem(current, body, r.Header.Get("Content-Type"))
+		if err != nil {
+			status := 400
+			switch {
+			case errors.Is(err, errUnsupportedPatchType):
+				status = http.StatusUnsupportedMediaType
+			case errors.Is(err, errInvalidPatch):
+				status = http.StatusUnprocessableEntity
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+
+	if updated.ID != "" && updated.ID != current.ID {
+		err = fmt.Errorf("ID cannot be changed")
+	} else {
+		updated.ID = current.ID
+		err = validateProblemEdit(&updated)
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+		return
+	}
+
+	if !reflect.DeepEqual(current, updated) {
+		if err := saveProblemManifest(updated); err != nil {
+			log.Printf("Failed to save problem %s: %v", problemID, err)
+			http.Error(w, "Failed to save problem", http.StatusInternalServerError)
+			return
+		}
+		ensurePartDirs(updated)
+		if !reflect.DeepEqual(current.Examples, updated.Examples) {
+			if err := syncExampleTests(updated, current.Examples); err != nil {
+				log.Printf("Failed to sync examples of %s: %v", problemID, err)
+			}
+		}
+		removeDroppedTestGroups(current, updated)
+		recordAudit(r, "problem.update", problemID, current, updated, nil)
+		log.Printf("Updated problem %s", problemID)
+	}
+
+	w.Header().Set("ETag", problemETag(updated))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// patchProblem applies a merge patch or JSON patch to a problem
+func patchProblem(p Problem, patch []byte, contentType string) (Problem, error) {
+	mediaType := "application/merge-patch+json"
+	if contentType != "" {
+		mt, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return p, errUnsupportedPatchType
+		}
+		mediaType = mt
+	}
+
+	doc := toJSONValue(p)
+	var err error
+	switch mediaType {
+	case "application/merge-patch+json", "application/json":
+		var mp interface{}
+		if err := json.Unmarshal(patch, &mp); err != nil {
+			return p, fmt.Errorf("invalid JSON: %v", err)
+		}
+		if _, ok := mp.(map[string]interface{}); !ok {
+			return p, fmt.Errorf("%w: merge patch must be a JSON object", errInvalidPatch)
+		}
+		doc = mergePatch(doc, mp)
+	case "application/json-patch+json":
+		var ops []jsonPatchOp
+		if err := json.Unmarshal(patch, &ops); err != nil {
+			return p, fmt.Errorf("invalid JSON patch: %v", err)
+		}
+		if doc, err = applyJSONPatch(doc, ops); err != nil {
+			return p, err
+		}
+	default:
+		return p, errUnsupportedPatchType
+	}
+
+	data, _ := json.Marshal(doc)
+	var out Problem
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&out); err != nil {
+		return p, fmt.Errorf("%w: %v", errInvalidPatch, err)
+	}
+	return out, nil
+}
+
+// mergePatch applies an RFC 7396 merge patch: objects merge recursively,
+// null removes a member, anything else replaces the target
+func mergePatch(target, patch interface{}) interface{} {
+	pm, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	tm, ok := target.(map[string]interface{})
+	if !ok {
+		tm = make(map[string]interface{})
+	}
+	for k, v := range pm {
+		if v == nil {
+			delete(tm, k)
+		} else {
+			tm[k] = mergePatch(tm[k], v)
+		}
+	}
+	return tm
+}
+
+// jsonPatchOp is one RFC 6902 operation
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// applyJSONPatch applies the operations in order; the document is left
+// untouched by the caller when any of them fails
+func applyJSONPatch(doc interface{}, ops []jsonPatchOp) (interface{}, error) {
+	for i, op := range ops {
+		var err error
+		var value interface{}
+		if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: operation %d (%s) needs a value", errInvalidPatch, i, op.Op)
+			}
+			json.Unmarshal(op.Value, &value)
+		}
+		switch op.Op {
+		case "add":
+			doc, err = jsonPointerAdd(doc, op.Path, value)
+		case "remove":
+			doc, _, err = jsonPointerRemove(doc, op.Path)
+		case "replace":
+			if doc, _, err = jsonPointerRemove(doc, op.Path); err == nil {
+				doc, err = jsonPointerAdd(doc, op.Path, value)
+			}
+		case "move":
+			var moved interface{}
+			if doc, moved, err = jsonPointerRemove(doc, op.From); err == nil {
+				doc, err = jsonPointerAdd(doc, op.Path, moved)
+			}
+		case "copy":
+			var copied interface{}
+			if copied, err = jsonPointerGet(doc, op.From); err == nil {
+				doc, err = jsonPointerAdd(doc, op.Path, toJSONValue(copied))
+			}
+		case "test":
+			var actual interface{}
+			if actual, err = jsonPointerGet(doc, op.Path); err == nil && !reflect.DeepEqual(actual, value) {
+				err = fmt.Errorf("test failed at %s", op.Path)
+			}
+		default:
+			err = fmt.Errorf("unknown op %q", op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", errInvalidPatch, i, err)
+		}
+	}
+	return doc, nil
+}
+
+func splitJSONPointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("path %q must start with /", ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token; "-" (append) is only allowed
+// when allowEnd is set
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	n, err := strconv.Atoi(token)
+	if err != nil || n < 0 || n > length || (n == length && !allowEnd) {
+		return 0, fmt.Errorf("array index %q out of range", token)
+	}
+	return n, nil
+}
+
+func jsonPointerGet(doc interface{}, ptr string) (interface{}, error) {
+	tokens, err := splitJSONPointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	cur := doc
+	for _, t := range tokens {
+		switch c := cur.(type) {
+		case map[string]interface{}:
+			v, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("path %s not found", ptr)
+			}
+			cur = v
+		case []interface{}:
+			i, err := arrayIndex(t, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			cur = c[i]
+		default:
+			return nil, fmt.Errorf("path %s not found", ptr)
+		}
+	}
+	return cur, nil
+}
+
+// jsonPointerAdd returns doc with value added at ptr
+func jsonPointerAdd(doc interface{}, ptr string, value interface{}) (interface{}, error) {
+	tokens, err := splitJSONPointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	return addAt(doc, tokens, value)
+}
+
+func addAt(node interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	t, rest := tokens[0], tokens[1:]
+	switch c := node.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			c[t] = value
+			return c, nil
+		}
+		// A missing member is created when only its last level is new, so
+		// "/Parts/-" works on a manifest that omits an empty Parts
+		child, ok := c[t]
+		if !ok && len(rest) > 1 {
+			return nil, fmt.Errorf("member %q not found", t)
+		}
+		v, err := addAt(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		c[t] = v
+		return c, nil
+	case []interface{}:
+		i, err := arrayIndex(t, len(c), len(rest) == 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+		v, err := addAt(c[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = v
+		return c, nil
+	case nil:
+		if t == "-" || t == "0" {
+			return addAt([]interface{}{}, tokens, value)
+		}
+		return addAt(map[string]interface{}{}, tokens, value)
+	default:
+		return nil, fmt.Errorf("cannot add below a scalar at %q", t)
+	}
+}
+
+// jsonPointerRemove returns doc without the value at ptr, and that value
+func jsonPointerRemove(doc interface{}, ptr string) (interface{}, interface{}, error) {
+	tokens, err := splitJSONPointer(ptr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, doc, nil
+	}
+	return removeAt(doc, tokens)
+}
+
+func removeAt(node interface{}, tokens []string) (interface{}, interface{}, error) {
+	t, rest := tokens[0], tokens[1:]
+	switch c := node.(type) {
+	case map[string]interface{}:
+		child, ok := c[t]
+		if !ok {
+			return nil, nil, fmt.Errorf("member %q not found", t)
+		}
+		if len(rest) == 0 {
+			delete(c, t)
+			return c, child, nil
+		}
+		v, removed, err := removeAt(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		c[t] = v
+		return c, removed, nil
+	case []interface{}:
+		i, err := arrayIndex(t, len(c), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := c[i]
+			return append(c[:i], c[i+1:]...), removed, nil
+		}
+		v, removed, err := removeAt(c[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		c[i] = v
+		return c, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("member %q not found", t)
+	}
+}
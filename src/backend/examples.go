@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Manifests carry the examples shown in the statement and the input
+// constraints as structured data:
+//
+//	"Examples":    [{"input": "3\n1 2 3", "output": "6", "explanation": "..."}]
+//	"Constraints": ["1 ≤ n ≤ 10^5", {"vars": ["k"], "min": 0, "max": 100000}]
+//
+// Example inputs and outputs are exactly what a solution reads and prints,
+// and are kept in sync with the first public test cases. Constraints may be
+// written as text; bounds are parsed out of it when it has the usual
+// "lo ≤ x ≤ hi" shape.
+
+const maxExampleBytes = 4 << 10 // larger sample tests are not turned into examples on import
+
+// Example is a worked example from the problem statement
+type Example struct {
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// Constraint bounds one or more input quantities; Min and Max are inclusive.
+// Constraints without bounds (e.g. "nums is sorted") only carry Text.
+type Constraint struct {
+	Vars []string `json:"vars,omitempty"` // e.g. "n", "nums[i]", "s.length"
+	Min  *int64   `json:"min,omitempty"`
+	Max  *int64   `json:"max,omitempty"`
+	Text string   `json:"text,omitempty"`
+}
+
+// UnmarshalJSON accepts a constraint as plain text or as an object whose
+// bounds may be numbers (1e5 included) or expressions such as "2^31 - 1"
+func (c *Constraint) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = parseConstraint(text)
+		return nil
+	}
+
+	var raw struct {
+		Vars []string    `json:"vars"`
+		Var  string      `json:"var"` // single-variable shorthand
+		Min  interface{} `json:"min"`
+		Max  interface{} `json:"max"`
+		Text string      `json:"text"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	if len(raw.Vars) == 0 && raw.Var != "" {
+		raw.Vars = []string{raw.Var}
+	}
+	if len(raw.Vars) == 0 && raw.Min == nil && raw.Max == nil {
+		*c = parseConstraint(raw.Text)
+		return nil
+	}
+
+	*c = Constraint{Vars: raw.Vars, Text: strings.TrimSpace(raw.Text)}
+	for _, b := range []struct {
+		name string
+		v    interface{}
+		dst  **int64
+	}{{"min", raw.Min, &c.Min}, {"max", raw.Max, &c.Max}} {
+		if b.v == nil {
+			continue
+		}
+		n, err := evalBound(fmt.Sprint(b.v))
+		if err != nil {
+			return fmt.Errorf("constraint %s: %v", b.name, err)
+		}
+		*b.dst = &n
+	}
+	return nil
+}
+
+// Allows reports whether v satisfies the bounds
+func (c Constraint) Allows(v int64) bool {
+	return (c.Min == nil || v >= *c.Min) && (c.Max == nil || v <= *c.Max)
+}
+
+// String renders the constraint the way statements write it
+func (c Constraint) String() string {
+	if c.Text != "" {
+		return c.Text
+	}
+	s := strings.Join(c.Vars, ", ")
+	if c.Min != nil {
+		s = fmt.Sprintf("%d ≤ %s", *c.Min, s)
+	}
+	if c.Max != nil {
+		s = fmt.Sprintf("%s ≤ %d", s, *c.Max)
+	}
+	return s
+}
+
+var (
+	constraintOpRe = regexp.MustCompile(`<=|>=|≤|≥|⩽|⩾|<|>`)
+	// boundSymbols maps the minus and times signs of typeset statements to ASCII
+	boundSymbols = strings.NewReplacer("−", "-", "–", "-", "×", "*", "·", "*", "⋅", "*")
+)
+
+// parseConstraint reads bounds out of text such as "1 <= n <= 10^5",
+// "-10^4 < nums[i], target < 10^4" or "k ≥ 0". Text it can't parse is kept
+// as a bound-less constraint.
+func parseConstraint(text string) Constraint {
+	text = strings.TrimSpace(text)
+	for _, bullet := range []string{"- ", "* ", "• "} {
+		text = strings.TrimSpace(strings.TrimPrefix(text, bullet))
+	}
+	c := Constraint{Text: text}
+
+	norm := boundSymbols.Replace(text)
+	ops := constraintOpRe.FindAllString(norm, -1)
+	parts := constraintOpRe.Split(norm, -1)
+	if len(ops) == 0 || len(ops) > 2 {
+		return c
+	}
+	less := func(op string) bool { return strings.ContainsAny(op, "<≤⩽") }
+	strict := func(op string) bool { return op == "<" || op == ">" }
+	for _, op := range ops[1:] {
+		if less(op) != less(ops[0]) {
+			return c // "a < x > b" is not a range
+		}
+	}
+
+	// Normalize to lo op vars op hi
+	if !less(ops[0]) {
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+		for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+			ops[i], ops[j] = ops[j], ops[i]
+		}
+	}
+
+	var lo, hi *int64
+	var vars string
+	bound := func(s, op string, delta int64) (*int64, bool) {
+		n, err := evalBound(s)
+		if err != nil {
+			return nil, false
+		}
+		if strict(op) {
+			n += delta
+		}
+		return &n, true
+	}
+	var ok bool
+	switch len(parts) {
+	case 3:
+		vars = parts[1]
+		if lo, ok = bound(parts[0], ops[0], 1); !ok {
+			return c
+		}
+		if hi, ok = bound(parts[2], ops[1], -1); !ok {
+			return c
+		}
+	case 2:
+		if n, loOK := bound(parts[0], ops[0], 1); loOK {
+			lo, vars = n, parts[1]
+		} else if n, hiOK := bound(parts[1], ops[0], -1); hiOK {
+			hi, vars = n, parts[0]
+		} else {
+			return c
+		}
+	}
+
+	for _, v := range strings.Split(vars, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			c.Vars = append(c.Vars, v)
+		}
+	}
+	if len(c.Vars) == 0 {
+		return c
+	}
+	c.Min, c.Max = lo, hi
+	return c
+}
+
+// evalBound evaluates an integer bound such as "10^5", "1e5", "2 * 10^5",
+// "2·10^5", "10**9 + 7", "100,000" or "2^31 - 1"
+func evalBound(s string) (int64, error) {
+	p := &boundParser{s: strings.TrimSpace(boundSymbols.Replace(s))}
+	if p.s == "" {
+		return 0, fmt.Errorf("empty bound")
+	}
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return 0, fmt.Errorf("unexpected %q in bound %q", p.s[p.pos:], s)
+	}
+	if !v.IsInt64() {
+		return 0, fmt.Errorf("bound %q does not fit in 64 bits", s)
+	}
+	return v.Int64(), nil
+}
+
+// boundParser is a recursive-descent parser for + - * / ^ (or **) and
+// parentheses over arbitrary-precision integers
+type boundParser struct {
+	s   string
+	pos int
+}
+
+func (p *boundParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *boundParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *boundParser) expr() (*big.Int, error) {
+	v, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case '+', '-':
+			op := p.s[p.pos]
+			p.pos++
+			r, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			if op == '+' {
+				v.Add(v, r)
+			} else {
+				v.Sub(v, r)
+			}
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *boundParser) term() (*big.Int, error) {
+	v, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case '*', '/':
+			op := p.s[p.pos]
+			p.pos++
+			r, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			if op == '*' {
+				v.Mul(v, r)
+			} else if r.Sign() == 0 {
+				return nil, fmt.Errorf("division by zero")
+			} else {
+				v.Quo(v, r)
+			}
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *boundParser) unary() (*big.Int, error) {
+	if p.peek() == '-' {
+		p.pos++
+		v, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return v.Neg(v), nil
+	}
+	return p.power()
+}
+
+func (p *boundParser) power() (*big.Int, error) {
+	base, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.peek() == '^':
+		p.pos++
+	case strings.HasPrefix(p.s[p.pos:], "**"):
+		p.pos += 2
+	default:
+		return base, nil
+	}
+	exp, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if exp.Sign() < 0 || exp.Cmp(big.NewInt(128)) > 0 {
+		return nil, fmt.Errorf("exponent %s out of range", exp)
+	}
+	return base.Exp(base, exp, nil), nil
+}
+
+var boundNumberRe = regexp.MustCompile(`^(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d+))?(?:[eE]\+?(\d+))?`)
+
+func (p *boundParser) atom() (*big.Int, error) {
+	if p.peek() == '(' {
+		p.pos++
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) in %q", p.s)
+		}
+		p.pos++
+		return v, nil
+	}
+
+	m := boundNumberRe.FindStringSubmatch(p.s[p.pos:])
+	if m == nil {
+		return nil, fmt.Errorf("expected a number in %q", p.s)
+	}
+	p.pos += len(m[0])
+
+	// 1.5e5 is 15 * 10^4; the fraction must vanish at the given exponent
+	digits := strings.ReplaceAll(m[1], ",", "") + m[2]
+	exp := 0
+	if m[3] != "" {
+		fmt.Sscan(m[3], &exp)
+	}
+	exp -= len(m[2])
+	if exp < 0 {
+		if !strings.HasSuffix(digits, strings.Repeat("0", -exp)) {
+			return nil, fmt.Errorf("%s is not an integer", m[0])
+		}
+		digits = digits[:len(digits)+exp]
+		exp = 0
+	}
+	v, _ := new(big.Int).SetString(digits, 10)
+	if exp > 0 {
+		v.Mul(v, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+	}
+	return v, nil
+}
+
+// normalizeExamples trims trailing whitespace from example fields
+func normalizeExamples(examples []Example) []Example {
+	for i := range examples {
+		examples[i].Output = strings.TrimRight(examples[i].Output, " \t\r\n")
+		examples[i].Explanation = strings.TrimSpace(examples[i].Explanation)
+	}
+	return examples
+}
+
+// normalizeConstraints fills in the bounds of text-only constraints and
+// drops empty ones
+func normalizeConstraints(constraints []Constraint) ([]Constraint, error) {
+	out := constraints[:0]
+	for _, c := range constraints {
+		if len(c.Vars) == 0 && c.Min == nil && c.Max == nil {
+			if strings.TrimSpace(c.Text) == "" {
+				continue
+			}
+			c = parseConstraint(c.Text)
+		}
+		if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+			return nil, fmt.Errorf("constraint %q has min greater than max", c.String())
+		}
+		if (c.Min != nil || c.Max != nil) && len(c.Vars) == 0 {
+			return nil, fmt.Errorf("constraint %q bounds no variable", c.String())
+		}
+		out = append(out, c)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// sameTestData compares test files the way the judge compares output,
+// ignoring trailing whitespace
+func sameTestData(a, b string) bool {
+	return strings.TrimRight(a, " \t\r\n") == strings.TrimRight(b, " \t\r\n")
+}
+
+// syncExampleTests makes a problem's examples its first public test cases,
+// flagged as samples. A test equal to the current or previous i-th example
+// becomes the test of the i-th example, so that an edited example replaces
+// its old test instead of piling up; the tests keep their names and
+// metadata. System design problems and problems without examples are left
+// alone.
+func syncExampleTests(p Problem, previous []Example) error {
+	if p.Type == "system_design" || len(p.Examples) == 0 {
+		return nil
+	}
+
+	publicDir, err := ensurePublicTestDir(p.ID)
+	if err != nil {
+		return err
+	}
+
+	existing := readStoredTests(publicDir)
+	exampleOf := func(tc storedTest) int {
+		for i, ex := range p.Examples {
+			if sameTestData(tc.Input, ex.Input) && sameTestData(tc.Output, ex.Output) {
+				return i
+			}
+		}
+		for i, ex := range previous {
+			if sameTestData(tc.Input, ex.Input) && sameTestData(tc.Output, ex.Output) {
+				if i < len(p.Examples) {
+					return i
+				}
+				return len(p.Examples) // example was removed
+			}
+		}
+		return -1
+	}
+
+	tests := make([]storedTest, len(p.Examples), len(p.Examples)+len(existing))
+	var others []storedTest
+	for _, tc := range existing {
+		i := exampleOf(tc)
+		switch {
+		case i < 0:
+			others = append(others, tc)
+		case i < len(p.Examples) && tests[i].Name == "":
+			tests[i].Name, tests[i].Meta = tc.Name, tc.Meta
+		}
+	}
+	for i, ex := range p.Examples {
+		tests[i].TestCase = TestCase{Input: ex.Input, Output: ex.Output}
+		tests[i].Meta.Sample = true
+		if tests[i].Meta.Title == "" {
+			tests[i].Meta.Title = exampleTestName(i)
+		}
+	}
+	tests = append(tests, others...)
+	nameStoredTests(tests)
+
+	if reflect.DeepEqual(tests, existing) {
+		return nil
+	}
+	return writeStoredTests(publicDir, tests)
+}
+
+// examplesFromSamples turns the sample tests of an imported package into
+// examples; binary or very large samples are skipped
+func examplesFromSamples(tests []importedTest) []Example {
+	var examples []Example
+	for _, tc := range tests {
+		if !tc.Sample || len(tc.Input) > maxExampleBytes || len(tc.Output) > maxExampleBytes {
+			continue
+		}
+		if bytes.IndexByte(tc.Input, 0) >= 0 || bytes.IndexByte(tc.Output, 0) >= 0 {
+			continue
+		}
+		examples = append(examples, Example{Input: string(tc.Input), Output: string(tc.Output)})
+	}
+	return examples
+}
+
+// sampleCount is the number of leading public tests shown in the statement
+func sampleCount(p Problem) int {
+	return max(1, len(p.Examples))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEvalBound(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int64
+	}{
+		{"100000", 100000},
+		{"10^5", 100000},
+		{"1e5", 100000},
+		{"1E+5", 100000},
+		{"1.5e5", 150000},
+		{"2 * 10^5", 200000},
+		{"2·10^5", 200000},
+		{"2×10^5", 200000},
+		{"10**9+7", 1000000007},
+		{"10 ** 9 + 7", 1000000007},
+		{"100,000", 100000},
+		{"2^31 - 1", 2147483647},
+		{"−2^31", -2147483648},
+		{"-10^4", -10000},
+		{"(10^9 + 7) / 2", 500000003},
+		{"2^63 - 1", 9223372036854775807},
+	}
+	for _, tt := range tests {
+		got, err := evalBound(tt.s)
+		if err != nil || got != tt.want {
+			t.Errorf("evalBound(%q) = %d, %v, want %d", tt.s, got, err, tt.want)
+		}
+	}
+
+	for _, s := range []string{"", "n", "10^", "1.5e0", "2^63", "1 / 0", "(10^5", "10^5 n", "10^200"} {
+		if got, err := evalBound(s); err == nil {
+			t.Errorf("evalBound(%q) = %d, want an error", s, got)
+		}
+	}
+}
+
+func ptr(n int64) *int64 { return &n }
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		text string
+		want Constraint
+	}{
+		{"1 <= n <= 10^5", Constraint{Vars: []string{"n"}, Min: ptr(1), Max: ptr(100000)}},
+		{"1 ≤ n ≤ 1e5", Constraint{Vars: []string{"n"}, Min: ptr(1), Max: ptr(100000)}},
+		{"1 ≤ n ≤ 2·10^5", Constraint{Vars: []string{"n"}, Min: ptr(1), Max: ptr(200000)}},
+		{"0 <= a[i] < 10**9+7", Constraint{Vars: []string{"a[i]"}, Min: ptr(0), Max: ptr(1000000006)}},
+		{"-10^4 < nums[i], target < 10^4", Constraint{Vars: []string{"nums[i]", "target"}, Min: ptr(-9999), Max: ptr(9999)}},
+		{"−10^9 ≤ x ≤ 10^9", Constraint{Vars: []string{"x"}, Min: ptr(-1000000000), Max: ptr(1000000000)}},
+		{"10^5 >= n >= 1", Constraint{Vars: []string{"n"}, Min: ptr(1), Max: ptr(100000)}},
+		{"k ≥ 0", Constraint{Vars: []string{"k"}, Min: ptr(0)}},
+		{"n <= 100,000", Constraint{Vars: []string{"n"}, Max: ptr(100000)}},
+		{"- 1 <= q <= 2^31 - 1", Constraint{Vars: []string{"q"}, Min: ptr(1), Max: ptr(2147483647)}},
+		{"s consists of lowercase letters", Constraint{}},
+		{"a < x > b", Constraint{}},
+		{"1 <= n <= m", Constraint{}},
+	}
+	for _, tt := range tests {
+		got := parseConstraint(tt.text)
+		tt.want.Text = got.Text
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseConstraint(%q) = %s, want %s", tt.text, dumpConstraint(got), dumpConstraint(tt.want))
+		}
+	}
+}
+
+// dumpConstraint shows the bounds themselves rather than their addresses
+func dumpConstraint(c Constraint) string {
+	data, _ := json.Marshal(struct {
+		Vars     []string
+		Min, Max *int64
+	}{c.Vars, c.Min, c.Max})
+	return string(data)
+}
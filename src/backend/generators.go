@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Large tests are generated rather than written by hand. A problem keeps
+// generator programs in v1/generators/{name}.{ext} and lists test groups in
+// its manifest; each group names a generator, how many tests to make, a
+// seed and free-form parameters. POST /api/problems/{id}/generate-tests
+// runs the generator once per test, checks the inputs with the validator,
+// takes the expected outputs from the reference solutions and stores the
+// tests as v1/public/{group}-NN.in/.out next to the hand-written ones.
+//
+// A generator reads its arguments on stdin as "key=value" lines: seed
+// (the group seed plus the test index minus one), index (1-based), count
+// and the group's parameters sorted by key. It prints one test input.
+
+const (
+	generatorsDirName       = "generators"
+	maxTestGroupSize        = 100
+	maxGeneratedInputBytes  = 64 << 20
+	maxGeneratedInputsBytes = 256 << 20 // all tests of one group
+)
+
+var paramNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TestGroup is a set of generated tests
+type TestGroup struct {
+	Name      string                 `json:"name"`
+	Generator string                 `json:"generator"`
+	Count     int                    `json:"count"`
+	Seed      int64                  `json:"seed,omitempty"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+}
+
+// GroupReport is the result of generating one test group
+type GroupReport struct {
+	Group   string   `json:"group"`
+	Tests   []string `json:"tests"`
+	Bytes   int64    `json:"bytes"`
+	Written bool     `json:"written"`
+	OutputReport
+}
+
+// checkTestGroups rejects test groups the backend can't generate
+func checkTestGroups(groups []TestGroup) error {
+	seen := make(map[string]bool)
+	for i, g := range groups {
+		if !solutionNameRe.MatchString(g.Name) {
+			return fmt.Errorf("TestGroups[%d].name %q is invalid", i, g.Name)
+		}
+		if seen[g.Name] {
+			return fmt.Errorf("duplicate test group %q", g.Name)
+		}
+		seen[g.Name] = true
+		if !solutionNameRe.MatchString(g.Generator) {
+			return fmt.Errorf("TestGroups[%d].generator %q is invalid", i, g.Generator)
+		}
+		if g.Count < 1 || g.Count > maxTestGroupSize {
+			return fmt.Errorf("TestGroups[%d].count must be between 1 and %d", i, maxTestGroupSize)
+		}
+		for key, v := range g.Params {
+			if !paramNameRe.MatchString(key) || key == "seed" || key == "index" || key == "count" {
+				return fmt.Errorf("TestGroups[%d].params: invalid name %q", i, key)
+			}
+			if _, err := formatParam(v); err != nil {
+				return fmt.Errorf("TestGroups[%d].params.%s: %v", i, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// formatParam renders a parameter value for the generator; numbers without
+// a fraction are written as integers so that 1e5 reaches it as 100000
+func formatParam(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		if strings.ContainsAny(v, "\r\n") {
+			return "", fmt.Errorf("must be a single line")
+		}
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("must be a string, number or boolean")
+}
+
+// generatorInput is what the generator reads for the index-th test
+func generatorInput(g TestGroup, index int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "seed=%d\nindex=%d\ncount=%d\n", g.Seed+int64(index)-1, index, g.Count)
+	keys := make([]string, 0, len(g.Params))
+	for key := range g.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v, _ := formatParam(g.Params[key])
+		fmt.Fprintf(&b, "%s=%s\n", key, v)
+	}
+	return b.String()
+}
+
+// testGroupOf returns the group of a generated test name ("large-03" ->
+// "large"), or "" for a hand-written test
+func testGroupOf(name string) string {
+	i := strings.LastIndex(name, "-")
+	if i <= 0 || i == len(name)-1 {
+		return ""
+	}
+	for _, c := range name[i+1:] {
+		if c < '0' || c > '9' {
+			return ""
+		}
+	}
+	return name[:i]
+}
+
+// removeTestGroup deletes the stored tests of a group
+func removeTestGroup(dir, group string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if ext != ".in" && ext != ".out" {
+			continue
+		}
+		if testGroupOf(strings.TrimSuffix(name, ext)) == group {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+	pruneTestMeta(dir)
+}
+
+// removeDroppedTestGroups deletes the tests of groups an edit removed
+func removeDroppedTestGroups(current, updated Problem) {
+	dir := findPublicTestDir(updated.ID)
+	if dir == "" {
+		return
+	}
+	kept := make(map[string]bool)
+	for _, g := range updated.TestGroups {
+		kept[g.Name] = true
+	}
+	for _, g := range current.TestGroups {
+		if !kept[g.Name] {
+			removeTestGroup(dir, g.Name)
+		}
+	}
+}
+
+// generateInputs runs a group's generator once per test
+func generateInputs(p Problem, g TestGroup) ([]string, error) {
+	gen, ok := generatorPrograms.get(p.ID, g.Generator)
+	if !ok {
+		return nil, fmt.Errorf("test group %s: no generator named %q", g.Name, g.Generator)
+	}
+	args := make([]string, g.Count)
+	for i := range args {
+		args[i] = generatorInput(g, i+1)
+	}
+
+	inputs := make([]string, g.Count)
+	var total int
+	var genErr error
+	opts := runOptionsFromLimits(p.limitsFor(gen.Language))
+	opts.Uploads = p.ID
+	opts.Build, _ = p.buildFlagsFor("", gen.Language)
+	err := runProgram(gen.Language, gen.Source, args, opts, func(i int, stdout, stderr string, err error) bool {
+		switch {
+		case err != nil:
+			genErr = fmt.Errorf("test group %s: generator failed on test %d: %s", g.Name, i+1, previewOutput(strings.TrimSpace(stderr+"\n"+err.Error())))
+		case strings.TrimSpace(stdout) == "":
+			genErr = fmt.Errorf("test group %s: generator printed nothing for test %d", g.Name, i+1)
+		case len(stdout) > maxGeneratedInputBytes:
+			genErr = fmt.Errorf("test group %s: input %d is larger than %d MB", g.Name, i+1, maxGeneratedInputBytes>>20)
+		}
+		total += len(stdout)
+		if genErr == nil && total > maxGeneratedInputsBytes {
+			genErr = fmt.Errorf("test group %s: inputs are larger than %d MB in total", g.Name, maxGeneratedInputsBytes>>20)
+		}
+		inputs[i] = stdout
+		return genErr == nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("generator %s could not be run: %v", g.Generator, err)
+	}
+	return inputs, genErr
+}
+
+// generateTestGroup produces the tests of one group. The tests replace the
+// group's stored ones unless dryRun is set, or the reference solutions
+// disagree or fail and force is not set.
+func generateTestGroup(p Problem, g TestGroup, sols []Program, dryRun, force bool) (GroupReport, error) {
+	inputs, err := generateInputs(p, g)
+	if err != nil {
+		return GroupReport{}, err
+	}
+	names := make([]string, len(inputs))
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%02d", g.Name, i+1)
+	}
+	if p.Validator != nil {
+		errs, err := validateInputs(*p.Validator, names, inputs)
+		if err != nil {
+			return GroupReport{}, err
+		}
+		if len(errs) > 0 {
+			return GroupReport{}, &InputValidationError{Errors: errs}
+		}
+	}
+
+	outputs, ok, err := runSolutions(p, sols, inputs)
+	if err != nil {
+		return GroupReport{}, err
+	}
+	outReport, good := compareSolutions(names, sols, outputs, ok)
+	report := GroupReport{Group: g.Name, Tests: names, OutputReport: outReport}
+	for _, in := range inputs {
+		report.Bytes += int64(len(in))
+	}
+	for i := range names {
+		if _, written := good[i]; !written && (!force || !ok[0][i]) {
+			return report, nil
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	dir := findPublicTestDir(p.ID)
+	if dir == "" {
+		dir = filepath.Join(dataDir, p.ID, "v1", "public")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return report, err
+		}
+	}
+	removeTestGroup(dir, g.Name)
+	for i, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name+".in"), []byte(inputs[i]), 0644); err != nil {
+			return report, fmt.Errorf("failed to write %s.in: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".out"), []byte(outputs[0][i]), 0644); err != nil {
+			return report, fmt.Errorf("failed to write %s.out: %v", name, err)
+		}
+	}
+	report.Written = true
+	report.Updated = names
+	return report, nil
+}
+
+// generateTestsHandler handles POST /api/problems/{id}/generate-tests
+func generateTestsHandler(w http.ResponseWriter, r *http.Request, problemID string) {
+	if decoded, err := url.QueryUnescape(problemID); err == nil {
+		problemID = decoded
+	}
+	if !isValidProblemID(problemID) {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Groups   []string `json:"groups"`   // default: every group
+		Solution string   `json:"solution"` // primary solution, as for /outputs
+		DryRun   bool     `json:"dryRun"`
+		Force    bool     `json:"force"` // write groups the solutions disagree on
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", 400)
+			return
+		}
+	}
+	p, found := readProblemManifest(dataDir, problemID)
+	if !found {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+
+	fail := func(err error) {
+		if _, ok := err.(*InputValidationError); ok {
+			writeValidationFailure(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+	}
+
+	groups := p.TestGroups
+	if len(req.Groups) > 0 {
+		groups = nil
+		for _, name := range req.Groups {
+			var g *TestGroup
+			for i := range p.TestGroups {
+				if p.TestGroups[i].Name == name {
+					g = &p.TestGroups[i]
+				}
+			}
+			if g == nil {
+				fail(fmt.Errorf("no test group named %q", name))
+				return
+			}
+			groups = append(groups, *g)
+		}
+	}
+	if len(groups) == 0 {
+		fail(fmt.Errorf("problem has no test groups"))
+		return
+	}
+	sols, err := listSolutions(problemID)
+	if err == nil && len(sols) == 0 {
+		err = fmt.Errorf("problem has no reference solutions")
+	}
+	if err == nil {
+		sols, err = orderSolutions(sols, req.Solution)
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	before := snapshotTestCases(problemID)
+	reports := []GroupReport{}
+	conflict := false
+	for _, g := range groups {
+		report, err := generateTestGroup(p, g, sols, req.DryRun, req.Force)
+		if err != nil {
+			log.Printf("Generating test group %s of %s failed: %v", g.Name, problemID, err)
+			fail(err)
+			return
+		}
+		if len(report.Disagreements) > 0 || len(report.Failures) > 0 {
+			conflict = true
+		}
+		reports = append(reports, report)
+	}
+	details := map[string]interface{}{}
+	for _, rep := range reports {
+		if rep.Written {
+			details[rep.Group] = len(rep.Tests)
+		}
+	}
+	if len(details) > 0 {
+		recordAudit(r, "testcases.generate", problemID, before, snapshotTestCases(problemID), details)
+	}
+
+	status := "success"
+	if conflict {
+		status = "conflict"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "groups": reports})
+}
+
+// removePlainTests deletes the hand-written tests in dir
+func removePlainTests(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if (ext == ".in" || ext == ".out") && testGroupOf(strings.TrimSuffix(name, ext)) == "" {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+	return nil
+}
@@ -3,9 +3,12 @@ module ceesarcode
 go 1.25.1
 
 require (
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/google/generative-ai-go v0.15.0
 	github.com/google/uuid v1.6.0
+	golang.org/x/sys v0.20.0
 	google.golang.org/api v0.183.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -33,7 +36,6 @@ require (
 	golang.org/x/net v0.25.0 // indirect
 	golang.org/x/oauth2 v0.21.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
-	golang.org/x/sys v0.20.0 // indirect
 	golang.org/x/text v0.15.0 // indirect
 	golang.org/x/time v0.5.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20240604185151-ef581f913117 // indirect
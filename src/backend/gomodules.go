@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Go programs are built hermetically. Each run gets a generated go.mod
+// that requires the server's allowlist of third-party modules, and builds
+// against the allowlist's vendor/ directory with the network, the host's
+// GOPATH and its go env settings out of the way. Runs share a build cache
+// that is warmed at startup, so the standard library and the vendored
+// packages are compiled once; the go command locks the cache's entries, so
+// runs and submissions can write to it at the same time.
+//
+// The allowlist is a Go module in GO_MODULES_DIR (data/go-modules): its
+// allowlist.go imports the packages programs may use, and `go mod vendor`
+// fills vendor/ from them. Without it programs can only import the
+// standard library.
+
+const defaultGoModulePath = "solution"
+
+// goBuildFlags are set in GOFLAGS for every Go build. -trimpath keeps the
+// run's directory out of the cache keys, so vendored packages are shared.
+var goBuildFlags = []string{"-mod=vendor", "-trimpath"}
+
+var goModuleRe = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)"?`)
+var goDirectiveRe = regexp.MustCompile(`(?m)^go\s+[0-9.]+\s*$`)
+
+// goAllowlist is the loaded allowlist module
+type goAllowlist struct {
+	Dir      string          // the allowlist module; empty when there is none
+	GoMod    string          // its go.mod, which runs' go.mod files are made from
+	Modules  []string        // vendored modules, as path@version
+	Packages map[string]bool // vendored packages programs may import
+}
+
+var (
+	goAllowlistMu sync.Mutex
+	goModules     *goAllowlist // nil until loaded
+)
+
+// loadGoAllowlist reads the allowlist module in dir and its
+// vendor/modules.txt
+func loadGoAllowlist(dir string) (*goAllowlist, error) {
+	allow := &goAllowlist{Packages: map[string]bool{}}
+	gomod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if os.IsNotExist(err) {
+		return allow, nil
+	} else if err != nil {
+		return allow, err
+	}
+	modules, err := os.ReadFile(filepath.Join(dir, "vendor", "modules.txt"))
+	if err != nil {
+		return allow, fmt.Errorf("%s has no vendor/modules.txt; run `go mod vendor` there", dir)
+	}
+	allow.Dir, allow.GoMod = dir, string(gomod)
+	for _, line := range strings.Split(string(modules), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "# "):
+			// "# path version", or "# path version => replacement"
+			if f := strings.Fields(line); len(f) >= 3 {
+				allow.Modules = append(allow.Modules, f[1]+"@"+f[2])
+			}
+		case line != "" && !strings.HasPrefix(line, "#"):
+			allow.Packages[line] = true
+		}
+	}
+	return allow, nil
+}
+
+// currentGoAllowlist returns the allowlist, loading it first if that
+// hasn't happened yet. A broken allowlist is logged and treated as empty.
+func currentGoAllowlist() *goAllowlist {
+	goAllowlistMu.Lock()
+	defer goAllowlistMu.Unlock()
+	if goModules == nil {
+		allow, err := loadGoAllowlist(config.GoModulesDir)
+		if err != nil {
+			log.Printf("Go modules: %v; programs can only import the standard library", err)
+		}
+		goModules = allow
+	}
+	return goModules
+}
+
+// packageList returns the packages programs may import, sorted
+func (a *goAllowlist) packageList() []string {
+	list := make([]string, 0, len(a.Packages))
+	for pkg := range a.Packages {
+		list = append(list, pkg)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// goLanguageVersion is the go directive of generated go.mod files: the
+// installed toolchain's, so programs can use its language features
+func goLanguageVersion() string {
+	found, _ := currentToolchains()
+	parts := strings.Split(found["go"].Version, ".")
+	if len(parts) < 2 {
+		return "1.21"
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// goBuildEnv is the environment of go commands that build programs
+func goBuildEnv(dir string) []string {
+	return append(os.Environ(), goBuildSettings(dir)...)
+}
+
+// goBuildSettings are the variables goBuildEnv sets on top of the server's
+// environment; submissions pass them to the executor
+func goBuildSettings(dir string) []string {
+	settings := []string{
+		"GOFLAGS=" + strings.Join(goBuildFlags, " "),
+		"GOPROXY=off",
+		"GOSUMDB=off",
+		"GOTOOLCHAIN=local",
+		"GOWORK=off",
+		"GOENV=off",
+		"GO111MODULE=on",
+		"GOPATH=" + filepath.Join(abs(dir), ".gopath"),
+	}
+	if config.GoBuildCache != "" {
+		// an empty GOCACHE keeps go's default cache
+		settings = append(settings, "GOCACHE="+abs(config.GoBuildCache))
+	}
+	return settings
+}
+
+// prepareGoModule makes dir a module that builds against the allowlist.
+// A go.mod the project brought keeps its module path and nothing else,
+// and the project's own go.sum, go.work and vendor/ are replaced.
+func prepareGoModule(dir string) error {
+	allow := currentGoAllowlist()
+	modulePath := defaultGoModulePath
+	if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+		if m := goModuleRe.FindSubmatch(data); m != nil {
+			modulePath = string(m[1])
+		}
+	}
+	for _, name := range []string{"go.sum", "go.work", "go.work.sum", "vendor"} {
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	gomod := "go " + goLanguageVersion() + "\n"
+	if allow.Dir != "" {
+		gomod = goDirectiveRe.ReplaceAllString(goModuleRe.ReplaceAllString(allow.GoMod, ""), "go "+goLanguageVersion())
+		if err := os.Symlink(filepath.Join(abs(allow.Dir), "vendor"), filepath.Join(dir, "vendor")); err != nil {
+			return err
+		}
+	}
+	gomod = "module " + modulePath + "\n\n" + strings.TrimLeft(gomod, "\n")
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644); err != nil {
+		return err
+	}
+	return checkGoImports(dir, modulePath, allow)
+}
+
+// checkGoImports reports the first import of the project that is neither
+// the standard library, the project's own module nor on the allowlist
+func checkGoImports(dir, modulePath string, allow *goAllowlist) error {
+	for _, rel := range projectSources(dir, "go") {
+		if strings.HasSuffix(rel, "_test.go") {
+			continue
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, filepath.Join(dir, filepath.FromSlash(rel)), nil, parser.ImportsOnly)
+		if err != nil {
+			continue // the build reports it
+		}
+		for _, imp := range file.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			first := strings.SplitN(path, "/", 2)[0]
+			switch {
+			case !strings.Contains(first, "."):
+				// the standard library
+			case path == modulePath, strings.HasPrefix(path, modulePath+"/"):
+			case allow.Packages[path]:
+			default:
+				return fmt.Errorf("%s: package %s is not available; Go programs can import the standard library and the packages listed by GET /api/languages", rel, path)
+			}
+		}
+	}
+	return nil
+}
+
+// warmGoBuildCache compiles the standard library and the vendored
+// packages into the shared build cache with each build profile's flags
+func warmGoBuildCache() {
+	if _, err := exec.LookPath("go"); err != nil {
+		return
+	}
+	cache := config.GoBuildCache
+	if err := os.MkdirAll(cache, 0755); err != nil {
+		log.Printf("Go build cache: %v", err)
+		return
+	}
+	dir, err := os.MkdirTemp("", "ceesarcode-gowarm")
+	if err != nil {
+		log.Printf("Go build cache: %v", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	if err := prepareGoModule(dir); err != nil {
+		log.Printf("Go build cache: %v", err)
+		return
+	}
+	packages := append([]string{"std"}, currentGoAllowlist().packageList()...)
+
+	var server Problem // the server's profiles, without a problem's overrides
+	seen := map[string]bool{}
+	for _, profile := range server.buildProfileNames() {
+		flags, _ := server.buildFlagsFor(profile, "go")
+		key := strings.Join(flags.CompileFlags, "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		cmd := exec.Command("go", append(withFlags([]string{"build"}, flags.CompileFlags...), packages...)...)
+		cmd.Dir = dir
+		cmd.Env = goBuildEnv(dir)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Printf("Go build cache: warming for %s failed: %v: %s", profile, err, strings.TrimSpace(string(out)))
+			continue
+		}
+		log.Printf("Go build cache: warmed for %s (%d packages)", profile, len(packages))
+	}
+}
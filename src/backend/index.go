@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// problemIndex keeps every problem manifest of a data directory in memory so
+// listProblems and getProblem don't re-read and re-parse them per request.
+// It is built at startup, updated by the API after every write
+// (invalidate) and by fsnotify for edits made directly on disk (watch).
+type problemIndex struct {
+	dir string
+
+	mu       sync.RWMutex
+	problems map[string]Problem // keyed by directory name
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// problemCache is the index of dataDir; nil until main() builds it, in which
+// case lookups fall back to reading from disk
+var problemCache *problemIndex
+
+func newProblemIndex(dir string) *problemIndex {
+	ix := &problemIndex{dir: dir, problems: make(map[string]Problem)}
+	ix.rebuild()
+	return ix
+}
+
+// isProblemDirName filters out the directories of dataDir that never hold a
+// problem (uploads, staging and trash directories)
+func isProblemDirName(name string) bool {
+	return name != "uploads" && !strings.HasPrefix(name, ".")
+}
+
+// readProblemManifest parses dir/id/manifest.json, reporting false when the
+// problem doesn't exist or its manifest is unusable
+func readProblemManifest(dir, id string) (Problem, bool) {
+	b, err := os.ReadFile(filepath.Join(dir, id, "manifest.json"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error loading problem %s: %v", id, err)
+		}
+		return Problem{}, false
+	}
+	var p Problem
+	if err := json.Unmarshal(b, &p); err != nil {
+		log.Printf("Error unmarshaling problem %s: %v", id, err)
+		return Problem{}, false
+	}
+	return p, p.ID != ""
+}
+
+// scanProblems reads every problem of dir, keyed by directory name
+func scanProblems(dir string) (map[string]Problem, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	problems := make(map[string]Problem)
+	for _, e := range ents {
+		if !e.IsDir() || !isProblemDirName(e.Name()) {
+			continue
+		}
+		if p, ok := readProblemManifest(dir, e.Name()); ok {
+			problems[e.Name()] = p
+		}
+	}
+	return problems, nil
+}
+
+// rebuild re-reads the whole directory
+func (ix *problemIndex) rebuild() {
+	problems, err := scanProblems(ix.dir)
+	if err != nil {
+		log.Printf("Problem index: error reading %s: %v", ix.dir, err)
+		problems = make(map[string]Problem)
+	}
+	ix.mu.Lock()
+	ix.problems = problems
+	ix.mu.Unlock()
+	log.Printf("Problem index: loaded %d problems from %s", len(problems), ix.dir)
+}
+
+// invalidate re-reads a single problem, dropping it when it no longer exists
+func (ix *problemIndex) invalidate(id string) {
+	if ix == nil || id == "" || !isProblemDirName(id) {
+		return
+	}
+	p, ok := readProblemManifest(ix.dir, id)
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ok {
+		ix.problems[id] = p
+	} else {
+		delete(ix.problems, id)
+	}
+}
+
+// get returns the problem stored in directory id
+func (ix *problemIndex) get(id string) (Problem, bool) {
+	if ix == nil {
+		return readProblemManifest(dataDir, id)
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	p, ok := ix.problems[id]
+	return p, ok
+}
+
+// list returns all problems ordered by directory name
+func (ix *problemIndex) list() []Problem {
+	var problems map[string]Problem
+	if ix == nil {
+		problems, _ = scanProblems(dataDir)
+	} else {
+		ix.mu.RLock()
+		defer ix.mu.RUnlock()
+		problems = ix.problems
+	}
+
+	ids := make([]string, 0, len(problems))
+	for id := range problems {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	out := make([]Problem, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, problems[id])
+	}
+	return out
+}
+
+// watch keeps the index in sync with changes made on disk. It watches the
+// data directory (problems added or removed) and every problem directory
+// (manifest edits).
+func (ix *problemIndex) watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(ix.dir); err != nil {
+		w.Close()
+		return err
+	}
+	ix.watcher = w
+	ix.done = make(chan struct{})
+
+	if ents, err := os.ReadDir(ix.dir); err == nil {
+		for _, e := range ents {
+			if e.IsDir() && isProblemDirName(e.Name()) {
+				ix.watchProblemDir(e.Name())
+			}
+		}
+	}
+
+	go ix.run()
+	return nil
+}
+
+func (ix *problemIndex) watchProblemDir(id string) {
+	if err := ix.watcher.Add(filepath.Join(ix.dir, id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Problem index: cannot watch %s: %v", id, err)
+	}
+}
+
+func (ix *problemIndex) run() {
+	defer close(ix.done)
+	for {
+		select {
+		case ev, ok := <-ix.watcher.Events:
+			if !ok {
+				return
+			}
+			ix.handleEvent(ev)
+		case err, ok := <-ix.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Events may have been dropped (e.g. queue overflow), start over
+			log.Printf("Problem index: watcher error: %v; rebuilding", err)
+			ix.rebuild()
+		}
+	}
+}
+
+func (ix *problemIndex) handleEvent(ev fsnotify.Event) {
+	rel, err := filepath.Rel(ix.dir, ev.Name)
+	if err != nil {
+		return
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	id := parts[0]
+	if id == "." || id == ".." || !isProblemDirName(id) {
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		// A problem directory was created, removed or renamed
+		if ev.Has(fsnotify.Create) {
+			if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+				ix.watchProblemDir(id)
+			}
+		}
+		ix.invalidate(id)
+	case len(parts) == 2 && parts[1] == "manifest.json":
+		ix.invalidate(id)
+	}
+}
+
+// close stops watching
+func (ix *problemIndex) close() {
+	if ix == nil || ix.watcher == nil {
+		return
+	}
+	ix.watcher.Close()
+	<-ix.done
+}
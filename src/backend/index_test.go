@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestManifest(t *testing.T, dir, id, title string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, id), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(Problem{ID: id, Title: title})
+	if err := os.WriteFile(filepath.Join(dir, id, "manifest.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor polls cond until it holds or the watcher had plenty of time to react
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestProblemIndexBuild(t *testing.T) {
+	dir := t.TempDir()
+	writeTestManifest(t, dir, "b-problem", "B")
+	writeTestManifest(t, dir, "a-problem", "A")
+	os.MkdirAll(filepath.Join(dir, "uploads"), 0755)
+	os.MkdirAll(filepath.Join(dir, "no-manifest"), 0755)
+	writeTestManifest(t, dir, ".import-123", "Staging")
+
+	ix := newProblemIndex(dir)
+
+	list := ix.list()
+	if len(list) != 2 || list[0].ID != "a-problem" || list[1].ID != "b-problem" {
+		t.Fatalf("list = %+v, want a-problem and b-problem in order", list)
+	}
+	if p, ok := ix.get("b-problem"); !ok || p.Title != "B" {
+		t.Errorf("get(b-problem) = %+v, %v", p, ok)
+	}
+	if _, ok := ix.get("no-manifest"); ok {
+		t.Error("directory without manifest was indexed")
+	}
+}
+
+func TestProblemIndexInvalidate(t *testing.T) {
+	dir := t.TempDir()
+	writeTestManifest(t, dir, "p", "Old")
+	ix := newProblemIndex(dir)
+
+	// Without a watcher, disk edits are only picked up on invalidate
+	writeTestManifest(t, dir, "p", "New")
+	if p, _ := ix.get("p"); p.Title != "Old" {
+		t.Fatalf("title = %q before invalidate, want cached %q", p.Title, "Old")
+	}
+	ix.invalidate("p")
+	if p, _ := ix.get("p"); p.Title != "New" {
+		t.Fatalf("title = %q after invalidate, want %q", p.Title, "New")
+	}
+
+	writeTestManifest(t, dir, "q", "Added")
+	ix.invalidate("q")
+	if _, ok := ix.get("q"); !ok {
+		t.Fatal("new problem missing after invalidate")
+	}
+
+	os.RemoveAll(filepath.Join(dir, "p"))
+	ix.invalidate("p")
+	if _, ok := ix.get("p"); ok {
+		t.Fatal("deleted problem still indexed after invalidate")
+	}
+
+	// A manifest that no longer parses drops the problem
+	os.WriteFile(filepath.Join(dir, "q", "manifest.json"), []byte("{"), 0644)
+	ix.invalidate("q")
+	if len(ix.list()) != 0 {
+		t.Fatalf("list = %+v, want empty", ix.list())
+	}
+}
+
+func TestProblemIndexWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTestManifest(t, dir, "p", "Old")
+	ix := newProblemIndex(dir)
+	if err := ix.watch(); err != nil {
+		t.Skipf("filesystem watching unavailable: %v", err)
+	}
+	defer ix.close()
+
+	writeTestManifest(t, dir, "p", "Edited")
+	waitFor(t, "manifest edit", func() bool {
+		p, _ := ix.get("p")
+		return p.Title == "Edited"
+	})
+
+	writeTestManifest(t, dir, "new", "Created on disk")
+	waitFor(t, "new problem", func() bool {
+		_, ok := ix.get("new")
+		return ok
+	})
+
+	// The new directory is watched too
+	writeTestManifest(t, dir, "new", "Edited again")
+	waitFor(t, "edit in new problem", func() bool {
+		p, _ := ix.get("new")
+		return p.Title == "Edited again"
+	})
+
+	os.RemoveAll(filepath.Join(dir, "p"))
+	waitFor(t, "removal", func() bool {
+		_, ok := ix.get("p")
+		return !ok
+	})
+
+	// Atomic saves write a temp file and rename it over the manifest
+	tmp := filepath.Join(dir, "new", "manifest.json.tmp")
+	data, _ := json.Marshal(Problem{ID: "new", Title: "Renamed into place"})
+	os.WriteFile(tmp, data, 0644)
+	os.Rename(tmp, filepath.Join(dir, "new", "manifest.json"))
+	waitFor(t, "atomic save", func() bool {
+		p, _ := ix.get("new")
+		return p.Title == "Renamed into place"
+	})
+}
+
+func TestProblemHandlersUseIndex(t *testing.T) {
+	dir := t.TempDir()
+	oldDataDir, oldCache := dataDir, problemCache
+	dataDir = dir
+	problemCache = newProblemIndex(dir)
+	defer func() { dataDir, problemCache = oldDataDir, oldCache }()
+	initConfig()
+
+	// Writes through the API are visible immediately
+	w := httptest.NewRecorder()
+	createProblem(w, httptest.NewRequest("POST", "/api/problems/create", strings.NewReader(`{"Title":"Two Sum"}`)))
+	if w.Code != 200 {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	getProblem(w, httptest.NewRequest("GET", "/api/problem/two-sum", nil))
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "Two Sum") {
+		t.Fatalf("get after create: %d %s", w.Code, w.Body.String())
+	}
+
+	// Reads are served from memory, not from disk
+	writeTestManifest(t, dir, "two-sum", "Changed behind the index")
+	w = httptest.NewRecorder()
+	listProblems(w, httptest.NewRequest("GET", "/api/problems", nil))
+	var list []Problem
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Title != "Two Sum" {
+		t.Fatalf("list = %+v, want the cached problem", list)
+	}
+
+	w = httptest.NewRecorder()
+	deleteProblem(w, httptest.NewRequest("DELETE", "/api/problems/two-sum", nil), "two-sum")
+	w = httptest.NewRecorder()
+	getProblem(w, httptest.NewRequest("GET", "/api/problem/two-sum", nil))
+	if w.Code != 404 {
+		t.Fatalf("get after delete: %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,994 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Importers and exporters for the two judge package formats our problem sets
+// come in:
+//
+//   - Kattis problem packages (problem.yaml, problem_statement/, data/sample,
+//     data/secret, output_validators/)
+//   - Polygon packages (problem.xml, statement-sections/, tests/, files/)
+//
+// Both are mapped onto the CeesarCode layout: manifest.json, tests in
+// v1/public (samples first) and custom checkers in v1/checker. The judge
+// compares outputs exactly, so checkers are kept for export only and every
+// import that relies on one reports a warning.
+
+// exactPolygonCheckers are the Polygon standard checkers whose verdicts
+// match the judge's exact comparison of trimmed output
+var exactPolygonCheckers = map[string]bool{
+	"std::fcmp.cpp": true,
+	"std::hcmp.cpp": true,
+	"std::icmp.cpp": true,
+	"std::lcmp.cpp": true,
+	"std::ncmp.cpp": true,
+	"std::wcmp.cpp": true,
+}
+
+// checkerNotJudgedWarning explains that an imported checker has no effect on judging
+func checkerNotJudgedWarning(name string) string {
+	return fmt.Sprintf("checker %s is stored in v1/checker but not used for judging; outputs are compared exactly, so solutions that need a tolerance or produce another valid answer will be rejected", name)
+}
+
+// packageArchive indexes the files of a judge package by their path relative
+// to the package root. Packages are usually zipped with a single top-level
+// directory, which is stripped and remembered in root.
+type packageArchive struct {
+	files map[string]*zip.File
+	root  string
+}
+
+func newPackageArchive(zr *zip.Reader) (*packageArchive, error) {
+	var names []string
+	var total uint64
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s: only regular files are allowed in a package", f.Name)
+		}
+		if strings.Contains(f.Name, `\`) || path.IsAbs(f.Name) || path.Clean(f.Name) != f.Name || strings.HasPrefix(f.Name, "../") {
+			return nil, fmt.Errorf("%s: invalid path", f.Name)
+		}
+		total += f.UncompressedSize64
+		if total > maxBundleUncompressedBytes {
+			return nil, fmt.Errorf("package expands to more than %d bytes", uint64(maxBundleUncompressedBytes))
+		}
+		names = append(names, f.Name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("archive is empty")
+	}
+
+	// Strip a common top-level directory (e.g. "a-plus-b/problem.yaml")
+	root := ""
+	if i := strings.Index(names[0], "/"); i > 0 {
+		root = names[0][:i]
+		for _, name := range names {
+			if !strings.HasPrefix(name, root+"/") {
+				root = ""
+				break
+			}
+		}
+	}
+
+	a := &packageArchive{files: make(map[string]*zip.File), root: root}
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		name := f.Name
+		if root != "" {
+			name = strings.TrimPrefix(name, root+"/")
+		}
+		a.files[name] = f
+	}
+	return a, nil
+}
+
+func (a *packageArchive) has(name string) bool {
+	_, ok := a.files[name]
+	return ok
+}
+
+func (a *packageArchive) read(name string) ([]byte, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in package", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, int64(f.UncompressedSize64)+1))
+}
+
+// list returns the sorted paths of all files under dir (recursively)
+func (a *packageArchive) list(dir string) []string {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	var out []string
+	for name := range a.files {
+		if strings.HasPrefix(name, prefix) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// copyTo writes a package file to dest, creating parent directories
+func (a *packageArchive) copyTo(name, dest string) error {
+	data, err := a.read(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0644)
+}
+
+// detectArchiveFormat tells a CeesarCode bundle apart from Kattis and Polygon packages
+func detectArchiveFormat(zr *zip.Reader) string {
+	for _, f := range zr.File {
+		if f.Name == bundleHeaderName {
+			return "bundle"
+		}
+	}
+	a, err := newPackageArchive(zr)
+	if err != nil {
+		return ""
+	}
+	if a.has("problem.xml") {
+		return "polygon"
+	}
+	if a.has("problem.yaml") || len(a.list("data")) > 0 {
+		return "kattis"
+	}
+	return ""
+}
+
+// importedTest is a test case read from a judge package
+type importedTest struct {
+	Input, Output []byte
+	Sample        bool
+}
+
+// stagePackageProblem writes a converted problem into a fresh staging
+// directory under dataDir and commits it like a bundle import. v1Files maps
+// package paths to paths under v1 (checkers, reference solutions).
+func stagePackageProblem(a *packageArchive, problem Problem, tests []importedTest, v1Files map[string]string, onConflict string) (ImportedProblem, error) {
+	if !isValidProblemID(problem.ID) {
+		return ImportedProblem{}, fmt.Errorf("invalid problem ID %q", problem.ID)
+	}
+
+	stagingRoot := filepath.Join(dataDir, ".import-"+uuid.NewString())
+	stagingDir := filepath.Join(stagingRoot, problem.ID)
+	defer os.RemoveAll(stagingRoot)
+
+	publicDir := filepath.Join(stagingDir, "v1", "public")
+	if err := os.MkdirAll(publicDir, 0755); err != nil {
+		return ImportedProblem{}, err
+	}
+
+	// Samples first so they get the lowest numbers and match the examples
+	sort.SliceStable(tests, func(i, j int) bool { return tests[i].Sample && !tests[j].Sample })
+	if len(problem.Examples) == 0 {
+		problem.Examples = examplesFromSamples(tests)
+	}
+
+	manifestData, err := json.MarshalIndent(problem, "", "  ")
+	if err != nil {
+		return ImportedProblem{}, err
+	}
+	if err := os.WriteFile(filepath.Join(stagingDir, "manifest.json"), manifestData, 0644); err != nil {
+		return ImportedProblem{}, err
+	}
+	files := 1
+
+	var entries []testMetaEntry
+	for i, tc := range tests {
+		testNum := fmt.Sprintf("%02d", i+1)
+		if err := os.WriteFile(filepath.Join(publicDir, testNum+".in"), tc.Input, 0644); err != nil {
+			return ImportedProblem{}, err
+		}
+		if err := os.WriteFile(filepath.Join(publicDir, testNum+".out"), tc.Output, 0644); err != nil {
+			return ImportedProblem{}, err
+		}
+		files += 2
+		entries = append(entries, testMetaEntry{Test: testNum, TestMeta: TestMeta{Sample: tc.Sample}})
+	}
+	if err := writeTestMeta(publicDir, entries); err != nil {
+		return ImportedProblem{}, err
+	}
+	if len(entries) > 0 {
+		files++
+	}
+
+	for src, rel := range v1Files {
+		if err := a.copyTo(src, filepath.Join(stagingDir, "v1", filepath.FromSlash(rel))); err != nil {
+			return ImportedProblem{}, err
+		}
+		files++
+	}
+
+	res, err := commitImportedProblem(stagingDir, problem.ID, onConflict)
+	if err != nil {
+		return res, err
+	}
+	if res.Action != "skipped" {
+		res.Files = files
+	}
+	return res, nil
+}
+
+// ==========================================
+// Kattis problem package format
+// ==========================================
+
+type kattisProblemYAML struct {
+	ProblemFormatVersion string       `yaml:"problem_format_version,omitempty"`
+	Name                 interface{}  `yaml:"name,omitempty"` // string, or map of language code -> name
+	Author               string       `yaml:"author,omitempty"`
+	Source               interface{}  `yaml:"source,omitempty"`
+	License              string       `yaml:"license,omitempty"`
+	Validation           string       `yaml:"validation,omitempty"`
+	ValidatorFlags       string       `yaml:"validator_flags,omitempty"`
+	Limits               kattisLimits `yaml:"limits,omitempty"`
+}
+
+type kattisLimits struct {
+	TimeLimit      float64 `yaml:"time_limit,omitempty"`      // seconds
+	TimeMultiplier float64 `yaml:"time_multiplier,omitempty"` // derived from submissions, not importable
+	Memory         int     `yaml:"memory,omitempty"`          // MiB
+}
+
+func importKattisPackage(zr *zip.Reader, onConflict string) ([]ImportedProblem, error) {
+	a, err := newPackageArchive(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta kattisProblemYAML
+	if a.has("problem.yaml") {
+		data, err := a.read("problem.yaml")
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("invalid problem.yaml: %v", err)
+		}
+	}
+
+	var warnings []string
+	statement, texTitle := kattisStatement(a)
+	if statement == "" {
+		warnings = append(warnings, "no problem statement found")
+	}
+
+	title := localizedName(meta.Name)
+	if title == "" {
+		title = texTitle
+	}
+	if title == "" {
+		title = a.root
+	}
+	if title == "" {
+		return nil, fmt.Errorf("package has no problem name")
+	}
+
+	id := a.root
+	if id == "" {
+		id = problemIDFromTitle(title)
+	}
+
+	languages, _ := getLanguageConfig("python")
+	problem := Problem{
+		ID:            id,
+		Title:         title,
+		Statement:     statement,
+		Languages:     languages,
+		Stub:          map[string]string{},
+		Type:          "coding",
+		MemoryLimitMB: meta.Limits.Memory,
+	}
+
+	// Statements in other languages become translations; when there is no
+	// English one, the language of the chosen statement becomes the default
+	for lang, text := range kattisTranslations(a) {
+		if text == statement {
+			if lang != defaultProblemLocale {
+				problem.DefaultLocale = lang
+			}
+			continue
+		}
+		if lang == defaultProblemLocale {
+			continue
+		}
+		if problem.Statements == nil {
+			problem.Statements = make(map[string]string)
+		}
+		problem.Statements[lang] = text
+	}
+
+	switch {
+	case meta.Limits.TimeLimit > 0:
+		problem.TimeLimitMs = int(meta.Limits.TimeLimit * 1000)
+	case a.has(".timelimit"):
+		data, _ := a.read(".timelimit")
+		var seconds float64
+		if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%g", &seconds); err == nil && seconds > 0 {
+			problem.TimeLimitMs = int(seconds * 1000)
+		}
+	default:
+		warnings = append(warnings, "package has no explicit time limit (Kattis derives it from submissions); server default applies")
+	}
+
+	var tests []importedTest
+	for _, group := range []string{"data/sample", "data/secret"} {
+		for _, name := range a.list(group) {
+			if strings.HasSuffix(name, ".interaction") {
+				warnings = append(warnings, "interactive tests are not supported: "+name)
+				continue
+			}
+			if !strings.HasSuffix(name, ".in") {
+				continue
+			}
+			ans := strings.TrimSuffix(name, ".in") + ".ans"
+			if !a.has(ans) {
+				warnings = append(warnings, "skipped test without answer file: "+name)
+				continue
+			}
+			in, err := a.read(name)
+			if err != nil {
+				return nil, err
+			}
+			out, err := a.read(ans)
+			if err != nil {
+				return nil, err
+			}
+			tests = append(tests, importedTest{Input: in, Output: out, Sample: group == "data/sample"})
+		}
+	}
+	if len(tests) == 0 {
+		warnings = append(warnings, "package has no tests")
+	}
+
+	// Custom output validators (legacy output_validators/<name>/ or output_validator/)
+	checkerFiles := make(map[string]string)
+	for _, dir := range []string{"output_validators", "output_validator"} {
+		for _, name := range a.list(dir) {
+			rel := strings.TrimPrefix(name, dir+"/")
+			if dir == "output_validators" {
+				if i := strings.Index(rel, "/"); i >= 0 {
+					rel = rel[i+1:]
+				}
+			}
+			checkerFiles[name] = "checker/" + rel
+		}
+	}
+	if len(checkerFiles) > 0 {
+		warnings = append(warnings, checkerNotJudgedWarning("output_validators"))
+	} else if meta.Validation != "" && meta.Validation != "default" {
+		warnings = append(warnings, fmt.Sprintf("validation is %q but no output validator was found", meta.Validation))
+	}
+	if meta.ValidatorFlags != "" && len(checkerFiles) == 0 {
+		warnings = append(warnings, fmt.Sprintf("default validator flags %q are not supported; outputs are compared exactly", meta.ValidatorFlags))
+	}
+
+	// Accepted submissions become reference solutions
+	for _, name := range a.list("submissions/accepted") {
+		rel := strings.TrimPrefix(name, "submissions/accepted/")
+		if strings.Contains(rel, "/") || languageForFile(rel) == "" {
+			warnings = append(warnings, fmt.Sprintf("accepted submission %s is not a single source file and was not imported", rel))
+			continue
+		}
+		checkerFiles[name] = solutionsDirName + "/" + solutionFileName(rel)
+	}
+
+	res, err := stagePackageProblem(a, problem, tests, checkerFiles, onConflict)
+	if err != nil {
+		return nil, err
+	}
+	res.Warnings = warnings
+	return []ImportedProblem{res}, nil
+}
+
+// kattisStatement returns the English (or only) statement of a Kattis package
+// and the title from \problemname, if any
+func kattisStatement(a *packageArchive) (string, string) {
+	candidates := []string{
+		"statement/problem.en.md", "problem_statement/problem.en.md", "problem_statement/problem.md",
+		"statement/problem.en.tex", "problem_statement/problem.en.tex", "problem_statement/problem.tex",
+	}
+	for _, dir := range []string{"statement", "problem_statement"} {
+		for _, name := range a.list(dir) {
+			base := path.Base(name)
+			if strings.HasPrefix(base, "problem.") && (strings.HasSuffix(base, ".md") || strings.HasSuffix(base, ".tex")) {
+				candidates = append(candidates, name)
+			}
+		}
+	}
+
+	for _, name := range candidates {
+		if !a.has(name) {
+			continue
+		}
+		data, err := a.read(name)
+		if err != nil {
+			continue
+		}
+		if strings.HasSuffix(name, ".md") {
+			return strings.TrimSpace(string(data)), ""
+		}
+		return texToText(string(data))
+	}
+	return "", ""
+}
+
+// kattisTranslations returns the text of every problem.{lang}.md/.tex
+// statement, keyed by locale (Markdown wins over LaTeX)
+func kattisTranslations(a *packageArchive) map[string]string {
+	out := make(map[string]string)
+	for _, dir := range []string{"statement", "problem_statement"} {
+		names := a.list(dir)
+		sort.Slice(names, func(i, j int) bool {
+			// .tex first so that .md overwrites it
+			return strings.HasSuffix(names[i], ".tex") && !strings.HasSuffix(names[j], ".tex")
+		})
+		for _, name := range names {
+			parts := strings.Split(path.Base(name), ".")
+			if len(parts) != 3 || parts[0] != "problem" || (parts[2] != "md" && parts[2] != "tex") {
+				continue
+			}
+			lang := normalizeLocale(parts[1])
+			if lang == "" {
+				continue
+			}
+			data, err := a.read(name)
+			if err != nil {
+				continue
+			}
+			if parts[2] == "md" {
+				out[lang] = strings.TrimSpace(string(data))
+			} else {
+				out[lang], _ = texToText(string(data))
+			}
+		}
+	}
+	return out
+}
+
+// localizedName picks the English name from a Kattis name field
+func localizedName(v interface{}) string {
+	switch n := v.(type) {
+	case string:
+		return n
+	case map[string]interface{}:
+		if en, ok := n["en"].(string); ok {
+			return en
+		}
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if s, ok := n[k].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func writeKattisPackage(w http.ResponseWriter, problemID string) {
+	p := loadProblem(problemID)
+	if p.ID == "" {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+
+	meta := kattisProblemYAML{
+		Name:       p.Title,
+		Source:     "CeesarCode",
+		Validation: "default",
+		Limits:     kattisLimits{Memory: p.MemoryLimitMB},
+	}
+	if p.TimeLimitMs > 0 {
+		meta.Limits.TimeLimit = float64(p.TimeLimitMs) / 1000
+	}
+	checkerDir := filepath.Join(dataDir, problemID, "v1", "checker")
+	if _, err := os.Stat(checkerDir); err == nil {
+		meta.Validation = "custom"
+	}
+	yamlData, err := yaml.Marshal(meta)
+	if err != nil {
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", problemID+"-kattis.zip"))
+
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+	root := problemID + "/"
+
+	writeZipEntry(zw, root+"problem.yaml", yamlData)
+	writeZipEntry(zw, root+"problem_statement/problem."+p.defaultLocale()+".tex",
+		[]byte(fmt.Sprintf("\\problemname{%s}\n\n%s\n", texEscape(p.Title), texEscape(p.Statement))))
+	for lang, text := range p.Statements {
+		writeZipEntry(zw, root+"problem_statement/problem."+lang+".tex",
+			[]byte(fmt.Sprintf("\\problemname{%s}\n\n%s\n", texEscape(p.Title), texEscape(text))))
+	}
+
+	if publicDir := findPublicTestDir(problemID); publicDir != "" {
+		metas := testMetaByName(publicDir)
+		for i, name := range listTestNames(publicDir) {
+			group := "secret"
+			if isSampleTest(metas, name, i, p) {
+				group = "sample" // shown in the statement
+			}
+			if err := addFileToZip(zw, filepath.Join(publicDir, name+".in"), root+"data/"+group+"/"+name+".in"); err != nil {
+				log.Printf("Kattis export of %s: %v", problemID, err)
+			}
+			if err := addFileToZip(zw, filepath.Join(publicDir, name+".out"), root+"data/"+group+"/"+name+".ans"); err != nil {
+				log.Printf("Kattis export of %s: %v", problemID, err)
+			}
+		}
+	}
+
+	if meta.Validation == "custom" {
+		addDirToZip(zw, checkerDir, root+"output_validators/checker")
+	}
+	addDirToZip(zw, solutionPrograms.path(problemID), root+"submissions/accepted")
+}
+
+// ==========================================
+// Polygon package format
+// ==========================================
+
+type polygonProblemXML struct {
+	XMLName    xml.Name           `xml:"problem"`
+	Revision   string             `xml:"revision,attr,omitempty"`
+	ShortName  string             `xml:"short-name,attr"`
+	Names      []polygonName      `xml:"names>name"`
+	Statements []polygonStatement `xml:"statements>statement,omitempty"`
+	Testsets   []polygonTestset   `xml:"judging>testset"`
+	Checker    *polygonChecker    `xml:"assets>checker,omitempty"`
+	Solutions  []polygonSolution  `xml:"assets>solutions>solution,omitempty"`
+}
+
+type polygonName struct {
+	Language string `xml:"language,attr"`
+	Value    string `xml:"value,attr"`
+}
+
+type polygonStatement struct {
+	Charset  string `xml:"charset,attr,omitempty"`
+	Language string `xml:"language,attr"`
+	Path     string `xml:"path,attr"`
+	Type     string `xml:"type,attr"`
+}
+
+type polygonTestset struct {
+	Name              string        `xml:"name,attr"`
+	TimeLimit         int           `xml:"time-limit"`   // milliseconds
+	MemoryLimit       int64         `xml:"memory-limit"` // bytes
+	TestCount         int           `xml:"test-count"`
+	InputPathPattern  string        `xml:"input-path-pattern"`
+	AnswerPathPattern string        `xml:"answer-path-pattern"`
+	Tests             []polygonTest `xml:"tests>test"`
+}
+
+type polygonTest struct {
+	Method string `xml:"method,attr,omitempty"`
+	Cmd    string `xml:"cmd,attr,omitempty"`
+	Sample bool   `xml:"sample,attr,omitempty"`
+}
+
+type polygonChecker struct {
+	Name   string         `xml:"name,attr,omitempty"`
+	Type   string         `xml:"type,attr,omitempty"`
+	Source *polygonSource `xml:"source,omitempty"`
+}
+
+type polygonSolution struct {
+	Tag    string        `xml:"tag,attr"` // "main", "accepted", "wrong-answer", ...
+	Source polygonSource `xml:"source"`
+}
+
+type polygonSource struct {
+	Path string `xml:"path,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+func importPolygonPackage(zr *zip.Reader, onConflict string) ([]ImportedProblem, error) {
+	a, err := newPackageArchive(zr)
+	if err != nil {
+		return nil, err
+	}
+	data, err := a.read("problem.xml")
+	if err != nil {
+		return nil, err
+	}
+	var px polygonProblemXML
+	if err := xml.Unmarshal(data, &px); err != nil {
+		return nil, fmt.Errorf("invalid problem.xml: %v", err)
+	}
+
+	var warnings []string
+	title := ""
+	for _, n := range px.Names {
+		if title == "" || n.Language == "english" {
+			title = n.Value
+		}
+	}
+	if title == "" {
+		title = px.ShortName
+	}
+	id := px.ShortName
+	if id == "" {
+		id = problemIDFromTitle(title)
+	}
+
+	statement := polygonStatementText(a, px)
+	if statement == "" {
+		warnings = append(warnings, "no problem statement found")
+	}
+
+	if len(px.Testsets) == 0 {
+		return nil, fmt.Errorf("problem.xml has no testset")
+	}
+	ts := px.Testsets[0]
+	for _, t := range px.Testsets {
+		if t.Name == "tests" {
+			ts = t
+			break
+		}
+	}
+
+	languages, _ := getLanguageConfig("python")
+	problem := Problem{
+		ID:            id,
+		Title:         title,
+		Statement:     statement,
+		Languages:     languages,
+		Stub:          map[string]string{},
+		Type:          "coding",
+		TimeLimitMs:   ts.TimeLimit,
+		MemoryLimitMB: int(ts.MemoryLimit >> 20),
+	}
+
+	count := ts.TestCount
+	if count == 0 {
+		count = len(ts.Tests)
+	}
+	var tests []importedTest
+	for i := 1; i <= count; i++ {
+		inPath := fmt.Sprintf(ts.InputPathPattern, i)
+		ansPath := fmt.Sprintf(ts.AnswerPathPattern, i)
+		if !a.has(inPath) || !a.has(ansPath) {
+			warnings = append(warnings, fmt.Sprintf("test %d is missing from the package (generated tests are only included in full packages)", i))
+			continue
+		}
+		in, err := a.read(inPath)
+		if err != nil {
+			return nil, err
+		}
+		out, err := a.read(ansPath)
+		if err != nil {
+			return nil, err
+		}
+		sample := i <= len(ts.Tests) && ts.Tests[i-1].Sample
+		tests = append(tests, importedTest{Input: in, Output: out, Sample: sample})
+	}
+
+	checkerFiles := make(map[string]string)
+	if px.Checker != nil {
+		name := px.Checker.Name
+		if px.Checker.Source != nil && a.has(px.Checker.Source.Path) {
+			checkerFiles[px.Checker.Source.Path] = "checker/" + path.Base(px.Checker.Source.Path)
+			if a.has("files/testlib.h") {
+				checkerFiles["files/testlib.h"] = "checker/testlib.h"
+			}
+			if name == "" {
+				name = path.Base(px.Checker.Source.Path)
+			}
+		}
+		if name != "" && !exactPolygonCheckers[name] {
+			warnings = append(warnings, checkerNotJudgedWarning(name))
+		}
+	}
+
+	// The main and accepted solutions become reference solutions
+	for _, sol := range px.Solutions {
+		if sol.Tag != "main" && sol.Tag != "accepted" {
+			continue
+		}
+		base := path.Base(sol.Source.Path)
+		if !a.has(sol.Source.Path) || languageForFile(base) == "" {
+			warnings = append(warnings, fmt.Sprintf("solution %s could not be imported", sol.Source.Path))
+			continue
+		}
+		name := solutionFileName(base)
+		if sol.Tag == "main" {
+			name = primarySolutionName + path.Ext(name)
+		}
+		checkerFiles[sol.Source.Path] = solutionsDirName + "/" + name
+	}
+
+	res, err := stagePackageProblem(a, problem, tests, checkerFiles, onConflict)
+	if err != nil {
+		return nil, err
+	}
+	res.Warnings = warnings
+	return []ImportedProblem{res}, nil
+}
+
+// polygonStatementText assembles the statement from problem-properties.json,
+// statement-sections or the statement .tex files, preferring English
+func polygonStatementText(a *packageArchive, px polygonProblemXML) string {
+	languages := []string{"english"}
+	for _, st := range px.Statements {
+		if st.Language != "english" {
+			languages = append(languages, st.Language)
+		}
+	}
+
+	for _, lang := range languages {
+		if data, err := a.read("statements/" + lang + "/problem-properties.json"); err == nil {
+			var props struct {
+				Legend string `json:"legend"`
+				Input  string `json:"input"`
+				Output string `json:"output"`
+				Notes  string `json:"notes"`
+			}
+			if json.Unmarshal(data, &props) == nil && props.Legend != "" {
+				return joinStatementSections(props.Legend, props.Input, props.Output, props.Notes)
+			}
+		}
+		for _, dir := range []string{"statement-sections/" + lang, "statements/" + lang} {
+			var sections [4]string
+			for i, name := range []string{"legend", "input", "output", "notes"} {
+				if data, err := a.read(dir + "/" + name + ".tex"); err == nil {
+					sections[i], _ = texToText(string(data))
+				}
+			}
+			if sections[0] != "" {
+				return joinStatementSections(sections[0], sections[1], sections[2], sections[3])
+			}
+		}
+	}
+
+	for _, st := range px.Statements {
+		if st.Type == "application/x-tex" && a.has(st.Path) {
+			data, _ := a.read(st.Path)
+			text, _ := texToText(string(data))
+			return text
+		}
+	}
+	return ""
+}
+
+func joinStatementSections(legend, input, output, notes string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(legend))
+	if s := strings.TrimSpace(input); s != "" {
+		sb.WriteString("\n\nInput:\n" + s)
+	}
+	if s := strings.TrimSpace(output); s != "" {
+		sb.WriteString("\n\nOutput:\n" + s)
+	}
+	if s := strings.TrimSpace(notes); s != "" {
+		sb.WriteString("\n\nNotes:\n" + s)
+	}
+	return sb.String()
+}
+
+func writePolygonPackage(w http.ResponseWriter, problemID string) {
+	p := loadProblem(problemID)
+	if p.ID == "" {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+
+	ts := polygonTestset{
+		Name:              "tests",
+		TimeLimit:         p.TimeLimitMs,
+		MemoryLimit:       int64(p.MemoryLimitMB) << 20,
+		InputPathPattern:  "tests/%02d",
+		AnswerPathPattern: "tests/%02d.a",
+	}
+	if ts.TimeLimit == 0 {
+		ts.TimeLimit = config.ExecutionTimeoutSeconds * 1000
+	}
+	if ts.MemoryLimit == 0 {
+		ts.MemoryLimit = int64(config.MaxMemoryMB) << 20
+	}
+
+	var testNames []string
+	metas := make(map[string]TestMeta)
+	publicDir := findPublicTestDir(problemID)
+	if publicDir != "" {
+		testNames = listTestNames(publicDir)
+		metas = testMetaByName(publicDir)
+	}
+	for i, name := range testNames {
+		ts.Tests = append(ts.Tests, polygonTest{Method: "manual", Sample: isSampleTest(metas, name, i, p)})
+	}
+	ts.TestCount = len(testNames)
+
+	px := polygonProblemXML{
+		Revision:  "1",
+		ShortName: p.ID,
+		Names:     []polygonName{{Language: "english", Value: p.Title}},
+		Statements: []polygonStatement{{
+			Charset: "UTF-8", Language: "english", Path: "statements/english/problem.tex", Type: "application/x-tex",
+		}},
+		Testsets: []polygonTestset{ts},
+		Checker: &polygonChecker{
+			Name: "std::wcmp.cpp", Type: "testlib",
+		},
+	}
+
+	checkerDir := filepath.Join(dataDir, problemID, "v1", "checker")
+	var checkerSources []string
+	if entries, err := os.ReadDir(checkerDir); err == nil {
+		for _, e := range entries {
+			if !e.IsDir() {
+				checkerSources = append(checkerSources, e.Name())
+			}
+		}
+	}
+	if len(checkerSources) > 0 {
+		entry := checkerSources[0]
+		for _, name := range checkerSources {
+			if strings.HasPrefix(name, "check") {
+				entry = name
+				break
+			}
+		}
+		px.Checker = &polygonChecker{Type: "testlib", Source: &polygonSource{Path: "files/" + entry}}
+	}
+
+	solutions, _ := listSolutions(problemID)
+	for i, s := range solutions {
+		tag := "accepted"
+		if i == 0 {
+			tag = "main"
+		}
+		file := filepath.Base(solutionPrograms.file(problemID, s.Name))
+		px.Solutions = append(px.Solutions, polygonSolution{Tag: tag, Source: polygonSource{Path: "solutions/" + file}})
+	}
+
+	xmlData, err := xml.MarshalIndent(px, "", "    ")
+	if err != nil {
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", problemID+"-polygon.zip"))
+
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	writeZipEntry(zw, "problem.xml", append([]byte(xml.Header), xmlData...))
+	writeZipEntry(zw, "statement-sections/english/name.tex", []byte(texEscape(p.Title)))
+	writeZipEntry(zw, "statement-sections/english/legend.tex", []byte(texEscape(p.Statement)))
+	writeZipEntry(zw, "statements/english/problem.tex",
+		[]byte(fmt.Sprintf("\\begin{problem}{%s}{standard input}{standard output}{%d seconds}{%d megabytes}\n\n%s\n\n\\end{problem}\n",
+			texEscape(p.Title), (ts.TimeLimit+999)/1000, ts.MemoryLimit>>20, texEscape(p.Statement))))
+
+	for i, name := range testNames {
+		if err := addFileToZip(zw, filepath.Join(publicDir, name+".in"), fmt.Sprintf(ts.InputPathPattern, i+1)); err != nil {
+			log.Printf("Polygon export of %s: %v", problemID, err)
+		}
+		if err := addFileToZip(zw, filepath.Join(publicDir, name+".out"), fmt.Sprintf(ts.AnswerPathPattern, i+1)); err != nil {
+			log.Printf("Polygon export of %s: %v", problemID, err)
+		}
+	}
+	for _, name := range checkerSources {
+		if err := addFileToZip(zw, filepath.Join(checkerDir, name), "files/"+name); err != nil {
+			log.Printf("Polygon export of %s: %v", problemID, err)
+		}
+	}
+	for _, sol := range px.Solutions {
+		file := path.Base(sol.Source.Path)
+		if err := addFileToZip(zw, filepath.Join(solutionPrograms.path(problemID), file), sol.Source.Path); err != nil {
+			log.Printf("Polygon export of %s: %v", problemID, err)
+		}
+	}
+}
+
+// ==========================================
+// Shared helpers
+// ==========================================
+
+func writeZipEntry(zw *zip.Writer, name string, data []byte) {
+	fw, err := zw.Create(name)
+	if err != nil {
+		log.Printf("Failed to add %s to archive: %v", name, err)
+		return
+	}
+	if _, err := fw.Write(data); err != nil {
+		log.Printf("Failed to write %s to archive: %v", name, err)
+	}
+}
+
+func addDirToZip(zw *zip.Writer, dir, prefix string) {
+	filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil
+		}
+		if err := addFileToZip(zw, p, prefix+"/"+filepath.ToSlash(rel)); err != nil {
+			log.Printf("Failed to add %s to archive: %v", p, err)
+		}
+		return nil
+	})
+}
+
+var (
+	texProblemNameRe = regexp.MustCompile(`\\problemname\{([^}]*)\}`)
+	texSectionRe     = regexp.MustCompile(`\\(?:sub)*section\*?\{([^}]*)\}`)
+	texStyleRe       = regexp.MustCompile(`\\(?:textbf|textit|emph|texttt|t)\{([^}]*)\}`)
+	texEnvRe         = regexp.MustCompile(`\\(?:begin|end)\{[^}]*\}(?:\{[^}]*\})*`)
+	texBlankLinesRe  = regexp.MustCompile(`\n{3,}`)
+)
+
+// texToText turns a problem statement written in LaTeX into readable plain
+// text. Math is kept as-is; only the structural markup is removed.
+func texToText(tex string) (text, title string) {
+	if m := texProblemNameRe.FindStringSubmatch(tex); m != nil {
+		title = strings.TrimSpace(m[1])
+		tex = strings.Replace(tex, m[0], "", 1)
+	}
+	tex = texSectionRe.ReplaceAllString(tex, "\n$1:\n")
+	tex = texStyleRe.ReplaceAllString(tex, "$1")
+	tex = texEnvRe.ReplaceAllString(tex, "")
+	tex = strings.ReplaceAll(tex, `\item`, "-")
+	tex = strings.ReplaceAll(tex, `\\`, "\n")
+	tex = strings.ReplaceAll(tex, "~", " ")
+	tex = texUnescaper.Replace(tex)
+	tex = texBlankLinesRe.ReplaceAllString(tex, "\n\n")
+	return strings.TrimSpace(tex), title
+}
+
+var texUnescaper = strings.NewReplacer(
+	`\textbackslash{}`, `\`, `\textasciitilde{}`, "~", `\textasciicircum{}`, "^",
+	`\#`, "#", `\$`, "$", `\%`, "%", `\&`, "&", `\_`, "_", `\{`, "{", `\}`, "}",
+)
+
+// texEscape escapes the characters LaTeX treats specially
+func texEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\textbackslash{}`,
+		`#`, `\#`, `$`, `\$`, `%`, `\%`, `&`, `\&`, `_`, `\_`,
+		`{`, `\{`, `}`, `\}`, `~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`,
+	).Replace(s)
+}
@@ -0,0 +1,208 @@
+package main
+
+// kernelDriver is the Python program a kernel runs. It reads cells as JSON
+// lines ({"code": ..., "count": ...}) from stdin, runs them in one
+// namespace, and answers each with one line on stdout: the kernel's token
+// followed by {"status": ..., "outputs": [...]}. What the cell prints is
+// captured through sys.stdout and sys.stderr; the token keeps output that
+// bypasses them (from subprocesses, say) from passing for a reply.
+const kernelDriver = `
+import ast, base64, builtins, io, json, linecache, os, signal, sys, traceback, warnings
+
+TOKEN = os.environ.pop("CEESARCODE_KERNEL_TOKEN", "")
+MAX_OUTPUT = int(os.environ.pop("CEESARCODE_KERNEL_MAX_OUTPUT", "1048576"))
+MAX_MEMORY_MB = int(os.environ.pop("CEESARCODE_KERNEL_MAX_MEMORY_MB", "0"))
+MAX_TABLE_ROWS = 50
+
+real_stdout, real_stdin = sys.stdout, sys.stdin
+outputs = []
+written = [0]
+namespace = {"__name__": "__main__", "__builtins__": builtins}
+
+warnings.filterwarnings("ignore", message=".*non-interactive.*")
+
+
+class Stream(io.TextIOBase):
+    encoding = "utf-8"
+
+    def __init__(self, name):
+        self._name = name
+
+    @property
+    def name(self):
+        return "<" + self._name + ">"
+
+    def writable(self):
+        return True
+
+    def write(self, text):
+        if not isinstance(text, str):
+            raise TypeError("write() argument must be str, not " + type(text).__name__)
+        n = len(text)
+        if written[0] >= MAX_OUTPUT:
+            return n
+        if written[0] + n > MAX_OUTPUT:
+            text = text[:MAX_OUTPUT - written[0]] + "\n[output truncated]\n"
+        written[0] += n
+        if outputs and outputs[-1]["type"] == "stream" and outputs[-1]["name"] == self._name:
+            outputs[-1]["text"] += text
+        else:
+            outputs.append({"type": "stream", "name": self._name, "text": text})
+        return n
+
+
+def figure_png(fig):
+    buf = io.BytesIO()
+    fig.savefig(buf, format="png", bbox_inches="tight")
+    return base64.b64encode(buf.getvalue()).decode("ascii")
+
+
+def table_data(obj):
+    kind = type(obj).__name__
+    if kind == "Series" and hasattr(obj, "to_frame"):
+        obj = obj.to_frame()
+    elif kind != "DataFrame" or not hasattr(obj, "head"):
+        return None
+    head = obj.head(MAX_TABLE_ROWS)
+    return {
+        "columns": [str(c) for c in head.columns],
+        "index": [str(i) for i in head.index],
+        "rows": json.loads(head.to_json(orient="values", date_format="iso", default_handler=str)),
+        "rowCount": len(obj),
+    }
+
+
+def mime_bundle(obj):
+    data = {"text/plain": repr(obj)}
+    if isinstance(obj, type):
+        return data
+    if type(obj).__module__.startswith("matplotlib") and hasattr(obj, "savefig"):
+        data["image/png"] = figure_png(obj)
+    for method, mime in (("_repr_html_", "text/html"), ("_repr_markdown_", "text/markdown"),
+                         ("_repr_svg_", "image/svg+xml"), ("_repr_png_", "image/png"),
+                         ("_repr_jpeg_", "image/jpeg"), ("_repr_json_", "application/json"),
+                         ("_repr_latex_", "text/latex")):
+        fn = getattr(obj, method, None)
+        if not callable(fn):
+            continue
+        try:
+            value = fn()
+        except Exception:
+            continue
+        if isinstance(value, tuple):
+            value = value[0]
+        if value is None:
+            continue
+        if isinstance(value, bytes):
+            value = base64.b64encode(value).decode("ascii")
+        data[mime] = value
+    try:
+        table = table_data(obj)
+    except Exception:
+        table = None
+    if table is not None:
+        data["application/vnd.ceesarcode.table+json"] = table
+    return data
+
+
+def display(*objs):
+    for obj in objs:
+        outputs.append({"type": "display_data", "data": mime_bundle(obj)})
+
+
+def flush_figures():
+    plt = sys.modules.get("matplotlib.pyplot")
+    if plt is None:
+        return
+    try:
+        for num in plt.get_fignums():
+            fig = plt.figure(num)
+            outputs.append({"type": "display_data", "data": {"text/plain": repr(fig), "image/png": figure_png(fig)}})
+        plt.close("all")
+    except Exception as e:
+        outputs.append({"type": "stream", "name": "stderr", "text": "could not render figures: %s\n" % e})
+
+
+def limit_memory():
+    if MAX_MEMORY_MB <= 0:
+        return
+    try:
+        import resource
+        limit = MAX_MEMORY_MB << 20
+        resource.setrlimit(resource.RLIMIT_DATA, (limit, limit))
+    except (ImportError, ValueError, OSError):
+        pass
+
+
+def error_output(e):
+    if isinstance(e, MemoryError) and MAX_MEMORY_MB > 0:
+        return {"type": "error", "ename": "MemoryError",
+                "evalue": "memory limit of %d MB exceeded" % MAX_MEMORY_MB,
+                "traceback": ["MemoryError: memory limit of %d MB exceeded" % MAX_MEMORY_MB]}
+    tb = e.__traceback__
+    while tb is not None and tb.tb_frame.f_code.co_filename == "<string>":
+        tb = tb.tb_next
+    if isinstance(e, SyntaxError):
+        lines = traceback.format_exception_only(type(e), e)
+    else:
+        lines = traceback.format_exception(type(e), e, tb)
+    return {"type": "error", "ename": type(e).__name__, "evalue": str(e),
+            "traceback": "".join(lines).rstrip("\n").split("\n")}
+
+
+def run_cell(code, count):
+    filename = "<cell %d>" % count
+    linecache.cache[filename] = (len(code), None, code.splitlines(True), filename)
+    tree = ast.parse(code, filename)
+    last = None
+    if tree.body and isinstance(tree.body[-1], ast.Expr) and not code.rstrip().endswith(";"):
+        last = ast.Expression(tree.body.pop().value)
+    exec(compile(tree, filename, "exec"), namespace)
+    if last is not None:
+        value = eval(compile(last, filename, "eval"), namespace)
+        if value is not None:
+            namespace["_"] = value
+            outputs.append({"type": "execute_result", "executionCount": count, "data": mime_bundle(value)})
+
+
+def reply(message):
+    real_stdout.write(TOKEN + json.dumps(message) + "\n")
+    real_stdout.flush()
+
+
+def main():
+    signal.signal(signal.SIGINT, signal.default_int_handler)
+    limit_memory()
+    namespace["display"] = display
+    reply({"status": "ready", "python": sys.version.split()[0]})
+    while True:
+        try:
+            line = real_stdin.readline()
+        except KeyboardInterrupt:
+            continue
+        if not line:
+            return
+        request = json.loads(line)
+        del outputs[:]
+        written[0] = 0
+        status = "ok"
+        sys.stdout, sys.stderr, sys.stdin = Stream("stdout"), Stream("stderr"), io.StringIO()
+        try:
+            run_cell(request["code"], request["count"])
+        except KeyboardInterrupt as e:
+            status = "interrupted"
+            outputs.append(error_output(e))
+        except BaseException as e:
+            status = "error"
+            outputs.append(error_output(e))
+        finally:
+            try:
+                flush_figures()
+            except KeyboardInterrupt:
+                pass
+            sys.stdout, sys.stderr, sys.stdin = real_stdout, sys.__stderr__, real_stdin
+        reply({"status": status, "outputs": outputs})
+
+
+main()
+`
@@ -0,0 +1,616 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notebook kernels keep a Python process per session so that cells run in
+// order share their state, like Jupyter kernels. A kernel runs in its own
+// working directory, with the uploads of its problem in data/, and is shut
+// down after KERNEL_IDLE_TIMEOUT_MINUTES without activity.
+
+const (
+	kernelStartTimeout     = 15 * time.Second
+	kernelInterruptGrace   = 5 * time.Second // before a timed out cell's kernel is restarted
+	kernelKillGrace        = 2 * time.Second // for the output pipes to close after a kill
+	kernelReapInterval     = time.Minute
+	maxKernelOutputBytes   = 1 << 20 // output kept of a cell
+	maxKernelCodeBytes     = 1 << 20
+	kernelReplyBufferDepth = 4
+)
+
+const (
+	kernelStatusStarting = "starting"
+	kernelStatusIdle     = "idle"
+	kernelStatusBusy     = "busy"
+	kernelStatusDead     = "dead"
+)
+
+var (
+	errTooManyKernels = errors.New("too many kernels are running; shut one down first")
+	errKernelDead     = errors.New("the kernel is dead; restart it")
+)
+
+// KernelInfo describes a kernel
+type KernelInfo struct {
+	ID             string    `json:"id"`
+	ProblemID      string    `json:"problemId,omitempty"` // whose uploads are in data/
+	Status         string    `json:"status"`              // starting, idle, busy or dead
+	Python         string    `json:"python,omitempty"`    // version of the interpreter
+	ExecutionCount int       `json:"executionCount"`      // cells run since the last (re)start
+	MemoryLimitMB  int       `json:"memoryLimitMB"`       // data the kernel may allocate (0 = none)
+	CreatedAt      time.Time `json:"createdAt"`
+	LastActivity   time.Time `json:"lastActivity"`
+}
+
+// KernelOutput is one output of a cell, shaped like Jupyter's
+type KernelOutput struct {
+	Type           string                 `json:"type"`           // stream, execute_result, display_data or error
+	Name           string                 `json:"name,omitempty"` // stdout or stderr, for streams
+	Text           string                 `json:"text,omitempty"`
+	Data           map[string]interface{} `json:"data,omitempty"` // by MIME type; images are base64
+	ExecutionCount int                    `json:"executionCount,omitempty"`
+	EName          string                 `json:"ename,omitempty"`
+	EValue         string                 `json:"evalue,omitempty"`
+	Traceback      []string               `json:"traceback,omitempty"`
+}
+
+// CellResult is what running a cell produced
+type CellResult struct {
+	Status         string         `json:"status"` // ok, error, interrupted, timeout or dead
+	ExecutionCount int            `json:"executionCount"`
+	Outputs        []KernelOutput `json:"outputs"`
+	DurationMs     int64          `json:"durationMs"`
+	Restarted      bool           `json:"restarted,omitempty"` // the kernel had to be restarted; its state is gone
+}
+
+// Kernel is a notebook session
+type Kernel struct {
+	mu   sync.Mutex // guards info and proc
+	info KernelInfo
+	proc *kernelProcess
+
+	run     sync.Mutex // held while a cell runs, so cells run one at a time
+	life    sync.Mutex // held while the process is replaced or stopped
+	dir     string
+	dataDir string
+}
+
+// kernelProcess is the Python process behind a kernel
+type kernelProcess struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	pipe    io.ReadCloser // the driver's stdout, which read consumes
+	token   string
+	replies chan []byte
+	done    chan struct{} // closed when the process has exited
+	stdout  cappedBuffer  // output that bypassed the driver
+	stderr  cappedBuffer
+}
+
+// cappedBuffer keeps up to maxKernelOutputBytes written to it
+type cappedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := maxKernelOutputBytes - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// take returns and clears the content
+func (b *cappedBuffer) take() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.buf.String()
+	b.buf.Reset()
+	return s
+}
+
+var (
+	kernelsMu sync.Mutex
+	kernels   = make(map[string]*Kernel)
+)
+
+// startKernel starts a kernel, with the uploads of problemID if given
+func startKernel(problemID string) (*Kernel, error) {
+	kernelsMu.Lock()
+	if config.MaxKernels > 0 && len(kernels) >= config.MaxKernels {
+		kernelsMu.Unlock()
+		return nil, errTooManyKernels
+	}
+	now := time.Now()
+	k := &Kernel{info: KernelInfo{ID: uuid.NewString(), ProblemID: problemID, Status: kernelStatusStarting, CreatedAt: now, LastActivity: now, MemoryLimitMB: config.MaxMemoryMB}}
+	kernels[k.info.ID] = k // reserves the slot while the process starts
+	kernelsMu.Unlock()
+
+	fail := func(err error) (*Kernel, error) {
+		kernelsMu.Lock()
+		delete(kernels, k.info.ID)
+		kernelsMu.Unlock()
+		if k.dir != "" {
+			os.RemoveAll(k.dir)
+		}
+		return nil, err
+	}
+
+	k.dir = filepath.Join(os.TempDir(), "ceesarcode-kernels", k.info.ID)
+	if err := os.MkdirAll(k.dir, 0755); err != nil {
+		return fail(err)
+	}
+	if problemID != "" {
+		mount, err := mountUploads(problemID, k.dir)
+		if err != nil {
+			return fail(err)
+		}
+		k.dataDir = mount
+		k.info.MemoryLimitMB = loadProblem(problemID).limitsFor("python").MemoryLimitMB
+	}
+	if err := k.restart(); err != nil {
+		return fail(err)
+	}
+	log.Printf("Started kernel %s (problem %q)", k.info.ID, problemID)
+	return k, nil
+}
+
+// getKernel returns a running kernel, or nil
+func getKernel(id string) *Kernel {
+	kernelsMu.Lock()
+	defer kernelsMu.Unlock()
+	return kernels[id]
+}
+
+// listKernels returns the kernels, oldest first
+func listKernels() []KernelInfo {
+	kernelsMu.Lock()
+	list := make([]*Kernel, 0, len(kernels))
+	for _, k := range kernels {
+		list = append(list, k)
+	}
+	kernelsMu.Unlock()
+
+	infos := make([]KernelInfo, 0, len(list))
+	for _, k := range list {
+		infos = append(infos, k.snapshot())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt.Before(infos[j].CreatedAt) })
+	return infos
+}
+
+// shutdownKernel stops a kernel and removes its working directory
+func shutdownKernel(id string) bool {
+	kernelsMu.Lock()
+	k, ok := kernels[id]
+	delete(kernels, id)
+	kernelsMu.Unlock()
+	if !ok {
+		return false
+	}
+	k.life.Lock()
+	defer k.life.Unlock()
+	k.mu.Lock()
+	p := k.proc
+	k.proc = nil
+	k.info.Status = kernelStatusDead
+	k.mu.Unlock()
+	if p != nil {
+		p.kill()
+	}
+	os.RemoveAll(k.dir)
+	log.Printf("Shut down kernel %s", id)
+	return true
+}
+
+// expireIdleKernels shuts down kernels that have been idle for too long
+func expireIdleKernels(now time.Time) {
+	idle := time.Duration(config.KernelIdleTimeoutMinutes) * time.Minute
+	for _, info := range listKernels() {
+		if info.Status != kernelStatusBusy && now.Sub(info.LastActivity) > idle {
+			log.Printf("Kernel %s idle since %s", info.ID, info.LastActivity.Format(time.RFC3339))
+			shutdownKernel(info.ID)
+		}
+	}
+}
+
+func expireIdleKernelsLoop() {
+	for {
+		time.Sleep(kernelReapInterval)
+		expireIdleKernels(time.Now())
+	}
+}
+
+// snapshot returns the kernel's current info
+func (k *Kernel) snapshot() KernelInfo {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	info := k.info
+	if k.proc != nil && k.proc.exited() {
+		info.Status = kernelStatusDead
+	}
+	return info
+}
+
+// restart replaces the kernel's process with a fresh one; files in its
+// working directory are kept
+func (k *Kernel) restart() error {
+	k.life.Lock()
+	defer k.life.Unlock()
+
+	k.mu.Lock()
+	old := k.proc
+	k.proc = nil
+	k.info.Status = kernelStatusStarting
+	k.mu.Unlock()
+	if old != nil {
+		old.kill()
+	}
+
+	p, version, err := startKernelProcess(k.dir, k.dataDir, k.info.MemoryLimitMB)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err != nil {
+		k.info.Status = kernelStatusDead
+		return err
+	}
+	k.proc = p
+	k.info.Status = kernelStatusIdle
+	k.info.Python = version
+	k.info.ExecutionCount = 0
+	k.info.LastActivity = time.Now()
+	return nil
+}
+
+// interrupt stops the running cell with a KeyboardInterrupt
+func (k *Kernel) interrupt() error {
+	k.mu.Lock()
+	p := k.proc
+	k.info.LastActivity = time.Now()
+	k.mu.Unlock()
+	if p == nil || p.exited() {
+		return errKernelDead
+	}
+	if err := p.cmd.Process.Signal(os.Interrupt); err != nil {
+		return fmt.Errorf("interrupt is not supported here (%v); restart the kernel instead", err)
+	}
+	return nil
+}
+
+// execute runs a cell, giving up on it after timeout
+func (k *Kernel) execute(code string, timeout time.Duration) (CellResult, error) {
+	k.run.Lock()
+	defer k.run.Unlock()
+
+	k.mu.Lock()
+	p := k.proc
+	if p == nil || p.exited() {
+		k.mu.Unlock()
+		return CellResult{}, errKernelDead
+	}
+	k.info.ExecutionCount++
+	k.info.Status = kernelStatusBusy
+	k.info.LastActivity = time.Now()
+	result := CellResult{ExecutionCount: k.info.ExecutionCount}
+	k.mu.Unlock()
+
+	start := time.Now()
+	p.stdout.take()
+	p.stderr.take()
+	req, _ := json.Marshal(map[string]interface{}{"code": code, "count": result.ExecutionCount})
+	var reply []byte
+	if _, err := p.stdin.Write(append(req, '\n')); err == nil {
+		reply = p.await(timeout)
+	}
+	switch {
+	case reply != nil:
+		if err := json.Unmarshal(reply, &result); err != nil {
+			result.Status = kernelStatusDead
+		} else if result.Status == "interrupted" && time.Since(start) >= timeout {
+			result.Status = "timeout"
+		}
+	case p.exited():
+		result.Status = kernelStatusDead
+	default:
+		// the cell didn't react to the interrupt in time
+		result.Status = "timeout"
+	}
+
+	// output that bypassed sys.stdout and sys.stderr, e.g. of subprocesses
+	var outputs []KernelOutput
+	for _, s := range []struct{ name, text string }{{"stdout", p.stdout.take()}, {"stderr", p.stderr.take()}} {
+		if s.text != "" {
+			outputs = append(outputs, KernelOutput{Type: "stream", Name: s.name, Text: s.text})
+		}
+	}
+	result.Outputs = append(outputs, result.Outputs...)
+	if result.Status == kernelStatusDead {
+		result.Outputs = append(result.Outputs, KernelOutput{Type: "error", EName: "KernelDied", EValue: "the kernel process exited while running the cell"})
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	k.mu.Lock()
+	current := k.proc == p
+	if current {
+		k.info.Status = kernelStatusIdle
+		k.info.LastActivity = time.Now()
+	}
+	k.mu.Unlock()
+
+	if result.Status == "timeout" && reply == nil && current {
+		// the cell ignored the interrupt; start over
+		if err := k.restart(); err != nil {
+			log.Printf("Failed to restart kernel %s: %v", k.info.ID, err)
+		}
+		result.Restarted = true
+	}
+	if result.Outputs == nil {
+		result.Outputs = []KernelOutput{}
+	}
+	return result, nil
+}
+
+// startKernelProcess starts the Python driver in dir and waits until it's
+// ready. The driver caps its own data segment at memoryLimitMB, so a cell
+// that allocates too much fails with a MemoryError instead of taking the
+// server down
+func startKernelProcess(dir, dataDir string, memoryLimitMB int) (*kernelProcess, string, error) {
+	pythonCmd := strings.Fields(findPythonCommand())
+	args := append(pythonCmd[1:], "-u", "-c", kernelDriver)
+	p := &kernelProcess{
+		cmd:     exec.Command(pythonCmd[0], args...),
+		token:   uuid.NewString(),
+		replies: make(chan []byte, kernelReplyBufferDepth),
+		done:    make(chan struct{}),
+	}
+	p.cmd.Dir = dir
+	p.cmd.Env = append(os.Environ(),
+		"CEESARCODE_KERNEL_TOKEN="+p.token,
+		fmt.Sprintf("CEESARCODE_KERNEL_MAX_OUTPUT=%d", maxKernelOutputBytes),
+		fmt.Sprintf("CEESARCODE_KERNEL_MAX_MEMORY_MB=%d", memoryLimitMB),
+		"MPLBACKEND=Agg",
+		"PYTHONIOENCODING=utf-8",
+	)
+	if dataDir != "" {
+		p.cmd.Env = append(p.cmd.Env, dataDirEnvVar+"="+dataDir)
+	}
+	p.cmd.Stderr = &p.stderr
+	// Subprocesses a cell starts inherit the output pipes; they are in the
+	// kernel's process group and killed with it, and Wait doesn't wait on
+	// pipes held open by any that escaped
+	p.cmd.WaitDelay = kernelKillGrace
+	startProcessGroup(p.cmd)
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, "", err
+	}
+	p.pipe = stdout
+	if p.stdin, err = p.cmd.StdinPipe(); err != nil {
+		return nil, "", err
+	}
+	if err := p.cmd.Start(); err != nil {
+		return nil, "", fmt.Errorf("failed to start Python: %v", err)
+	}
+	go p.read(stdout)
+
+	reply := p.await(kernelStartTimeout)
+	var ready struct {
+		Status string `json:"status"`
+		Python string `json:"python"`
+	}
+	if reply == nil || json.Unmarshal(reply, &ready) != nil || ready.Status != "ready" {
+		p.kill()
+		return nil, "", fmt.Errorf("the kernel didn't start: %s", strings.TrimSpace(p.stderr.take()))
+	}
+	return p, ready.Python, nil
+}
+
+// read splits the process's output into replies and stray output
+func (p *kernelProcess) read(stdout io.Reader) {
+	br := bufio.NewReaderSize(stdout, 64<<10)
+	for {
+		line, err := br.ReadString('\n')
+		if i := strings.Index(line, p.token); i >= 0 {
+			p.stdout.Write([]byte(line[:i]))
+			select {
+			case p.replies <- []byte(line[i+len(p.token):]):
+			default:
+				log.Printf("Dropping kernel reply nobody waits for")
+			}
+		} else if line != "" {
+			p.stdout.Write([]byte(line))
+		}
+		if err != nil {
+			break
+		}
+	}
+	p.cmd.Wait()
+	close(p.done)
+}
+
+// await returns the next reply, interrupting the process after timeout and
+// giving up kernelInterruptGrace later; nil means there was none
+func (p *kernelProcess) await(timeout time.Duration) []byte {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case reply := <-p.replies:
+		return reply
+	case <-p.done:
+		return nil
+	case <-timer.C:
+	}
+	if err := p.cmd.Process.Signal(os.Interrupt); err != nil {
+		return nil
+	}
+	select {
+	case reply := <-p.replies:
+		return reply
+	case <-p.done:
+		return nil
+	case <-time.After(kernelInterruptGrace):
+		return nil
+	}
+}
+
+func (p *kernelProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill stops the process and the subprocesses of its cells, and waits
+// until it's gone. A subprocess that left the group can keep the stdout
+// pipe open, so it's closed when the reader hasn't finished in time.
+func (p *kernelProcess) kill() {
+	p.stdin.Close()
+	killProcessGroup(p.cmd)
+	select {
+	case <-p.done:
+	case <-time.After(kernelKillGrace):
+		p.pipe.Close()
+		<-p.done
+	}
+}
+
+func writeKernelError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+}
+
+func writeKernelJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleKernelRoutes serves /api/kernels and /api/kernels/...
+//
+//	GET    /api/kernels                 list kernels
+//	POST   /api/kernels                 start a kernel
+//	GET    /api/kernels/{id}            one kernel
+//	POST   /api/kernels/{id}/execute    run a cell
+//	POST   /api/kernels/{id}/interrupt  interrupt the running cell
+//	POST   /api/kernels/{id}/restart    restart with a fresh state
+//	DELETE /api/kernels/{id}            shut the kernel down
+func handleKernelRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/kernels"), "/")
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			writeKernelJSON(w, http.StatusOK, listKernels())
+		case http.MethodPost:
+			var req struct {
+				ProblemID string `json:"problemId"`
+			}
+			if r.ContentLength != 0 {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					http.Error(w, "Invalid JSON", 400)
+					return
+				}
+			}
+			if req.ProblemID != "" && (!isValidProblemID(req.ProblemID) || !problemExists(req.ProblemID)) {
+				http.Error(w, "Problem not found", http.StatusNotFound)
+				return
+			}
+			k, err := startKernel(req.ProblemID)
+			if errors.Is(err, errTooManyKernels) {
+				writeKernelError(w, http.StatusTooManyRequests, err)
+				return
+			}
+			if err != nil {
+				log.Printf("Failed to start kernel: %v", err)
+				writeKernelError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeKernelJSON(w, http.StatusCreated, k.snapshot())
+		default:
+			http.Error(w, "GET or POST only", 405)
+		}
+		return
+	}
+
+	k := getKernel(parts[0])
+	if k == nil || len(parts) > 2 {
+		http.Error(w, "Kernel not found", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			writeKernelJSON(w, http.StatusOK, k.snapshot())
+		case http.MethodDelete:
+			shutdownKernel(parts[0])
+			writeKernelJSON(w, http.StatusOK, map[string]string{"status": "success"})
+		default:
+			http.Error(w, "GET or DELETE only", 405)
+		}
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", 405)
+		return
+	}
+	switch parts[1] {
+	case "execute":
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxKernelCodeBytes+1024)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", 400)
+			return
+		}
+		timeout := time.Duration(config.KernelCellTimeoutSeconds) * time.Second
+		result, err := k.execute(req.Code, timeout)
+		if err != nil {
+			writeKernelError(w, http.StatusConflict, err)
+			return
+		}
+		writeKernelJSON(w, http.StatusOK, result)
+	case "interrupt":
+		if err := k.interrupt(); err != nil {
+			writeKernelError(w, http.StatusConflict, err)
+			return
+		}
+		writeKernelJSON(w, http.StatusOK, k.snapshot())
+	case "restart":
+		if err := k.restart(); err != nil {
+			log.Printf("Failed to restart kernel %s: %v", parts[0], err)
+			writeKernelError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeKernelJSON(w, http.StatusOK, k.snapshot())
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
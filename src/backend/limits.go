@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+)
+
+// ResourceLimits are the time and memory limits a solution runs under
+type ResourceLimits struct {
+	TimeLimitMs   int `json:"TimeLimitMs,omitempty"`
+	MemoryLimitMB int `json:"MemoryLimitMB,omitempty"`
+}
+
+// limitsFor resolves the limits for a language: the problem's per-language
+// override, then the problem-wide limits, then the server defaults
+func (p Problem) limitsFor(language string) ResourceLimits {
+	limits := ResourceLimits{
+		TimeLimitMs:   config.ExecutionTimeoutSeconds * 1000,
+		MemoryLimitMB: config.MaxMemoryMB,
+	}
+	if p.TimeLimitMs > 0 {
+		limits.TimeLimitMs = p.TimeLimitMs
+	}
+	if p.MemoryLimitMB > 0 {
+		limits.MemoryLimitMB = p.MemoryLimitMB
+	}
+	if l, ok := p.LanguageLimits[language]; ok {
+		if l.TimeLimitMs > 0 {
+			limits.TimeLimitMs = l.TimeLimitMs
+		}
+		if l.MemoryLimitMB > 0 {
+			limits.MemoryLimitMB = l.MemoryLimitMB
+		}
+	}
+	return limits
+}
+
+// RunOptions controls how the language runners execute a program
+type RunOptions struct {
+	TimeLimit     time.Duration // wall-clock limit for the run step (0 = none)
+	MemoryLimitMB int           // peak resident memory allowed (0 = none)
+	Uploads       string        // problem whose uploads are mounted in data/ (see mountUploads)
+	DataDir       string        // exported as CEESARCODE_DATA_DIR; set by runLanguage
+	EntryPoint    string        // project file the program starts from (see findEntryPoint)
+	Build         BuildFlags    // flags of the build profile (see buildFlagsFor)
+	Profile       *CPUProfile   // filled in with the run's CPU profile when set (see profiling.go)
+	Prebuilt      bool          // dir holds the binary of an earlier run; compiled languages skip their build step
+}
+
+func runOptionsFromLimits(l ResourceLimits) RunOptions {
+	return RunOptions{
+		TimeLimit:     time.Duration(l.TimeLimitMs) * time.Millisecond,
+		MemoryLimitMB: l.MemoryLimitMB,
+	}
+}
+
+// runWithLimits runs cmd and returns its combined output like CombinedOutput,
+// killing it when it exceeds the time limit. Its data segment is capped at
+// the memory limit as soon as it has started (see limitMemory), and a run
+// that fails on an allocation or whose peak memory was over the limit
+// fails with the memory it used. cmd runs in a process group of its own,
+// which is killed as a whole, so that wrappers like perf don't leave the
+// program running.
+func runWithLimits(cmd *exec.Cmd, opts RunOptions) ([]byte, error) {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// Don't wait forever on children that inherited the output pipe
+	cmd.WaitDelay = time.Second
+	if opts.DataDir != "" || len(opts.Build.Env) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, opts.Build.Env...)
+		if opts.DataDir != "" {
+			cmd.Env = append(cmd.Env, dataDirEnvVar+"="+opts.DataDir)
+		}
+	}
+
+	startProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	if opts.MemoryLimitMB > 0 {
+		// Fails only when the program has already exited
+		limitMemory(cmd.Process, opts.MemoryLimitMB)
+	}
+
+	var timedOut atomic.Bool
+	if opts.TimeLimit > 0 {
+		timer := time.AfterFunc(opts.TimeLimit, func() {
+			timedOut.Store(true)
+			killProcessGroup(cmd)
+		})
+		defer timer.Stop()
+	}
+
+	err := cmd.Wait()
+	if timedOut.Load() {
+		return out.Bytes(), fmt.Errorf("time limit exceeded (%v)", opts.TimeLimit)
+	}
+	if opts.MemoryLimitMB > 0 && cmd.ProcessState != nil {
+		peak := peakMemoryMB(cmd.ProcessState)
+		if peak > opts.MemoryLimitMB || (err != nil && ranOutOfMemory(out.Bytes())) {
+			return out.Bytes(), fmt.Errorf("memory limit exceeded (%d MB used, limit %d MB)", peak, opts.MemoryLimitMB)
+		}
+	}
+	return out.Bytes(), err
+}
+
+// outOfMemoryMarkers are what runtimes print when an allocation fails
+var outOfMemoryMarkers = []string{
+	"std::bad_alloc",            // C++
+	"MemoryError",               // Python
+	"OutOfMemoryError",          // Java
+	"out of memory",             // Go, Swift, C
+	"memory allocation of",      // Rust
+	"failed to allocate memory", // Ruby
+	"Cannot allocate memory",    // ENOMEM from libc
+	"heap out of memory",        // Node.js
+}
+
+// ranOutOfMemory reports whether a failed program's output says an
+// allocation failed
+func ranOutOfMemory(out []byte) bool {
+	for _, m := range outOfMemoryMarkers {
+		if bytes.Contains(out, []byte(m)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Problem.Statement and Part.Statement are written in the problem's
+// DefaultLocale ("en" unless set). Translations live next to them in
+// Statements, keyed by BCP 47 tag ("es", "pt-BR"). getProblem and
+// listProblems pick one statement per request with this fallback chain:
+//
+//  1. each locale of ?lang= (comma-separated), then of Accept-Language by q
+//  2. for "pt-BR" also "pt"; for "pt" also any "pt-*" translation
+//  3. the default locale (Statement itself)
+
+const defaultProblemLocale = "en"
+
+// normalizeLocale canonicalizes a BCP 47 tag ("PT_br" -> "pt-BR") and
+// returns "" for anything that isn't one
+func normalizeLocale(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if tag == "" {
+		return ""
+	}
+	subtags := strings.Split(tag, "-")
+	for i, s := range subtags {
+		if s == "" || len(s) > 8 {
+			return ""
+		}
+		for _, c := range s {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+				return ""
+			}
+		}
+		switch {
+		case i == 0:
+			if len(s) < 2 || len(s) > 3 {
+				return ""
+			}
+			subtags[i] = strings.ToLower(s)
+		case len(s) == 2:
+			subtags[i] = strings.ToUpper(s) // region
+		case len(s) == 4:
+			subtags[i] = strings.ToUpper(s[:1]) + strings.ToLower(s[1:]) // script
+		default:
+			subtags[i] = strings.ToLower(s)
+		}
+	}
+	return strings.Join(subtags, "-")
+}
+
+func baseLanguage(tag string) string {
+	if i := strings.Index(tag, "-"); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+// defaultLocale is the locale of Statement
+func (p Problem) defaultLocale() string {
+	if l := normalizeLocale(p.DefaultLocale); l != "" {
+		return l
+	}
+	return defaultProblemLocale
+}
+
+// parseAcceptLanguage returns the tags of an Accept-Language header, most
+// preferred first
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := normalizeLocale(fields[0])
+		if tag == "" {
+			continue // also skips "*"
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	out := make([]string, len(tags))
+	for i, t := range tags {
+		out[i] = t.tag
+	}
+	return out
+}
+
+// requestedLocales returns the locales a request asks for, ?lang= first
+func requestedLocales(r *http.Request) []string {
+	var out []string
+	for _, l := range splitListParam(r.URL.Query()["lang"]) {
+		if tag := normalizeLocale(l); tag != "" {
+			out = append(out, tag)
+		}
+	}
+	return append(out, parseAcceptLanguage(r.Header.Get("Accept-Language"))...)
+}
+
+// localeChain expands the requested locales into the lookup order, ending
+// with the default locale
+func localeChain(requested []string, def string) []string {
+	seen := make(map[string]bool)
+	var chain []string
+	add := func(tag string) {
+		if !seen[tag] {
+			seen[tag] = true
+			chain = append(chain, tag)
+		}
+	}
+	for _, tag := range requested {
+		add(tag)
+		add(baseLanguage(tag))
+	}
+	add(def)
+	return chain
+}
+
+// pickStatement returns the first text of the chain that exists; text is
+// the statement in the default locale def
+func pickStatement(text, def string, translations map[string]string, chain []string) (string, string) {
+	for _, tag := range chain {
+		if tag == def {
+			return text, def
+		}
+		if t, ok := translations[tag]; ok && t != "" {
+			return t, tag
+		}
+		if !strings.Contains(tag, "-") {
+			// "pt" accepts "pt-BR" when there is no plain "pt"
+			var regional []string
+			for k := range translations {
+				if baseLanguage(k) == tag && translations[k] != "" {
+					regional = append(regional, k)
+				}
+			}
+			if len(regional) > 0 {
+				sort.Strings(regional)
+				return translations[regional[0]], regional[0]
+			}
+			if baseLanguage(def) == tag {
+				return text, def
+			}
+		}
+	}
+	return text, def
+}
+
+// localized returns a copy of p whose Statement and part statements are in
+// the best available locale; Locale tells which one the main statement is in
+func (p Problem) localized(requested []string) Problem {
+	def := p.defaultLocale()
+	chain := localeChain(requested, def)
+	p.Statement, p.Locale = pickStatement(p.Statement, def, p.Statements, chain)
+	if len(p.Parts) > 0 {
+		parts := make([]Part, len(p.Parts))
+		copy(parts, p.Parts)
+		for i := range parts {
+			parts[i].Statement, _ = pickStatement(parts[i].Statement, def, parts[i].Statements, chain)
+		}
+		p.Parts = parts
+	}
+	return p
+}
+
+// setLocaleHeaders marks a response as language-dependent
+func setLocaleHeaders(w http.ResponseWriter, locale string) {
+	w.Header().Set("Content-Language", locale)
+	w.Header().Add("Vary", "Accept-Language")
+}
+
+// normalizeStatementLocales canonicalizes the keys of a translation map
+func normalizeStatementLocales(statements map[string]string, def string) (map[string]string, error) {
+	if len(statements) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(statements))
+	for k, v := range statements {
+		tag := normalizeLocale(k)
+		if tag == "" {
+			return nil, fmt.Errorf("invalid locale %q", k)
+		}
+		if tag == def {
+			return nil, fmt.Errorf("Statements must not contain the default locale %q; edit Statement instead", def)
+		}
+		if v != "" {
+			out[tag] = v
+		}
+	}
+	return out, nil
+}
+
+// validateProblemLocales normalizes the locale fields of an edited manifest
+func validateProblemLocales(p *Problem) error {
+	if p.DefaultLocale != "" {
+		l := normalizeLocale(p.DefaultLocale)
+		if l == "" {
+			return fmt.Errorf("invalid DefaultLocale %q", p.DefaultLocale)
+		}
+		p.DefaultLocale = l
+	}
+	def := p.defaultLocale()
+
+	// A Statement fetched in another locale must not be saved as the default
+	if p.Locale != "" && normalizeLocale(p.Locale) != def {
+		return fmt.Errorf("Statement is in locale %q, not the default %q; edit Statements.%s or fetch the problem with ?lang=%s", p.Locale, def, p.Locale, def)
+	}
+	p.Locale = ""
+
+	var err error
+	if p.Statements, err = normalizeStatementLocales(p.Statements, def); err != nil {
+		return err
+	}
+	for i := range p.Parts {
+		if p.Parts[i].Statements, err = normalizeStatementLocales(p.Parts[i].Statements, def); err != nil {
+			return fmt.Errorf("part %d: %v", p.Parts[i].PartNumber, err)
+		}
+	}
+	return nil
+}
+
+// problemLocales lists the locales a problem's statement is available in
+func problemLocales(p Problem) []string {
+	locales := []string{p.defaultLocale()}
+	for tag := range p.Statements {
+		locales = append(locales, tag)
+	}
+	sort.Strings(locales[1:])
+	return locales
+}
+
+// Translation via the AI providers
+
+// aiProviderAndKey resolves the provider and API key of a request, falling
+// back to the server defaults
+func aiProviderAndKey(provider, apiKey string) (string, string) {
+	provider = strings.ToLower(provider)
+	if provider == "" {
+		provider = config.DefaultAIProvider
+	}
+	if apiKey == "" {
+		switch provider {
+		case "gemini":
+			apiKey = os.Getenv("GEMINI_API_KEY")
+		case "openai":
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		case "claude":
+			apiKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+	}
+	return provider, apiKey
+}
+
+func callAIChat(provider, prompt, apiKey string) (string, error) {
+	switch provider {
+	case "openai":
+		return callOpenAIChat(prompt, apiKey)
+	case "claude":
+		return callClaudeChat(prompt, apiKey)
+	default:
+		return callGeminiChat(prompt, apiKey)
+	}
+}
+
+// statementTranslation is the JSON the model answers with
+type statementTranslation struct {
+	Statement string            `json:"statement"`
+	Parts     map[string]string `json:"parts,omitempty"` // keyed by part number
+}
+
+func buildTranslationPrompt(p Problem, locale string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Translate this coding interview problem statement from %s to %s (BCP 47 locale).\n", p.defaultLocale(), locale)
+	sb.WriteString("Keep Markdown formatting, code, identifiers, variable names, numbers, sample input/output and constraints exactly as they are. Translate only the prose.\n\n")
+	sb.WriteString("Respond with JSON only, no markdown fences, in this shape:\n")
+	sb.WriteString(`{"statement": "...", "parts": {"2": "...", "3": "..."}}` + "\n\n")
+	sb.WriteString("STATEMENT:\n" + p.Statement + "\n")
+	for _, part := range p.Parts {
+		fmt.Fprintf(&sb, "\nPART %d:\n%s\n", part.PartNumber, part.Statement)
+	}
+	return sb.String()
+}
+
+func parseTranslationResponse(text string) (statementTranslation, error) {
+	var t statementTranslation
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return t, fmt.Errorf("no JSON object in response")
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &t); err != nil {
+		return t, fmt.Errorf("invalid JSON in response: %v", err)
+	}
+	if strings.TrimSpace(t.Statement) == "" {
+		return t, fmt.Errorf("response has no statement")
+	}
+	return t, nil
+}
+
+// translateProblem adds AI translations of the statement and parts for each
+// locale. Locales that already have a translation are skipped unless
+// overwrite is set. It returns the locales that were translated.
+func translateProblem(p *Problem, locales []string, overwrite bool, provider, apiKey string) ([]string, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("API key is required for %s", provider)
+	}
+	def := p.defaultLocale()
+	var done []string
+	for _, l := range locales {
+		tag := normalizeLocale(l)
+		if tag == "" {
+			return done, fmt.Errorf("invalid locale %q", l)
+		}
+		if tag == def || (!overwrite && p.Statements[tag] != "") {
+			continue
+		}
+
+		log.Printf("Translating problem %s to %s with %s", p.ID, tag, provider)
+		text, err := callAIChat(provider, buildTranslationPrompt(*p, tag), apiKey)
+		if err != nil {
+			return done, fmt.Errorf("translation to %s failed: %v", tag, err)
+		}
+		t, err := parseTranslationResponse(text)
+		if err != nil {
+			return done, fmt.Errorf("translation to %s failed: %v", tag, err)
+		}
+
+		if p.Statements == nil {
+			p.Statements = make(map[string]string)
+		}
+		p.Statements[tag] = strings.TrimSpace(t.Statement)
+		for i := range p.Parts {
+			if s := strings.TrimSpace(t.Parts[strconv.Itoa(p.Parts[i].PartNumber)]); s != "" {
+				if p.Parts[i].Statements == nil {
+					p.Parts[i].Statements = make(map[string]string)
+				}
+				p.Parts[i].Statements[tag] = s
+			}
+		}
+		done = append(done, tag)
+	}
+	return done, nil
+}
+
+// translateProblemHandler handles POST /api/problems/{id}/translate
+//
+//	{"locales": ["es", "pt-BR"], "overwrite": false, "provider": "", "apiKey": ""}
+func translateProblemHandler(w http.ResponseWriter, r *http.Request, problemID string) {
+	if decoded, err := url.QueryUnescape(problemID); err == nil {
+		problemID = decoded
+	}
+	if !isValidProblemID(problemID) {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Locales   []string `json:"locales"`
+		Overwrite bool     `json:"overwrite"`
+		Provider  string   `json:"provider"`
+		APIKey    string   `json:"apiKey"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", 400)
+		return
+	}
+	if len(req.Locales) == 0 {
+		http.Error(w, "locales is required", 400)
+		return
+	}
+	provider, apiKey := aiProviderAndKey(req.Provider, req.APIKey)
+	if apiKey == "" {
+		http.Error(w, fmt.Sprintf("API key is required for %s", provider), 400)
+		return
+	}
+
+	current, ok := readProblemManifest(dataDir, problemID)
+	if !ok {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+
+	// The provider calls are slow, so translate outside the manifest lock
+	// and merge the result into whatever is current afterwards
+	translated := cloneStatements(current)
+	done, err := translateProblem(&translated, req.Locales, req.Overwrite, provider, apiKey)
+	if err != nil && len(done) == 0 {
+		log.Printf("Translation of %s failed: %v", problemID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+		return
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	latest, ok := readProblemManifest(dataDir, problemID)
+	if !ok {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+	if latest.Statement != current.Statement || len(latest.Parts) != len(current.Parts) {
+		http.Error(w, "Problem statement changed during translation; retry", http.StatusConflict)
+		return
+	}
+	updated := cloneStatements(latest)
+	for _, tag := range done {
+		if updated.Statements == nil {
+			updated.Statements = make(map[string]string)
+		}
+		updated.Statements[tag] = translated.Statements[tag]
+		for i := range updated.Parts {
+			if s := translated.Parts[i].Statements[tag]; s != "" {
+				if updated.Parts[i].Statements == nil {
+					updated.Parts[i].Statements = make(map[string]string)
+				}
+				updated.Parts[i].Statements[tag] = s
+			}
+		}
+	}
+
+	if len(done) > 0 {
+		if err := saveProblemManifest(updated); err != nil {
+			log.Printf("Failed to save translations of %s: %v", problemID, err)
+			http.Error(w, "Failed to save problem", 500)
+			return
+		}
+		recordAudit(r, "problem.translate", problemID, latest, updated, map[string]interface{}{"provider": provider, "locales": done})
+	}
+
+	resp := map[string]interface{}{
+		"status":     "success",
+		"translated": done,
+		"locales":    problemLocales(updated),
+	}
+	if err != nil {
+		resp["error"] = err.Error() // some locales failed after others succeeded
+	}
+	w.Header().Set("ETag", problemETag(updated))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// cloneStatements copies the translation maps of p so they can be modified
+// without touching the cached problem
+func cloneStatements(p Problem) Problem {
+	p.Statements = copyStringMap(p.Statements)
+	parts := make([]Part, len(p.Parts))
+	for i, part := range p.Parts {
+		part.Statements = copyStringMap(part.Statements)
+		parts[i] = part
+	}
+	p.Parts = parts
+	return p
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
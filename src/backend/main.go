@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,8 +13,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,18 +32,26 @@ var AppEnv = "dev"
 
 // Config holds application configuration
 type Config struct {
-	AppEnv                  string
-	Port                    string
-	FrontendOrigin          string
-	ExecutorMode            string
-	LogLevel                string
-	EnablePairProgramming   bool
-	EnableSystemDesignAgent bool
-	EnableWebSearch         bool
-	DefaultAIProvider       string
-	MaxConcurrentExecutions int
-	ExecutionTimeoutSeconds int
-	MaxMemoryMB             int
+	AppEnv                   string
+	Port                     string
+	FrontendOrigin           string
+	ExecutorMode             string
+	LogLevel                 string
+	EnablePairProgramming    bool
+	EnableSystemDesignAgent  bool
+	EnableWebSearch          bool
+	DefaultAIProvider        string
+	MaxConcurrentExecutions  int
+	ExecutionTimeoutSeconds  int
+	MaxMemoryMB              int
+	TrashRetentionDays       int
+	MaxUploadFileMB          int
+	UploadQuotaMB            int
+	MaxKernels               int
+	KernelIdleTimeoutMinutes int
+	KernelCellTimeoutSeconds int
+	GoModulesDir             string // allowlist module Go programs build against (see gomodules.go)
+	GoBuildCache             string // GOCACHE shared by Go runs
 }
 
 // Global configuration instance
@@ -48,18 +60,26 @@ var config Config
 // Initialize configuration from environment variables
 func initConfig() {
 	config = Config{
-		AppEnv:                  getEnvOrDefault("APP_ENV", AppEnv),
-		Port:                    getEnvOrDefault("PORT", "8080"),
-		FrontendOrigin:          getEnvOrDefault("FRONTEND_ORIGIN", "http://localhost:5173"),
-		ExecutorMode:            getEnvOrDefault("EXECUTOR_MODE", "stub"),
-		LogLevel:                getEnvOrDefault("LOG_LEVEL", "info"),
-		EnablePairProgramming:   getEnvBoolOrDefault("ENABLE_PAIR_PROGRAMMING", true),
-		EnableSystemDesignAgent: getEnvBoolOrDefault("ENABLE_SYSTEM_DESIGN_AGENT", true),
-		EnableWebSearch:         getEnvBoolOrDefault("ENABLE_WEB_SEARCH", true),
-		DefaultAIProvider:       getEnvOrDefault("DEFAULT_AI_PROVIDER", "gemini"),
-		MaxConcurrentExecutions: getEnvIntOrDefault("MAX_CONCURRENT_EXECUTIONS", 10),
-		ExecutionTimeoutSeconds: getEnvIntOrDefault("EXECUTION_TIMEOUT_SECONDS", 60),
-		MaxMemoryMB:             getEnvIntOrDefault("MAX_MEMORY_MB", 512),
+		AppEnv:                   getEnvOrDefault("APP_ENV", AppEnv),
+		Port:                     getEnvOrDefault("PORT", "8080"),
+		FrontendOrigin:           getEnvOrDefault("FRONTEND_ORIGIN", "http://localhost:5173"),
+		ExecutorMode:             getEnvOrDefault("EXECUTOR_MODE", "stub"),
+		LogLevel:                 getEnvOrDefault("LOG_LEVEL", "info"),
+		EnablePairProgramming:    getEnvBoolOrDefault("ENABLE_PAIR_PROGRAMMING", true),
+		EnableSystemDesignAgent:  getEnvBoolOrDefault("ENABLE_SYSTEM_DESIGN_AGENT", true),
+		EnableWebSearch:          getEnvBoolOrDefault("ENABLE_WEB_SEARCH", true),
+		DefaultAIProvider:        getEnvOrDefault("DEFAULT_AI_PROVIDER", "gemini"),
+		MaxConcurrentExecutions:  getEnvIntOrDefault("MAX_CONCURRENT_EXECUTIONS", 10),
+		ExecutionTimeoutSeconds:  getEnvIntOrDefault("EXECUTION_TIMEOUT_SECONDS", 60),
+		MaxMemoryMB:              getEnvIntOrDefault("MAX_MEMORY_MB", 512),
+		TrashRetentionDays:       getEnvIntOrDefault("TRASH_RETENTION_DAYS", 30),
+		MaxUploadFileMB:          getEnvIntOrDefault("MAX_UPLOAD_FILE_MB", 100),
+		UploadQuotaMB:            getEnvIntOrDefault("UPLOAD_QUOTA_MB", 500),
+		MaxKernels:               getEnvIntOrDefault("MAX_KERNELS", 10),
+		KernelIdleTimeoutMinutes: getEnvIntOrDefault("KERNEL_IDLE_TIMEOUT_MINUTES", 30),
+		KernelCellTimeoutSeconds: getEnvIntOrDefault("KERNEL_CELL_TIMEOUT_SECONDS", 300),
+		GoModulesDir:             getEnvOrDefault("GO_MODULES_DIR", "./data/go-modules"),
+		GoBuildCache:             getEnvOrDefault("GO_BUILD_CACHE", filepath.Join(os.TempDir(), "ceesarcode-gocache")),
 	}
 
 	log.Printf("CeesarCode starting in %s environment", config.AppEnv)
@@ -140,9 +160,10 @@ type SuggestedChange struct {
 }
 
 type Part struct {
-	PartNumber int               `json:"partNumber"` // 1, 2, 3, etc.
-	Statement  string            `json:"statement"`  // Part-specific statement
-	Stub       map[string]string `json:"stub"`       // Part-specific code stubs (optional, defaults to main stub)
+	PartNumber int               `json:"partNumber"`           // 1, 2, 3, etc.
+	Statement  string            `json:"statement"`            // Part-specific statement
+	Stub       map[string]string `json:"stub"`                 // Part-specific code stubs (optional, defaults to main stub)
+	Statements map[string]string `json:"statements,omitempty"` // Translations of Statement keyed by locale
 }
 
 type Problem struct {
@@ -155,6 +176,29 @@ type Problem struct {
 	DrawingData string            `json:"DrawingData,omitempty"` // Excalidraw drawing data (JSON string)
 	IsMultiPart bool              `json:"IsMultiPart,omitempty"` // Whether this is a multi-part question
 	Parts       []Part            `json:"Parts,omitempty"`       // Additional parts (Part 2, 3, etc.) - Part 1 uses Statement field
+
+	Difficulty       string                    `json:"Difficulty,omitempty"`       // "easy", "medium" or "hard"
+	Tags             []string                  `json:"Tags,omitempty"`             // Topic tags, e.g. "graph", "dp", "design"
+	Company          string                    `json:"Company,omitempty"`          // Company the question was written for
+	EstimatedMinutes int                       `json:"EstimatedMinutes,omitempty"` // Expected time to solve in an interview
+	Author           string                    `json:"Author,omitempty"`           // Person or generator that wrote the question
+	TimeLimitMs      int                       `json:"TimeLimitMs,omitempty"`      // Per-test time limit in milliseconds (0 = server default)
+	MemoryLimitMB    int                       `json:"MemoryLimitMB,omitempty"`    // Memory limit in megabytes (0 = server default)
+	LanguageLimits   map[string]ResourceLimits `json:"LanguageLimits,omitempty"`   // Per-language overrides of TimeLimitMs/MemoryLimitMB
+	InstalledOnly    bool                      `json:"InstalledOnly,omitempty"`    // Offer only the Languages whose toolchain is installed
+	BuildProfiles    BuildProfiles             `json:"BuildProfiles,omitempty"`    // Flags by profile and language, replacing the server's (see buildFlagsFor)
+
+	Examples    []Example    `json:"Examples,omitempty"`    // Worked examples from the statement; the first public tests mirror them
+	Constraints []Constraint `json:"Constraints,omitempty"` // Input bounds, e.g. "1 ≤ n ≤ 10^5"
+	Validator   *Validator   `json:"Validator,omitempty"`   // Program every test input must pass
+	TestGroups  []TestGroup  `json:"TestGroups,omitempty"`  // Tests produced by generator programs; only the recipe is stored here
+
+	DefaultLocale string            `json:"DefaultLocale,omitempty"` // BCP 47 locale of Statement and part statements ("en" when empty)
+	Statements    map[string]string `json:"Statements,omitempty"`    // Translations of Statement keyed by locale, e.g. "es", "pt-BR"
+	Locale        string            `json:"Locale,omitempty"`        // Locale Statement is in; only set in API responses, never stored
+	SampleTests   []Example         `json:"SampleTests,omitempty"`   // Tests flagged as samples in tests.json; only set in API responses, never stored
+
+	AvailableLanguages []string `json:"AvailableLanguages,omitempty"` // Languages whose toolchain is installed, for InstalledOnly problems; only set in API responses, never stored
 }
 
 type TestCase struct {
@@ -164,34 +208,56 @@ type TestCase struct {
 type SubmitReq struct {
 	ProblemID, Language string
 	Files               map[string]string
-	PartNumber          int `json:"PartNumber,omitempty"` // 0 for single-part or part 1, 1+ for additional parts
+	PartNumber          int    `json:"PartNumber,omitempty"`   // 0 for single-part or part 1, 1+ for additional parts
+	EntryPoint          string `json:"entryPoint,omitempty"`   // file the program starts from; found by name when empty
+	BuildProfile        string `json:"buildProfile,omitempty"` // flags to build with; "release" when empty
 }
 type ExecJob struct {
 	SubmissionID  string `json:"submission_id"`
 	ProblemBundle string `json:"problem_bundle"`
 	SubmissionDir string `json:"submission_dir"`
 	Language      string `json:"language"`
+	TimeLimitMs   int    `json:"time_limit_ms,omitempty"`   // per-test limit from the problem manifest
+	MemoryLimitMB int    `json:"memory_limit_mb,omitempty"` // memory limit from the problem manifest
+
+	TestOrder        []string       `json:"test_order,omitempty"`          // order of the tests from tests.json
+	TestTimeLimitsMs map[string]int `json:"test_time_limits_ms,omitempty"` // per-test overrides of TimeLimitMs
+	UploadsDir       string         `json:"uploads_dir,omitempty"`         // datasets mounted read-only in data/
+
+	EntryPoint string   `json:"entry_point,omitempty"` // file the program starts from, relative to submission_dir
+	Sources    []string `json:"sources,omitempty"`     // files compiled together (C, C++, Java, Swift)
+	MainClass  string   `json:"main_class,omitempty"`  // fully qualified class of the Java entry point
+
+	CompileFlags []string `json:"compile_flags,omitempty"` // flags of the build profile
+	RunFlags     []string `json:"run_flags,omitempty"`
+	Env          []string `json:"env,omitempty"`       // NAME=value pairs set when the program runs
+	BuildEnv     []string `json:"build_env,omitempty"` // NAME=value pairs set while it is built (Go: see goBuildSettings)
 }
 
 type AgentRequest struct {
-	Company            string `json:"company"`
-	Role               string `json:"role"`
-	Level              string `json:"level"`
-	Count              int    `json:"count,omitempty"`
-	JobDescription     string `json:"jobDescription,omitempty"`
-	CompanyDescription string `json:"companyDescription,omitempty"`
-	InterviewType      string `json:"interviewType,omitempty"`
-	Provider           string `json:"provider,omitempty"`         // "gemini", "openai", "claude"
-	APIKey             string `json:"apiKey,omitempty"`           // API key from frontend
-	DefaultLanguage    string `json:"defaultLanguage,omitempty"`  // Default language for generated questions (e.g., "python", "javascript", "java")
-	QuestionType       string `json:"questionType,omitempty"`     // "coding" or "system_design"
-	IncludeMultiPart   bool   `json:"includeMultiPart,omitempty"` // Whether to generate multi-part questions
+	Company            string   `json:"company"`
+	Role               string   `json:"role"`
+	Level              string   `json:"level"`
+	Count              int      `json:"count,omitempty"`
+	JobDescription     string   `json:"jobDescription,omitempty"`
+	CompanyDescription string   `json:"companyDescription,omitempty"`
+	InterviewType      string   `json:"interviewType,omitempty"`
+	Provider           string   `json:"provider,omitempty"`         // "gemini", "openai", "claude"
+	APIKey             string   `json:"apiKey,omitempty"`           // API key from frontend
+	DefaultLanguage    string   `json:"defaultLanguage,omitempty"`  // Default language for generated questions (e.g., "python", "javascript", "java")
+	QuestionType       string   `json:"questionType,omitempty"`     // "coding" or "system_design"
+	IncludeMultiPart   bool     `json:"includeMultiPart,omitempty"` // Whether to generate multi-part questions
+	Locales            []string `json:"locales,omitempty"`          // Also translate the statements into these locales
+	CreateCollection   bool     `json:"createCollection,omitempty"` // Also save the generated problems as a collection, in order
+	CollectionTitle    string   `json:"collectionTitle,omitempty"`  // Title of that collection (defaults to "{company} {level} {role} loop")
 }
 
 type AgentResponse struct {
-	Status   string    `json:"status"`
-	Problems []Problem `json:"problems,omitempty"`
-	Message  string    `json:"message,omitempty"`
+	Status     string      `json:"status"`
+	Problems   []Problem   `json:"problems,omitempty"`
+	Collection *Collection `json:"collection,omitempty"` // set when createCollection was requested
+	Message    string      `json:"message,omitempty"`
+	Errors     []string    `json:"errors,omitempty"` // problems that were generated but not saved, and why
 }
 
 var dataDir = "./data/problems"
@@ -201,6 +267,18 @@ func main() {
 	// Initialize configuration from environment
 	initConfig()
 
+	// Serve problems from memory and follow edits made directly in dataDir
+	problemCache = newProblemIndex(dataDir)
+	if err := problemCache.watch(); err != nil {
+		log.Printf("Problem index: filesystem watching disabled: %v", err)
+	}
+	go purgeExpiredTrashLoop()
+	go expireIdleKernelsLoop()
+	go func() {
+		discoverToolchains()
+		warmGoBuildCache()
+	}()
+
 	mux := http.NewServeMux()
 
 	// Health check endpoint
@@ -210,6 +288,13 @@ func main() {
 	// More specific routes first
 	mux.HandleFunc("/api/problems/create", createProblem)
 	mux.HandleFunc("/api/problems/clear", clearAllProblems)
+	mux.HandleFunc("/api/problems/export", exportProblems)
+	mux.HandleFunc("/api/problems/import", importProblems)
+	mux.HandleFunc("/api/problems/trash", handleTrashRoutes)
+	mux.HandleFunc("/api/problems/trash/", handleTrashRoutes)
+	mux.HandleFunc("/api/audit", getAuditLog)
+	mux.HandleFunc("/api/collections", handleCollectionRoutes)
+	mux.HandleFunc("/api/collections/", handleCollectionRoutes)
 	// Handle /api/problems/{id} routes (including DELETE)
 	mux.HandleFunc("/api/problems/", handleProblemsRoutes)
 	// Handle /api/problems (GET only for listing, DELETE goes to handleProblemsRoutes)
@@ -227,6 +312,10 @@ func main() {
 	mux.HandleFunc("/api/submit", submit)
 	mux.HandleFunc("/api/run", runCode)
 	mux.HandleFunc("/api/upload", uploadFile)
+	mux.HandleFunc("/api/kernels", handleKernelRoutes)
+	mux.HandleFunc("/api/kernels/", handleKernelRoutes)
+	mux.HandleFunc("/api/languages", handleLanguageRoutes)
+	mux.HandleFunc("/api/languages/", handleLanguageRoutes)
 	mux.HandleFunc("/api/agent/generate", generateQuestions)
 	mux.HandleFunc("/api/agent/clean", cleanAIGuestions)
 
@@ -268,7 +357,13 @@ func handleProblemRoutes(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) > 1 && parts[1] == "files" {
-		if r.Method == http.MethodGet {
+		if r.Method == http.MethodGet && len(parts) == 4 && parts[3] == "preview" {
+			previewUploadedFile(w, r, parts[0], parts[2])
+			return
+		} else if r.Method == http.MethodGet && len(parts) > 2 && parts[2] != "" {
+			serveUploadedFile(w, r, parts[0], strings.Join(parts[2:], "/"))
+			return
+		} else if r.Method == http.MethodGet {
 			listUploadedFiles(w, r, parts[0])
 			return
 		} else if r.Method == http.MethodDelete && len(parts) > 2 {
@@ -276,7 +371,10 @@ func handleProblemRoutes(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	} else if len(parts) > 1 && parts[1] == "testcases" {
-		if r.Method == http.MethodPut {
+		if len(parts) > 2 || r.Method == http.MethodPost {
+			// Handle single test cases and archive uploads
+			handleTestCaseRoutes(w, r, parts[0], parts[2:])
+		} else if r.Method == http.MethodPut {
 			// Handle test cases update
 			r.URL.Path = "/api/problem/" + parts[0] // Simplify path for updateTestCases
 			updateTestCases(w, r)
@@ -330,6 +428,68 @@ func handleProblemsRoutes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Handle /api/problems/{id}/export
+	if len(parts) == 2 && parts[1] == "export" && r.Method == http.MethodGet {
+		exportProblem(w, r, parts[0])
+		return
+	}
+
+	// Handle /api/problems/{id}/translate
+	if len(parts) == 2 && parts[1] == "translate" && r.Method == http.MethodPost {
+		translateProblemHandler(w, r, parts[0])
+		return
+	}
+
+	// Handle /api/problems/{id}/validate
+	if len(parts) == 2 && parts[1] == "validate" && r.Method == http.MethodPost {
+		validateStoredTests(w, r, parts[0])
+		return
+	}
+
+	// Handle /api/problems/{id}/solutions[/{name}]
+	if (len(parts) == 2 || len(parts) == 3) && parts[1] == "solutions" {
+		name := ""
+		if len(parts) == 3 {
+			name = parts[2]
+		}
+		handleProgramRoutes(w, r, solutionPrograms, parts[0], name)
+		return
+	}
+
+	// Handle /api/problems/{id}/generators[/{name}]
+	if (len(parts) == 2 || len(parts) == 3) && parts[1] == "generators" {
+		name := ""
+		if len(parts) == 3 {
+			name = parts[2]
+		}
+		handleProgramRoutes(w, r, generatorPrograms, parts[0], name)
+		return
+	}
+
+	// Handle /api/problems/{id}/generate-tests
+	if len(parts) == 2 && parts[1] == "generate-tests" && r.Method == http.MethodPost {
+		generateTestsHandler(w, r, parts[0])
+		return
+	}
+
+	// Handle /api/problems/{id}/outputs
+	if len(parts) == 2 && parts[1] == "outputs" && r.Method == http.MethodPost {
+		generateOutputsHandler(w, r, parts[0])
+		return
+	}
+
+	// Handle /api/problems/{id}/history
+	if len(parts) == 2 && parts[1] == "history" && r.Method == http.MethodGet {
+		getProblemHistory(w, r, parts[0])
+		return
+	}
+
+	// Handle /api/problems/{id} PUT/PATCH
+	if len(parts) == 1 && (r.Method == http.MethodPut || r.Method == http.MethodPatch) {
+		editProblem(w, r, parts[0])
+		return
+	}
+
 	// Handle /api/problems/{id} DELETE
 	if len(parts) == 1 && parts[0] != "" && r.Method == http.MethodDelete {
 		log.Printf("Deleting problem: %s", parts[0])
@@ -351,35 +511,36 @@ func updateDrawing(w http.ResponseWriter, r *http.Request, problemID string) {
 		return
 	}
 
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
 	// Read existing problem
-	problemPath := filepath.Join(dataDir, problemID, "problem.json")
-	data, err := os.ReadFile(problemPath)
-	if err != nil {
+	problem, ok := readProblemManifest(dataDir, problemID)
+	if !ok {
 		http.Error(w, "Problem not found", http.StatusNotFound)
 		return
 	}
 
-	var problem Problem
-	if err := json.Unmarshal(data, &problem); err != nil {
-		http.Error(w, "Failed to parse problem", http.StatusInternalServerError)
+	// Drawings are autosaved, so If-Match is optional here
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, problemETag(problem)) {
+		w.Header().Set("ETag", problemETag(problem))
+		http.Error(w, "Problem was modified by someone else; reload it and retry", http.StatusPreconditionFailed)
 		return
 	}
 
 	// Update drawing data
+	before := problem
 	problem.DrawingData = req.DrawingData
 
 	// Save updated problem
-	updatedData, err := json.MarshalIndent(problem, "", "  ")
-	if err != nil {
-		http.Error(w, "Failed to serialize problem", http.StatusInternalServerError)
-		return
-	}
-
-	if err := os.WriteFile(problemPath, updatedData, 0644); err != nil {
+	if err := saveProblemManifest(problem); err != nil {
+		log.Printf("Failed to save drawing for %s: %v", problemID, err)
 		http.Error(w, "Failed to save problem", http.StatusInternalServerError)
 		return
 	}
+	recordAudit(r, "drawing.update", problemID, before, problem, nil)
 
+	w.Header().Set("ETag", problemETag(problem))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
@@ -400,49 +561,55 @@ func deleteProblem(w http.ResponseWriter, r *http.Request, problemID string) {
 		return
 	}
 
-	// Delete the entire problem directory
-	if err := os.RemoveAll(problemPath); err != nil {
+	// Move the problem directory to the trash; it can be restored until it expires
+	before := loadProblem(problemID)
+	entry, err := moveToTrash(problemID, "deleted")
+	if err != nil {
 		log.Printf("Error deleting problem %s: %v", problemID, err)
 		http.Error(w, "Failed to delete problem", http.StatusInternalServerError)
 		return
 	}
 
+	recordAudit(r, "problem.delete", problemID, before, nil, map[string]interface{}{"reason": entry.Reason, "trashId": entry.TrashID})
+
 	log.Printf("Successfully deleted problem: %s", problemID)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "success",
+		"trashId":   entry.TrashID,
+		"expiresAt": entry.ExpiresAt,
+	})
 }
 
 func listProblems(w http.ResponseWriter, r *http.Request) {
 	// Handle GET request for listing problems
-	ents, err := os.ReadDir(dataDir)
+	query, err := parseProblemQuery(r.URL.Query())
 	if err != nil {
-		log.Printf("Error reading dataDir %s: %v", dataDir, err)
-		http.Error(w, "Internal server error", 500)
+		http.Error(w, err.Error(), 400)
 		return
 	}
-	var out []Problem
-	for _, e := range ents {
-		if !e.IsDir() {
-			continue
-		}
 
-		// Skip directories that are not problems (like uploads)
-		if e.Name() == "uploads" {
-			continue
-		}
+	page, total, next := query.apply(problemCache.list())
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
 
-		p := loadProblem(e.Name())
-		if p.ID != "" {
-			out = append(out, p)
+	if query.Summary {
+		summaries := make([]ProblemSummary, 0, len(page))
+		for _, p := range page {
+			summaries = append(summaries, summarizeProblem(p))
 		}
+		json.NewEncoder(w).Encode(summaries)
+		return
 	}
 
-	// If no problems found, return empty array instead of null
-	if out == nil {
-		out = []Problem{}
+	locales := requestedLocales(r)
+	for i := range page {
+		page[i] = page[i].localized(locales)
 	}
-
-	json.NewEncoder(w).Encode(out)
+	w.Header().Add("Vary", "Accept-Language")
+	json.NewEncoder(w).Encode(page)
 }
 func getProblem(w http.ResponseWriter, r *http.Request) {
 	id := strings.TrimPrefix(r.URL.Path, "/api/problem/")
@@ -450,28 +617,30 @@ func getProblem(w http.ResponseWriter, r *http.Request) {
 	if decoded, err := url.QueryUnescape(id); err == nil {
 		id = decoded
 	}
-	p := loadProblem(id)
-	if p.ID == "" {
+	p, ok := problemCache.get(id)
+	if !ok {
 		http.Error(w, "not found", 404)
 		return
 	}
+	etag := problemETag(p)
+	p = p.localized(requestedLocales(r))
+	p.SampleTests = sampleTests(p)
+	if p.InstalledOnly {
+		p.AvailableLanguages = installedLanguages(p.Languages)
+	}
+	w.Header().Set("ETag", etag)
+	setLocaleHeaders(w, p.Locale)
+	// The ETag covers the manifest only, so sample tests and installed
+	// languages can't be cached
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) && len(p.SampleTests) == 0 && !p.InstalledOnly {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	json.NewEncoder(w).Encode(p)
 }
+// loadProblem reads a manifest straight from disk, bypassing the index
 func loadProblem(id string) Problem {
-	manifestPath := filepath.Join(dataDir, id, "manifest.json")
-	b, err := os.ReadFile(manifestPath)
-	if err != nil {
-		// Only log if it's not a missing file (which is expected for some directories)
-		if !os.IsNotExist(err) {
-			log.Printf("Error loading problem %s: %v", id, err)
-		}
-		return Problem{}
-	}
-	var p Problem
-	if err := json.Unmarshal(b, &p); err != nil {
-		log.Printf("Error unmarshaling problem %s: %v", id, err)
-		return Problem{}
-	}
+	p, _ := readProblemManifest(dataDir, id)
 	return p
 }
 func submit(w http.ResponseWriter, r *http.Request) {
@@ -488,6 +657,14 @@ func submit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("Decoded request: %+v", req)
+	entryPoint, err := checkEntryPoint(req.EntryPoint, req.Files)
+	if err == nil {
+		_, err = cleanProject(req.Files)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
 
 	// Determine test case directory based on part number
 	pdir := filepath.Join(dataDir, req.ProblemID, "v1")
@@ -507,8 +684,10 @@ func submit(w http.ResponseWriter, r *http.Request) {
 	subID := uuid.NewString()
 	sdir := filepath.Join(os.TempDir(), "ceesarcode-submissions", subID)
 	os.MkdirAll(sdir, 0o755)
-	for name, content := range req.Files {
-		os.WriteFile(filepath.Join(sdir, filepath.Base(name)), []byte(content), 0o644)
+	if err := writeProject(sdir, req.Files); err != nil {
+		log.Printf("Failed to write submission %s: %v", subID, err)
+		http.Error(w, "Internal server error", 500)
+		return
 	}
 	if len(req.Files) == 0 {
 		os.WriteFile(filepath.Join(sdir, "code.txt"), []byte(""), 0o644)
@@ -519,7 +698,46 @@ func submit(w http.ResponseWriter, r *http.Request) {
 	log.Printf("sdir: %s", sdir)
 	log.Printf("abs(sdir): %s", abs(sdir))
 	log.Printf("language: %s", req.Language)
-	job := ExecJob{SubmissionID: subID, ProblemBundle: abs(pdir), SubmissionDir: abs(sdir), Language: req.Language}
+	problem := loadProblem(req.ProblemID)
+	if problem.InstalledOnly && !languageAvailable(req.Language) {
+		http.Error(w, fmt.Sprintf("%s is not installed on this server", req.Language), 422)
+		return
+	}
+	build, err := problem.buildFlagsFor(req.BuildProfile, req.Language)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	limits := problem.limitsFor(req.Language)
+	job := ExecJob{SubmissionID: subID, ProblemBundle: abs(pdir), SubmissionDir: abs(sdir), Language: req.Language,
+		TimeLimitMs: limits.TimeLimitMs, MemoryLimitMB: limits.MemoryLimitMB,
+		CompileFlags: build.CompileFlags, RunFlags: build.RunFlags, Env: build.Env}
+	if publicDir := filepath.Join(pdir, "public"); len(readTestMeta(publicDir)) > 0 {
+		job.TestOrder = listTestNames(publicDir)
+		job.TestTimeLimitsMs = testTimeLimits(publicDir)
+	}
+	if uploads := uploadsDir(req.ProblemID); uploadsUsage(req.ProblemID) > 0 {
+		job.UploadsDir = abs(uploads)
+	}
+	if req.Language == "go" {
+		// Build against the allowlist, offline, like /api/run does
+		if err := prepareGoModule(sdir); err != nil {
+			http.Error(w, err.Error(), 422)
+			return
+		}
+		job.BuildEnv = goBuildSettings(sdir)
+	}
+	if entry, err := findEntryPoint(sdir, req.Language, entryPoint); err == nil {
+		rel, _ := filepath.Rel(sdir, entry)
+		job.EntryPoint = filepath.ToSlash(rel)
+		switch req.Language {
+		case "c", "cpp", "java", "swift":
+			job.Sources = projectSources(sdir, req.Language)
+		}
+		if req.Language == "java" {
+			job.MainClass = javaMainClass(entry)
+		}
+	}
 	log.Printf("job struct: %+v", job)
 
 	// Check if submission directory exists and contains files
@@ -575,9 +793,14 @@ func runCode(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Language string            `json:"language"`
-		Files    map[string]string `json:"files"`
-		Input    string            `json:"input,omitempty"`
+		Language     string            `json:"language"`
+		Files        map[string]string `json:"files"`
+		Input        string            `json:"input,omitempty"`
+		ProblemID    string            `json:"problemId,omitempty"`    // applies the problem's limits and mounts its uploads
+		EntryPoint   string            `json:"entryPoint,omitempty"`   // file the program starts from; found by name when empty
+		BuildProfile string            `json:"buildProfile,omitempty"` // flags to build with; "release" when empty
+		Debug        bool              `json:"debug,omitempty"`        // build with the debug profile and report crashes in detail
+		Profile      bool              `json:"profile,omitempty"`      // sample the program's CPU use, see profiling.go
 	}
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
@@ -590,59 +813,71 @@ func runCode(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("runCode request: language=%s, files=%v", req.Language, req.Files)
 
+	if len(req.Files) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]string{"error": "No files provided"})
+		return
+	}
+	entryPoint, err := checkEntryPoint(req.EntryPoint, req.Files)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
 	// Create temporary directory for code execution
 	subID := uuid.NewString()
 	sdir := filepath.Join(os.TempDir(), "ceesarcode-run", subID)
 	os.MkdirAll(sdir, 0755)
 	defer os.RemoveAll(sdir) // Clean up after execution
 
-	// Write code files
-	var mainFile string
-	for name, content := range req.Files {
-		filePath := filepath.Join(sdir, filepath.Base(name))
-		os.WriteFile(filePath, []byte(content), 0644)
-		if mainFile == "" {
-			mainFile = filePath
-		}
+	// Write code files, keeping their directories
+	if err := writeProject(sdir, req.Files); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
 	}
 
-	if len(req.Files) == 0 {
+	// Resolve limits from the problem when the run belongs to one
+	// and mount its uploaded datasets
+	problem := Problem{}
+	var uploads string
+	if req.ProblemID != "" {
+		if p := loadProblem(req.ProblemID); p.ID != "" {
+			if p.InstalledOnly && !languageAvailable(req.Language) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(422)
+				json.NewEncoder(w).Encode(map[string]string{"error": req.Language + " is not installed on this server"})
+				return
+			}
+			problem = p
+			uploads = p.ID
+		}
+	}
+	if req.Debug && req.BuildProfile == "" {
+		req.BuildProfile = "debug"
+	}
+	build, err := problem.buildFlagsFor(req.BuildProfile, req.Language)
+	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(400)
-		json.NewEncoder(w).Encode(map[string]string{"error": "No files provided"})
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 
 	// Execute code based on language
-	var stdout, stderr string
-	var execErr error
-
-	switch req.Language {
-	case "python":
-		stdout, stderr, execErr = runPythonCode(sdir, req.Input)
-	case "javascript":
-		stdout, stderr, execErr = runJavaScriptCode(sdir, req.Input)
-	case "typescript":
-		stdout, stderr, execErr = runTypeScriptCode(sdir, req.Input)
-	case "java":
-		stdout, stderr, execErr = runJavaCode(sdir, req.Input)
-	case "cpp":
-		stdout, stderr, execErr = runCppCode(sdir, req.Input)
-	case "c":
-		stdout, stderr, execErr = runCCode(sdir, req.Input)
-	case "go":
-		stdout, stderr, execErr = runGoCode(sdir, req.Input)
-	case "rust":
-		stdout, stderr, execErr = runRustCode(sdir, req.Input)
-	case "swift":
-		stdout, stderr, execErr = runSwiftCode(sdir, req.Input)
-	case "ruby":
-		stdout, stderr, execErr = runRubyCode(sdir, req.Input)
-	case "bash", "sh":
-		stdout, stderr, execErr = runBashCode(sdir, req.Input)
-	case "sql":
-		stdout, stderr, execErr = runSqlCode(sdir, req.Input)
-	default:
+	opts := runOptionsFromLimits(problem.limitsFor(req.Language))
+	opts.Uploads = uploads
+	opts.EntryPoint = entryPoint
+	opts.Build = build
+	if req.Profile {
+		opts.Profile = &CPUProfile{}
+	}
+	stdout, stderr, execErr := runLanguage(req.Language, sdir, req.Input, opts)
+	if errors.Is(execErr, errUnsupportedLanguage) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(400)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Unsupported language: " + req.Language})
@@ -676,13 +911,70 @@ func runCode(w http.ResponseWriter, r *http.Request) {
 		result["error"] = stderr + "\n" + execErr.Error()
 	}
 
+	// Explain crashes; sanitizer reports need the sources, which are
+	// removed when runCode returns
+	if execErr != nil {
+		if crash := parseCrashReport(stdout+"\n"+stderr, execErr, sdir); crash != nil {
+			result["crash"] = crash
+		}
+	}
+	if opts.Profile != nil {
+		if opts.Profile.Profiler == "" && opts.Profile.Error == "" {
+			opts.Profile.Error = "profiling is not supported for " + req.Language
+		}
+		result["profile"] = opts.Profile
+	}
+
 	log.Printf("Returning result: result='%s', error='%s'", result["result"], result["error"])
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
 
-func runPythonCode(dir, input string) (stdout, stderr string, err error) {
+var errUnsupportedLanguage = errors.New("unsupported language")
+
+// errBuildFailed is wrapped by the runners' errors when the program didn't
+// compile; the compiler's output is in their stderr
+var errBuildFailed = errors.New("build failed")
+
+// runLanguage runs the program in dir with the runner for language
+func runLanguage(language, dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	if opts.Uploads != "" {
+		if opts.DataDir, err = mountUploads(opts.Uploads, dir); err != nil {
+			return "", "", err
+		}
+	}
+	switch language {
+	case "python":
+		return runPythonCode(dir, input, opts)
+	case "javascript":
+		return runJavaScriptCode(dir, input, opts)
+	case "typescript":
+		return runTypeScriptCode(dir, input, opts)
+	case "java":
+		return runJavaCode(dir, input, opts)
+	case "cpp":
+		return runCppCode(dir, input, opts)
+	case "c":
+		return runCCode(dir, input, opts)
+	case "go":
+		return runGoCode(dir, input, opts)
+	case "rust":
+		return runRustCode(dir, input, opts)
+	case "swift":
+		return runSwiftCode(dir, input, opts)
+	case "ruby":
+		return runRubyCode(dir, input, opts)
+	case "bash", "sh":
+		return runBashCode(dir, input, opts)
+	case "sql":
+		return runSqlCode(dir, input, opts)
+	default:
+		return "", "", fmt.Errorf("%w: %s", errUnsupportedLanguage, language)
+	}
+}
+
+func runPythonCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
 	log.Printf("runPythonCode: dir=%s, input length=%d", dir, len(input))
 
 	// List all files in directory for debugging
@@ -693,43 +985,53 @@ func runPythonCode(dir, input string) (stdout, stderr string, err error) {
 		}
 	}
 
-	mainPy := filepath.Join(dir, "Main.py")
-	if _, e := os.Stat(mainPy); e != nil {
-		log.Printf("Main.py not found, searching for .py files")
-		// Try other common names
-		files, _ := filepath.Glob(filepath.Join(dir, "*.py"))
-		if len(files) > 0 {
-			mainPy = files[0]
-			log.Printf("Found Python file: %s", mainPy)
-		} else {
-			log.Printf("No Python files found in %s", dir)
-			return "", "", fmt.Errorf("Python file not found in %s", dir)
-		}
-	} else {
-		log.Printf("Using Python file: %s", mainPy)
+	mainPy, err := findEntryPoint(dir, "python", opts.EntryPoint)
+	if err != nil {
+		log.Printf("No Python files found in %s: %v", dir, err)
+		return "", "", err
 	}
+	log.Printf("Using Python file: %s", mainPy)
 
 	// Find the correct Python command for this platform
 	pythonCmd := findPythonCommand()
+	args := withFlags(opts.Build.RunFlags, mainPy)
+	if opts.Profile != nil {
+		// The sampler runs the program
+		args = withFlags(opts.Build.RunFlags, "-c", pythonProfiler, mainPy)
+	}
 	// Handle py -3 on Windows (needs to be split)
 	var cmd *exec.Cmd
 	if strings.HasPrefix(pythonCmd, "py ") {
 		// Split "py -3" into ["py", "-3", mainPy]
 		parts := strings.Fields(pythonCmd)
-		cmd = exec.Command(parts[0], append(parts[1:], mainPy)...)
+		cmd = exec.Command(parts[0], append(parts[1:], args...)...)
 	} else {
-		cmd = exec.Command(pythonCmd, mainPy)
+		cmd = exec.Command(pythonCmd, args...)
 	}
 	cmd.Dir = dir
+	// Modules in subdirectories are imported from the project root
+	pythonPath := dir
+	if existing := os.Getenv("PYTHONPATH"); existing != "" {
+		pythonPath += string(os.PathListSeparator) + existing
+	}
+	cmd.Env = append(os.Environ(), "PYTHONPATH="+pythonPath)
 	cmd.Stdin = strings.NewReader(input)
+	if opts.Profile != nil {
+		profileCommand(cmd, dir)
+		cmd.Env = append(cmd.Env, fmt.Sprintf("CEESARCODE_PROFILE_RATE=%d", pythonSampleRateHz))
+	}
 
 	log.Printf("Executing: %s %s (in dir: %s)", pythonCmd, mainPy, dir)
 
 	// Use CombinedOutput to capture both stdout and stderr
 	// For Python, stdout and stderr are combined, but we'll treat it as stdout
 	// unless there's an actual error
-	out, execErr := cmd.CombinedOutput()
+	out, execErr := runWithLimits(cmd, opts)
 	outputStr := string(out)
+	if opts.Profile != nil {
+		opts.Profile.Profiler, opts.Profile.SampleRateHz = "sampler", pythonSampleRateHz
+		readFoldedProfile(opts.Profile, dir)
+	}
 
 	log.Printf("Python execution completed - output length: %d, error: %v", len(outputStr), execErr != nil)
 	if execErr != nil {
@@ -760,21 +1062,16 @@ func runPythonCode(dir, input string) (stdout, stderr string, err error) {
 	return "", execErr.Error(), execErr
 }
 
-func runJavaScriptCode(dir, input string) (stdout, stderr string, err error) {
-	mainJs := filepath.Join(dir, "Main.js")
-	if _, e := os.Stat(mainJs); e != nil {
-		files, _ := filepath.Glob(filepath.Join(dir, "*.js"))
-		if len(files) > 0 {
-			mainJs = files[0]
-		} else {
-			return "", "", fmt.Errorf("JavaScript file not found")
-		}
+func runJavaScriptCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	mainJs, err := findEntryPoint(dir, "javascript", opts.EntryPoint)
+	if err != nil {
+		return "", "", err
 	}
 
-	cmd := exec.Command("node", mainJs)
+	cmd := exec.Command("node", withFlags(opts.Build.RunFlags, mainJs)...)
 	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(input)
-	out, execErr := cmd.CombinedOutput()
+	out, execErr := runWithLimits(cmd, opts)
 
 	if execErr != nil {
 		return "", string(out), execErr
@@ -782,30 +1079,27 @@ func runJavaScriptCode(dir, input string) (stdout, stderr string, err error) {
 	return string(out), "", nil
 }
 
-func runTypeScriptCode(dir, input string) (stdout, stderr string, err error) {
-	mainTs := filepath.Join(dir, "Main.ts")
-	if _, e := os.Stat(mainTs); e != nil {
-		files, _ := filepath.Glob(filepath.Join(dir, "*.ts"))
-		if len(files) > 0 {
-			mainTs = files[0]
-		} else {
-			return "", "", fmt.Errorf("TypeScript file not found")
-		}
+func runTypeScriptCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	mainTs, err := findEntryPoint(dir, "typescript", opts.EntryPoint)
+	if err != nil {
+		return "", "", err
 	}
 
-	// Compile TypeScript
-	compileCmd := exec.Command("tsc", mainTs)
-	compileCmd.Dir = dir
-	if compileErr := compileCmd.Run(); compileErr != nil {
-		return "", "", fmt.Errorf("TypeScript compilation failed: %v", compileErr)
+	// Compile TypeScript; tsc follows the entry point's imports
+	if !opts.Prebuilt {
+		compileCmd := exec.Command("tsc", withFlags(opts.Build.CompileFlags, mainTs)...)
+		compileCmd.Dir = dir
+		if out, compileErr := compileCmd.CombinedOutput(); compileErr != nil {
+			return "", string(out), fmt.Errorf("TypeScript %w: %w", errBuildFailed, compileErr)
+		}
 	}
 
 	// Run compiled JavaScript
 	mainJs := strings.TrimSuffix(mainTs, ".ts") + ".js"
-	cmd := exec.Command("node", mainJs)
+	cmd := exec.Command("node", withFlags(opts.Build.RunFlags, mainJs)...)
 	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(input)
-	out, execErr := cmd.CombinedOutput()
+	out, execErr := runWithLimits(cmd, opts)
 
 	if execErr != nil {
 		return "", string(out), execErr
@@ -813,29 +1107,28 @@ func runTypeScriptCode(dir, input string) (stdout, stderr string, err error) {
 	return string(out), "", nil
 }
 
-func runJavaCode(dir, input string) (stdout, stderr string, err error) {
-	mainJava := filepath.Join(dir, "Main.java")
-	if _, e := os.Stat(mainJava); e != nil {
-		files, _ := filepath.Glob(filepath.Join(dir, "*.java"))
-		if len(files) > 0 {
-			mainJava = files[0]
-		} else {
-			return "", "", fmt.Errorf("Java file not found")
-		}
+func runJavaCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	mainJava, err := findEntryPoint(dir, "java", opts.EntryPoint)
+	if err != nil {
+		return "", "", err
 	}
 
-	// Compile
-	compileCmd := exec.Command("javac", mainJava)
-	compileCmd.Dir = dir
-	if compileErr := compileCmd.Run(); compileErr != nil {
-		return "", "", fmt.Errorf("Java compilation failed: %v", compileErr)
+	// Compile the whole source tree; classes land in their package
+	// directories under .classes
+	classes := filepath.Join(dir, ".classes")
+	if !opts.Prebuilt {
+		compileCmd := exec.Command("javac", append(withFlags([]string{"-d", classes}, opts.Build.CompileFlags...), sourcePaths(dir, "java")...)...)
+		compileCmd.Dir = dir
+		if out, compileErr := compileCmd.CombinedOutput(); compileErr != nil {
+			return "", string(out), fmt.Errorf("Java %w: %w", errBuildFailed, compileErr)
+		}
 	}
 
-	// Run
-	cmd := exec.Command("java", "-cp", dir, "Main")
+	// Run the entry point's class
+	cmd := exec.Command("java", withFlags(opts.Build.RunFlags, "-cp", classes, javaMainClass(mainJava))...)
 	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(input)
-	out, execErr := cmd.CombinedOutput()
+	out, execErr := runWithLimits(cmd, opts)
 
 	if execErr != nil {
 		return "", string(out), execErr
@@ -843,27 +1136,31 @@ func runJavaCode(dir, input string) (stdout, stderr string, err error) {
 	return string(out), "", nil
 }
 
-func runCppCode(dir, input string) (stdout, stderr string, err error) {
-	mainCpp := filepath.Join(dir, "Main.cpp")
-	if _, e := os.Stat(mainCpp); e != nil {
-		files, _ := filepath.Glob(filepath.Join(dir, "*.cpp"))
-		if len(files) > 0 {
-			mainCpp = files[0]
-		} else {
-			return "", "", fmt.Errorf("C++ file not found")
-		}
+func runCppCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	if _, err := findEntryPoint(dir, "cpp", opts.EntryPoint); err != nil {
+		return "", "", err
 	}
 
+	// Compile every source file; headers are included from the project root
 	exePath := filepath.Join(dir, "main")
-	compileCmd := exec.Command("g++", "-o", exePath, mainCpp)
-	if compileErr := compileCmd.Run(); compileErr != nil {
-		return "", "", fmt.Errorf("C++ compilation failed: %v", compileErr)
+	flags := opts.Build.CompileFlags
+	if opts.Profile != nil {
+		flags = withFlags(flags, nativeProfileFlags("cpp")...)
+	}
+	if !opts.Prebuilt {
+		compileCmd := exec.Command("g++", append(withFlags([]string{"-o", exePath, "-I", dir}, flags...), sourcePaths(dir, "cpp")...)...)
+		compileCmd.Dir = dir
+		if out, compileErr := compileCmd.CombinedOutput(); compileErr != nil {
+			return "", string(out), fmt.Errorf("C++ %w: %w", errBuildFailed, compileErr)
+		}
 	}
 
 	cmd := exec.Command(exePath)
 	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(input)
-	out, execErr := cmd.CombinedOutput()
+	cmd = perfCommand(cmd, dir, opts.Profile)
+	out, execErr := runWithLimits(cmd, opts)
+	readPerfProfile(opts.Profile, dir)
 
 	if execErr != nil {
 		return "", string(out), execErr
@@ -871,27 +1168,31 @@ func runCppCode(dir, input string) (stdout, stderr string, err error) {
 	return string(out), "", nil
 }
 
-func runCCode(dir, input string) (stdout, stderr string, err error) {
-	mainC := filepath.Join(dir, "Main.c")
-	if _, e := os.Stat(mainC); e != nil {
-		files, _ := filepath.Glob(filepath.Join(dir, "*.c"))
-		if len(files) > 0 {
-			mainC = files[0]
-		} else {
-			return "", "", fmt.Errorf("C file not found")
-		}
+func runCCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	if _, err := findEntryPoint(dir, "c", opts.EntryPoint); err != nil {
+		return "", "", err
 	}
 
+	// Compile every source file; headers are included from the project root
 	exePath := filepath.Join(dir, "main")
-	compileCmd := exec.Command("gcc", "-o", exePath, mainC)
-	if compileErr := compileCmd.Run(); compileErr != nil {
-		return "", "", fmt.Errorf("C compilation failed: %v", compileErr)
+	flags := opts.Build.CompileFlags
+	if opts.Profile != nil {
+		flags = withFlags(flags, nativeProfileFlags("c")...)
+	}
+	if !opts.Prebuilt {
+		compileCmd := exec.Command("gcc", append(withFlags([]string{"-o", exePath, "-I", dir}, flags...), sourcePaths(dir, "c")...)...)
+		compileCmd.Dir = dir
+		if out, compileErr := compileCmd.CombinedOutput(); compileErr != nil {
+			return "", string(out), fmt.Errorf("C %w: %w", errBuildFailed, compileErr)
+		}
 	}
 
 	cmd := exec.Command(exePath)
 	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(input)
-	out, execErr := cmd.CombinedOutput()
+	cmd = perfCommand(cmd, dir, opts.Profile)
+	out, execErr := runWithLimits(cmd, opts)
+	readPerfProfile(opts.Profile, dir)
 
 	if execErr != nil {
 		return "", string(out), execErr
@@ -899,21 +1200,45 @@ func runCCode(dir, input string) (stdout, stderr string, err error) {
 	return string(out), "", nil
 }
 
-func runGoCode(dir, input string) (stdout, stderr string, err error) {
-	mainGo := filepath.Join(dir, "main.go")
-	if _, e := os.Stat(mainGo); e != nil {
-		files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
-		if len(files) > 0 {
-			mainGo = files[0]
-		} else {
-			return "", "", fmt.Errorf("Go file not found")
+func runGoCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	mainGo, err := findEntryPoint(dir, "go", opts.EntryPoint)
+	if err != nil {
+		return "", "", err
+	}
+	exePath := filepath.Join(dir, "main")
+	if !opts.Prebuilt {
+		// Build as a module against the allowlist, offline
+		if err := prepareGoModule(dir); err != nil {
+			return "", "", err
+		}
+
+		// Build the entry point's package as part of the module
+		pkg, _ := filepath.Rel(dir, filepath.Dir(mainGo))
+		if opts.Profile != nil {
+			if err := injectGoProfiler(filepath.Dir(mainGo)); err != nil {
+				opts.Profile.Error = fmt.Sprintf("cannot profile: %v", err)
+				opts.Profile = nil
+			}
+		}
+		buildCmd := exec.Command("go", append(withFlags([]string{"build", "-o", exePath}, opts.Build.CompileFlags...), "./"+filepath.ToSlash(pkg))...)
+		buildCmd.Dir = dir
+		buildCmd.Env = goBuildEnv(dir)
+		if out, buildErr := buildCmd.CombinedOutput(); buildErr != nil {
+			return "", string(out), fmt.Errorf("Go %w: %w", errBuildFailed, buildErr)
 		}
 	}
 
-	cmd := exec.Command("go", "run", mainGo)
+	cmd := exec.Command(exePath)
 	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(input)
-	out, execErr := cmd.CombinedOutput()
+	if opts.Profile != nil {
+		profileCommand(cmd, dir)
+	}
+	out, execErr := runWithLimits(cmd, opts)
+	if opts.Profile != nil {
+		opts.Profile.Profiler, opts.Profile.SampleRateHz = "pprof", int(time.Second/pprofSamplePeriod)
+		readPprofProfile(opts.Profile, exePath, dir)
+	}
 
 	if execErr != nil {
 		return "", string(out), execErr
@@ -921,27 +1246,32 @@ func runGoCode(dir, input string) (stdout, stderr string, err error) {
 	return string(out), "", nil
 }
 
-func runRustCode(dir, input string) (stdout, stderr string, err error) {
-	mainRs := filepath.Join(dir, "main.rs")
-	if _, e := os.Stat(mainRs); e != nil {
-		files, _ := filepath.Glob(filepath.Join(dir, "*.rs"))
-		if len(files) > 0 {
-			mainRs = files[0]
-		} else {
-			return "", "", fmt.Errorf("Rust file not found")
-		}
+func runRustCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	mainRs, err := findEntryPoint(dir, "rust", opts.EntryPoint)
+	if err != nil {
+		return "", "", err
 	}
 
+	// rustc finds the crate's other files through its mod declarations
 	exePath := filepath.Join(dir, "main")
-	compileCmd := exec.Command("rustc", "-o", exePath, mainRs)
-	if compileErr := compileCmd.Run(); compileErr != nil {
-		return "", "", fmt.Errorf("Rust compilation failed: %v", compileErr)
+	flags := opts.Build.CompileFlags
+	if opts.Profile != nil {
+		flags = withFlags(flags, nativeProfileFlags("rust")...)
+	}
+	if !opts.Prebuilt {
+		compileCmd := exec.Command("rustc", withFlags(flags, "-o", exePath, mainRs)...)
+		compileCmd.Dir = dir
+		if out, compileErr := compileCmd.CombinedOutput(); compileErr != nil {
+			return "", string(out), fmt.Errorf("Rust %w: %w", errBuildFailed, compileErr)
+		}
 	}
 
 	cmd := exec.Command(exePath)
 	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(input)
-	out, execErr := cmd.CombinedOutput()
+	cmd = perfCommand(cmd, dir, opts.Profile)
+	out, execErr := runWithLimits(cmd, opts)
+	readPerfProfile(opts.Profile, dir)
 
 	if execErr != nil {
 		return "", string(out), execErr
@@ -949,21 +1279,28 @@ func runRustCode(dir, input string) (stdout, stderr string, err error) {
 	return string(out), "", nil
 }
 
-func runSwiftCode(dir, input string) (stdout, stderr string, err error) {
-	mainSwift := filepath.Join(dir, "main.swift")
-	if _, e := os.Stat(mainSwift); e != nil {
-		files, _ := filepath.Glob(filepath.Join(dir, "*.swift"))
-		if len(files) > 0 {
-			mainSwift = files[0]
-		} else {
-			return "", "", fmt.Errorf("Swift file not found")
+func runSwiftCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	mainSwift, err := findEntryPoint(dir, "swift", opts.EntryPoint)
+	if err != nil {
+		return "", "", err
+	}
+
+	cmd := exec.Command("swift", withFlags(opts.Build.CompileFlags, mainSwift)...)
+	if sources := sourcePaths(dir, "swift"); len(sources) > 1 {
+		// Several files are compiled into one module first
+		exePath := filepath.Join(dir, "main")
+		if !opts.Prebuilt {
+			compileCmd := exec.Command("swiftc", append(withFlags(opts.Build.CompileFlags, "-o", exePath), sources...)...)
+			compileCmd.Dir = dir
+			if out, compileErr := compileCmd.CombinedOutput(); compileErr != nil {
+				return "", string(out), fmt.Errorf("Swift %w: %w", errBuildFailed, compileErr)
+			}
 		}
+		cmd = exec.Command(exePath)
 	}
-
-	cmd := exec.Command("swift", mainSwift)
 	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(input)
-	out, execErr := cmd.CombinedOutput()
+	out, execErr := runWithLimits(cmd, opts)
 
 	if execErr != nil {
 		return "", string(out), execErr
@@ -971,21 +1308,16 @@ func runSwiftCode(dir, input string) (stdout, stderr string, err error) {
 	return string(out), "", nil
 }
 
-func runRubyCode(dir, input string) (stdout, stderr string, err error) {
-	mainRb := filepath.Join(dir, "main.rb")
-	if _, e := os.Stat(mainRb); e != nil {
-		files, _ := filepath.Glob(filepath.Join(dir, "*.rb"))
-		if len(files) > 0 {
-			mainRb = files[0]
-		} else {
-			return "", "", fmt.Errorf("Ruby file not found")
-		}
+func runRubyCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	mainRb, err := findEntryPoint(dir, "ruby", opts.EntryPoint)
+	if err != nil {
+		return "", "", err
 	}
 
-	cmd := exec.Command("ruby", mainRb)
+	cmd := exec.Command("ruby", withFlags(opts.Build.RunFlags, mainRb)...)
 	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(input)
-	out, execErr := cmd.CombinedOutput()
+	out, execErr := runWithLimits(cmd, opts)
 
 	if execErr != nil {
 		return "", string(out), execErr
@@ -993,37 +1325,16 @@ func runRubyCode(dir, input string) (stdout, stderr string, err error) {
 	return string(out), "", nil
 }
 
-func runBashCode(dir, input string) (stdout, stderr string, err error) {
-	// Try common bash file names
-	var bashFile string
-	possibleNames := []string{"main.sh", "script.sh", "Main.sh", "Script.sh"}
-	for _, name := range possibleNames {
-		path := filepath.Join(dir, name)
-		if _, e := os.Stat(path); e == nil {
-			bashFile = path
-			break
-		}
-	}
-
-	if bashFile == "" {
-		// Try to find any .sh or .bash file
-		files, _ := filepath.Glob(filepath.Join(dir, "*.sh"))
-		if len(files) > 0 {
-			bashFile = files[0]
-		} else {
-			files, _ = filepath.Glob(filepath.Join(dir, "*.bash"))
-			if len(files) > 0 {
-				bashFile = files[0]
-			} else {
-				return "", "", fmt.Errorf("Bash file not found")
-			}
-		}
+func runBashCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
+	bashFile, err := findEntryPoint(dir, "bash", opts.EntryPoint)
+	if err != nil {
+		return "", "", err
 	}
 
-	cmd := exec.Command("bash", bashFile)
+	cmd := exec.Command("bash", withFlags(opts.Build.RunFlags, bashFile)...)
 	cmd.Dir = dir
 	cmd.Stdin = strings.NewReader(input)
-	out, execErr := cmd.CombinedOutput()
+	out, execErr := runWithLimits(cmd, opts)
 
 	if execErr != nil {
 		return "", string(out), execErr
@@ -1031,7 +1342,7 @@ func runBashCode(dir, input string) (stdout, stderr string, err error) {
 	return string(out), "", nil
 }
 
-func runSqlCode(dir, input string) (stdout, stderr string, err error) {
+func runSqlCode(dir, input string, opts RunOptions) (stdout, stderr string, err error) {
 	// SQL execution would need a database connection
 	// For now, return an error indicating SQL needs special handling
 	return "", "", fmt.Errorf("SQL execution requires database setup")
@@ -1051,16 +1362,36 @@ func createProblem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req Problem
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	var body struct {
+		Problem
+		Solutions []Program `json:"Solutions,omitempty"` // reference solutions, stored outside the manifest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		log.Printf("JSON decode error: %v", err)
 		http.Error(w, "Bad request", 400)
 		return
 	}
+	req := body.Problem
+	for _, s := range body.Solutions {
+		if err := solutionPrograms.validate(s); err != nil {
+			http.Error(w, fmt.Sprintf("invalid reference solution %q: %v", s.Name, err), 400)
+			return
+		}
+	}
 
 	// Generate problem ID from title
-	req.ID = strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(req.Title, " ", "-"), "_", "-"))
-	req.ID = strings.ReplaceAll(req.ID, "--", "-")
+	req.ID = problemIDFromTitle(req.Title)
+	req.Difficulty = normalizeDifficulty(req.Difficulty)
+	req.Tags = normalizeTags(req.Tags)
+	req.Examples = normalizeExamples(req.Examples)
+	constraints, err := normalizeConstraints(req.Constraints)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	req.Constraints = constraints
+	req.SampleTests = nil
+	req.AvailableLanguages = nil
 
 	// Create problem directory
 	problemDir := filepath.Join(dataDir, req.ID)
@@ -1092,6 +1423,8 @@ func createProblem(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", 500)
 		return
 	}
+	problemCache.invalidate(req.ID)
+	recordAudit(r, "problem.create", req.ID, nil, req, nil)
 
 	// Create basic test files
 	publicDir := filepath.Join(v1Dir, "public")
@@ -1101,16 +1434,28 @@ func createProblem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create sample input and output files for the main part (Part 1)
-	inputFile := filepath.Join(publicDir, "01.in")
-	outputFile := filepath.Join(publicDir, "01.out")
+	// Create sample input and output files for the main part (Part 1),
+	// taken from the examples when there are any
+	if len(req.Examples) > 0 && req.Type != "system_design" {
+		if err := syncExampleTests(req, nil); err != nil {
+			log.Printf("Failed to create example tests: %v", err)
+		}
+	} else {
+		// The expected output stays empty until the reference solutions
+		// generate it
+		inputFile := filepath.Join(publicDir, "01.in")
+		outputFile := filepath.Join(publicDir, "01.out")
 
-	if err := os.WriteFile(inputFile, []byte(""), 0644); err != nil {
-		log.Printf("Failed to create input file: %v", err)
-	}
+		if err := os.WriteFile(inputFile, []byte(""), 0644); err != nil {
+			log.Printf("Failed to create input file: %v", err)
+		}
 
-	if err := os.WriteFile(outputFile, []byte("Hello, World!"), 0644); err != nil {
-		log.Printf("Failed to create output file: %v", err)
+		if err := os.WriteFile(outputFile, []byte(""), 0644); err != nil {
+			log.Printf("Failed to create output file: %v", err)
+		}
+		if err := setTestMeta(publicDir, "01", TestMeta{Pending: true}); err != nil {
+			log.Printf("Failed to mark output as pending: %v", err)
+		}
 	}
 
 	// If multi-part, create test directories for each part
@@ -1134,11 +1479,87 @@ func createProblem(w http.ResponseWriter, r *http.Request) {
 			if err := os.WriteFile(partOutputFile, []byte(""), 0644); err != nil {
 				log.Printf("Failed to create part%d output file: %v", part.PartNumber, err)
 			}
+			if err := setTestMeta(partPublicDir, "01", TestMeta{Pending: true}); err != nil {
+				log.Printf("Failed to mark part%d output as pending: %v", part.PartNumber, err)
+			}
+		}
+	}
+
+	resp := map[string]interface{}{"status": "success", "id": req.ID}
+
+	// With reference solutions the expected outputs come from running them
+	if len(body.Solutions) > 0 {
+		for _, s := range body.Solutions {
+			if err := solutionPrograms.write(req.ID, s); err != nil {
+				log.Printf("Failed to write solution %s: %v", s.Name, err)
+			}
+		}
+		report, err := regenerateOutputs(req.ID, "", false, false)
+		if err != nil {
+			log.Printf("Failed to generate outputs of %s: %v", req.ID, err)
+			resp["outputsError"] = err.Error()
+		} else {
+			resp["outputs"] = report
 		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "id": req.ID})
+	json.NewEncoder(w).Encode(resp)
+}
+
+// problemIDFromTitle derives a problem ID from its title ("Two Sum" -> "two-sum")
+func problemIDFromTitle(title string) string {
+	id := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(title, " ", "-"), "_", "-"))
+	return strings.ReplaceAll(id, "--", "-")
+}
+
+// findPublicTestDir returns the directory holding a problem's public test
+// cases (v1/public, or v1/sql/public for SQL problems), or "" if there is none
+func findPublicTestDir(problemID string) string {
+	problemDir := filepath.Join(dataDir, problemID, "v1")
+	if _, err := os.Stat(filepath.Join(problemDir, "public")); err == nil {
+		return filepath.Join(problemDir, "public")
+	}
+	if _, err := os.Stat(filepath.Join(problemDir, "sql", "public")); err == nil {
+		return filepath.Join(problemDir, "sql", "public")
+	}
+	return ""
+}
+
+// findTestDirs returns every directory holding public tests of a problem:
+// the one from findPublicTestDir, then v1/part{N}/public of each part
+func findTestDirs(problemID string) []string {
+	var dirs []string
+	if dir := findPublicTestDir(problemID); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	parts, _ := filepath.Glob(filepath.Join(dataDir, problemID, "v1", "part*", "public"))
+	sort.Slice(parts, func(i, j int) bool {
+		ni, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(parts[i])), "part"))
+		nj, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(parts[j])), "part"))
+		return ni < nj
+	})
+	return append(dirs, parts...)
+}
+
+// listTestNames returns the names (file stems) of the tests in dir that have
+// both an .in and an .out file, in the order of tests.json and then by name
+func listTestNames(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".in") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".in")
+		if _, err := os.Stat(filepath.Join(dir, name+".out")); err == nil {
+			names = append(names, name)
+		}
+	}
+	return orderTestNames(dir, names)
 }
 
 func getTestCases(w http.ResponseWriter, r *http.Request) {
@@ -1169,45 +1590,20 @@ func getTestCases(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entries, err := os.ReadDir(publicDir)
-	if err != nil {
-		log.Printf("Error reading test directory: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode([]map[string]string{})
-		return
-	}
-
-	var testCases []map[string]string
-
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".in") {
-			testName := strings.TrimSuffix(entry.Name(), ".in")
-			inputFile := filepath.Join(publicDir, entry.Name())
-			outputFile := filepath.Join(publicDir, testName+".out")
-
-			inputContent, err := os.ReadFile(inputFile)
-			if err != nil {
-				continue
-			}
-
-			outputContent, err := os.ReadFile(outputFile)
-			if err != nil {
-				continue
-			}
-
-			testCases = append(testCases, map[string]string{
-				"name":   testName,
-				"input":  string(inputContent),
-				"output": string(outputContent),
-			})
+	// Small tests are listed in full; large ones (or all, with ?preview=1)
+	// as a preview plus their size
+	full := r.URL.Query().Get("preview") == ""
+	metas := testMetaByName(publicDir)
+	testCases := []TestCaseInfo{}
+	for _, name := range listTestNames(publicDir) {
+		tc, err := readTestCaseInfo(publicDir, name, metas[name], full)
+		if err != nil {
+			continue
 		}
+		testCases = append(testCases, tc)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	// Ensure we always return an array, not null
-	if testCases == nil {
-		testCases = []map[string]string{}
-	}
 	json.NewEncoder(w).Encode(testCases)
 }
 
@@ -1233,6 +1629,7 @@ func deleteAllTestCases(w http.ResponseWriter, r *http.Request, problemID string
 	}
 
 	// Remove all test files
+	before := snapshotTestCases(problemID)
 	entries, err := os.ReadDir(publicDir)
 	if err != nil {
 		log.Printf("Error reading test directory: %v", err)
@@ -1252,6 +1649,9 @@ func deleteAllTestCases(w http.ResponseWriter, r *http.Request, problemID string
 		}
 	}
 
+	pruneTestMeta(publicDir)
+	recordAudit(r, "testcases.delete", problemID, before, snapshotTestCases(problemID), nil)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":        "success",
@@ -1273,8 +1673,8 @@ func updateTestCases(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var testCases []map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&testCases); err != nil {
+	var rawCases []map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&rawCases); err != nil {
 		http.Error(w, "Invalid JSON", 400)
 		return
 	}
@@ -1297,42 +1697,82 @@ func updateTestCases(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Remove existing test files
-	entries, err := os.ReadDir(publicDir)
-	if err == nil {
-		for _, entry := range entries {
-			if strings.HasSuffix(entry.Name(), ".in") || strings.HasSuffix(entry.Name(), ".out") {
-				os.Remove(filepath.Join(publicDir, entry.Name()))
+	// Tests keep their names, in the order sent; unnamed ones are numbered.
+	// Generated tests listed by GET are not rewritten here, tests that were
+	// listed as a preview keep their stored content and metadata fields
+	// left out keep their stored value.
+	oldMeta := testMetaByName(publicDir)
+	var testCases []storedTest
+	for _, raw := range rawCases {
+		var tc TestCaseInfo
+		data, _ := json.Marshal(raw)
+		if err := json.Unmarshal(data, &tc); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), 400)
+			return
+		}
+		if testGroupOf(tc.Name) != "" {
+			continue
+		}
+		if tc.Truncated {
+			in, err := os.ReadFile(filepath.Join(publicDir, tc.Name+".in"))
+			if err == nil {
+				var out []byte
+				out, err = os.ReadFile(filepath.Join(publicDir, tc.Name+".out"))
+				tc.Input, tc.Output = string(in), string(out)
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Test %q was listed as a preview but no longer exists; send its full input and output", tc.Name), 400)
+				return
 			}
 		}
+		meta := mergeTestMeta(raw, tc.TestMeta, oldMeta[tc.Name])
+		if err := checkTestMeta(tc.Name, meta); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		testCases = append(testCases, storedTest{Name: tc.Name, TestCase: TestCase{Input: tc.Input, Output: tc.Output}, Meta: meta})
 	}
+	nameStoredTests(testCases)
 
-	// Write new test cases
-	for i, testCase := range testCases {
-		testNum := fmt.Sprintf("%02d", i+1)
-		inputFile := filepath.Join(publicDir, testNum+".in")
-		outputFile := filepath.Join(publicDir, testNum+".out")
-
-		if err := os.WriteFile(inputFile, []byte(testCase["input"]), 0644); err != nil {
-			log.Printf("Failed to write input file: %v", err)
-			continue
+	// Reject inputs the problem's validator doesn't accept
+	p := loadProblem(path)
+	if p.Validator != nil {
+		tests := make([]TestCase, len(testCases))
+		for i, tc := range testCases {
+			tests[i] = tc.TestCase
 		}
-
-		if err := os.WriteFile(outputFile, []byte(testCase["output"]), 0644); err != nil {
-			log.Printf("Failed to write output file: %v", err)
-			continue
+		if err := validateTestCases(p, tests); err != nil {
+			writeValidationFailure(w, err)
+			return
 		}
 	}
 
+	// Replace the hand-written tests; generated tests belong to their group
+	// and are kept
+	before := snapshotTestCases(path)
+	if err := writeStoredTests(publicDir, testCases); err != nil {
+		log.Printf("Failed to write tests of %s: %v", path, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	// Examples from the manifest always stay the first tests
+	if err := syncExampleTests(p, nil); err != nil {
+		log.Printf("Failed to sync examples of %s: %v", path, err)
+	}
+	recordAudit(r, "testcases.update", path, before, snapshotTestCases(path), nil)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
 func listUploadedFiles(w http.ResponseWriter, r *http.Request, problemID string) {
-	uploadDir := filepath.Join(dataDir, problemID, "uploads")
-	log.Printf("Listing files in directory: %s", uploadDir)
+	log.Printf("Listing uploads of %s", problemID)
+	if !isValidProblemID(problemID) {
+		http.Error(w, "Invalid problem ID", 400)
+		return
+	}
 
-	files, err := os.ReadDir(uploadDir)
+	files, err := uploadsResponse(problemID)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Return empty array if directory doesn't exist
@@ -1345,29 +1785,21 @@ func listUploadedFiles(w http.ResponseWriter, r *http.Request, problemID string)
 		return
 	}
 
-	var fileList []map[string]interface{}
-	for _, file := range files {
-		if !file.IsDir() {
-			fileInfo, err := file.Info()
-			if err != nil {
-				continue
-			}
-
-			fileList = append(fileList, map[string]interface{}{
-				"name": file.Name(),
-				"size": fileInfo.Size(),
-				"type": getFileType(file.Name()),
-			})
-		}
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(fileList)
+	json.NewEncoder(w).Encode(files)
 }
 
 func deleteUploadedFile(w http.ResponseWriter, r *http.Request, problemID, filename string) {
-	filePath := filepath.Join(dataDir, problemID, "uploads", filename)
+	if !isValidProblemID(problemID) || !isValidUploadName(filename) {
+		http.Error(w, "File not found", 404)
+		return
+	}
+	filePath := filepath.Join(uploadsDir(problemID), filename)
 	log.Printf("Deleting file: %s", filePath)
+	var size int64
+	if info, err := os.Stat(filePath); err == nil {
+		size = info.Size()
+	}
 
 	err := os.Remove(filePath)
 	if err != nil {
@@ -1379,6 +1811,7 @@ func deleteUploadedFile(w http.ResponseWriter, r *http.Request, problemID, filen
 		http.Error(w, "Internal server error", 500)
 		return
 	}
+	recordAudit(r, "upload.delete", problemID, nil, nil, map[string]interface{}{"file": filename, "size": size})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
@@ -1389,8 +1822,12 @@ func getFileType(filename string) string {
 	switch ext {
 	case ".csv":
 		return "text/csv"
+	case ".tsv":
+		return "text/tab-separated-values"
 	case ".json":
 		return "application/json"
+	case ".jsonl", ".ndjson":
+		return "application/x-ndjson"
 	case ".txt":
 		return "text/plain"
 	case ".xlsx":
@@ -1418,12 +1855,24 @@ func uploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse oversized bodies before buffering them; the multipart framing
+	// gets a little room on top of the file limit
+	if config.MaxUploadFileMB > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(config.MaxUploadFileMB)<<20+1<<20)
+	}
+
 	// Parse multipart form
-	err := r.ParseMultipartForm(32 << 20) // 32MB max
+	err := r.ParseMultipartForm(32 << 20) // 32MB in memory, the rest on disk
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeUploadQuotaError(w, fmt.Errorf("file is larger than the %d MB limit", config.MaxUploadFileMB))
+			return
+		}
 		http.Error(w, "Failed to parse form", 400)
 		return
 	}
+	defer r.MultipartForm.RemoveAll()
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
@@ -1440,39 +1889,39 @@ func uploadFile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Problem ID required", 400)
 		return
 	}
-
-	// Create problem-specific uploads directory
-	uploadDir := filepath.Join(dataDir, problemID, "uploads")
-	log.Printf("Creating upload directory: %s", uploadDir)
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		log.Printf("Failed to create upload directory: %v", err)
-		http.Error(w, "Internal server error", 500)
+	if !isValidProblemID(problemID) || !problemExists(problemID) {
+		http.Error(w, "Problem not found", 404)
 		return
 	}
-
-	// Save file
-	filePath := filepath.Join(uploadDir, header.Filename)
-	log.Printf("Saving file to: %s", filePath)
-	dst, err := os.Create(filePath)
-	if err != nil {
-		log.Printf("Failed to create file: %v", err)
-		http.Error(w, "Internal server error", 500)
+	filename := filepath.Base(strings.ReplaceAll(header.Filename, "\\", "/"))
+	if !isValidUploadName(filename) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": "invalid file name"})
+		return
+	}
+	if err := checkUploadQuota(problemID, filename, header.Size); err != nil {
+		writeUploadQuotaError(w, err)
 		return
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
+	// Save file
+	size, err := storeUpload(problemID, filename, file)
+	if err != nil {
 		log.Printf("Failed to save file: %v", err)
 		http.Error(w, "Internal server error", 500)
 		return
 	}
-	log.Printf("File saved successfully: %s", filePath)
+	recordAudit(r, "upload.create", problemID, nil, nil, map[string]interface{}{"file": filename, "size": size})
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
+	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":   "success",
-		"url":      "/api/uploads/" + header.Filename,
-		"filename": header.Filename,
+		"url":      uploadURL(problemID, filename),
+		"filename": filename,
+		"path":     uploadsMountDir + "/" + filename,
+		"size":     size,
+		"type":     detectFileType(filepath.Join(uploadsDir(problemID), filename)),
 	})
 }
 
@@ -1577,11 +2026,25 @@ func generateQuestions(w http.ResponseWriter, r *http.Request) {
 
 	// Save generated problems to the data directory
 	savedProblems := make([]Problem, 0)
+	var saveErrors []string
 	for _, problem := range problems {
+		applyGeneratedMetadata(&problem, req)
+		if len(req.Locales) > 0 {
+			provider, apiKey := aiProviderAndKey(req.Provider, req.APIKey)
+			if _, err := translateProblem(&problem, req.Locales, false, provider, apiKey); err != nil {
+				log.Printf("Failed to translate problem %s: %v", problem.ID, err)
+			}
+		}
+		var before interface{}
+		if existing, ok := problemCache.get(problem.ID); ok {
+			before = existing
+		}
 		if err := saveGeneratedProblem(problem); err != nil {
 			log.Printf("Failed to save problem %s: %v", problem.ID, err)
+			saveErrors = append(saveErrors, fmt.Sprintf("%s: %v", problem.ID, err))
 			continue
 		}
+		recordAudit(r, "problem.generate", problem.ID, before, problem, map[string]interface{}{"provider": req.Provider})
 		savedProblems = append(savedProblems, problem)
 	}
 
@@ -1589,6 +2052,19 @@ func generateQuestions(w http.ResponseWriter, r *http.Request) {
 		Status:   "success",
 		Problems: savedProblems,
 		Message:  fmt.Sprintf("Generated %d questions for %s %s position at %s", len(savedProblems), req.Level, req.Role, req.Company),
+		Errors:   saveErrors,
+	}
+
+	// Optionally turn the generated problems into an interview loop
+	if req.CreateCollection && len(savedProblems) > 0 {
+		collection, err := createCollection(collectionFromProblems(req.CollectionTitle, req, savedProblems))
+		if err != nil {
+			log.Printf("Failed to create collection from generated problems: %v", err)
+			response.Message += "; failed to create collection: " + err.Error()
+		} else {
+			recordAudit(r, "collection.create", "", nil, collection, map[string]interface{}{"collectionId": collection.ID})
+			response.Collection = &collection
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -2292,6 +2768,9 @@ Return ONLY a JSON array (no markdown, no code blocks, no explanations) with thi
     "title": "System Design: [Topic]",
     "statement": "Design a [system description]. [Optional: brief context or example]",
     "type": "system_design",
+    "difficulty": "easy | medium | hard",
+    "tags": ["design", "caching"],
+    "estimatedMinutes": 45,
     "languages": [],
     "stub": {}
   }
@@ -2344,7 +2823,9 @@ Requirements:
 - Focus on %s role-specific skills and technologies
 - Include a mix of algorithmic, data structure, and practical problems
 - Each question should have a clear problem statement with examples
-- Include sample input/output examples
+- Include 1-3 examples; their input and output must be the exact stdin and stdout of a correct program
+- List the input bounds as constraints, one per entry, like "1 <= n <= 10^5"
+- Include a Python input validator that reads one test input from stdin, exits 0 if it is well-formed and within the constraints, and otherwise prints "line N: reason" and exits 1
 - Provide starter code stubs for %s (with %s as the primary/default language)%s
 
 For %s level %s roles at %s, consider:
@@ -2357,8 +2838,16 @@ Return ONLY a JSON array (no markdown, no code blocks, no explanations) with thi
   {
     "id": "unique-kebab-case-id",
     "title": "Descriptive Title",
-    "statement": "Detailed problem description with examples and constraints",
+    "statement": "Detailed problem description with the input and output format",
     "type": "coding",
+    "difficulty": "easy | medium | hard",
+    "tags": ["graph", "dp"],
+    "estimatedMinutes": 30,
+    "timeLimitMs": 2000,
+    "memoryLimitMB": 256,
+    "examples": [{"input": "3\n1 2 3", "output": "6", "explanation": "1 + 2 + 3 = 6"}],
+    "constraints": ["1 <= n <= 10^5", "-10^9 <= a[i] <= 10^9"],
+    "validator": {"language": "python", "source": "import sys\n..."},
     "languages": %s,
     "stub": {
 %s    }%s
@@ -2512,6 +3001,16 @@ IMPORTANT: Return ONLY the JSON array, nothing else. No markdown formatting, no
 		DrawingData string            `json:"drawingData,omitempty"`
 		IsMultiPart bool              `json:"isMultiPart,omitempty"`
 		Parts       []Part            `json:"parts,omitempty"`
+
+		Difficulty       string   `json:"difficulty,omitempty"`
+		Tags             []string `json:"tags,omitempty"`
+		EstimatedMinutes int      `json:"estimatedMinutes,omitempty"`
+		TimeLimitMs      int      `json:"timeLimitMs,omitempty"`
+		MemoryLimitMB    int      `json:"memoryLimitMB,omitempty"`
+
+		Examples    []Example    `json:"examples,omitempty"`
+		Constraints []Constraint `json:"constraints,omitempty"`
+		Validator   *Validator   `json:"validator,omitempty"`
 	}
 
 	if err := json.Unmarshal([]byte(cleanText), &aiProblems); err != nil {
@@ -2567,6 +3066,22 @@ IMPORTANT: Return ONLY the JSON array, nothing else. No markdown formatting, no
 			DrawingData: aiProblem.DrawingData,
 			IsMultiPart: aiProblem.IsMultiPart,
 			Parts:       aiProblem.Parts,
+
+			Difficulty:       aiProblem.Difficulty,
+			Tags:             aiProblem.Tags,
+			EstimatedMinutes: aiProblem.EstimatedMinutes,
+			TimeLimitMs:      aiProblem.TimeLimitMs,
+			MemoryLimitMB:    aiProblem.MemoryLimitMB,
+
+			Examples: normalizeExamples(aiProblem.Examples),
+		}
+		if constraints, err := normalizeConstraints(aiProblem.Constraints); err == nil {
+			problems[i].Constraints = constraints
+		}
+		if err := checkValidator(aiProblem.Validator); err == nil {
+			problems[i].Validator = aiProblem.Validator
+		} else {
+			log.Printf("Dropping validator of generated problem %s: %v", problems[i].ID, err)
 		}
 	}
 
@@ -2626,6 +3141,9 @@ Return ONLY a JSON array (no markdown, no code blocks, no explanations) with thi
     "title": "System Design: [Topic]",
     "statement": "Design a [system description]. [Optional: brief context or example]",
     "type": "system_design",
+    "difficulty": "easy | medium | hard",
+    "tags": ["design", "caching"],
+    "estimatedMinutes": 45,
     "languages": [],
     "stub": {}
   }
@@ -2678,7 +3196,9 @@ Requirements:
 - Focus on %s role-specific skills and technologies
 - Include a mix of algorithmic, data structure, and practical problems
 - Each question should have a clear problem statement with examples
-- Include sample input/output examples
+- Include 1-3 examples; their input and output must be the exact stdin and stdout of a correct program
+- List the input bounds as constraints, one per entry, like "1 <= n <= 10^5"
+- Include a Python input validator that reads one test input from stdin, exits 0 if it is well-formed and within the constraints, and otherwise prints "line N: reason" and exits 1
 - Provide starter code stubs for %s (with %s as the primary/default language)%s
 
 Return ONLY a JSON array (no markdown, no code blocks, no explanations) with this exact format:
@@ -2686,8 +3206,16 @@ Return ONLY a JSON array (no markdown, no code blocks, no explanations) with thi
   {
     "id": "unique-kebab-case-id",
     "title": "Descriptive Title",
-    "statement": "Detailed problem description with examples and constraints",
+    "statement": "Detailed problem description with the input and output format",
     "type": "coding",
+    "difficulty": "easy | medium | hard",
+    "tags": ["graph", "dp"],
+    "estimatedMinutes": 30,
+    "timeLimitMs": 2000,
+    "memoryLimitMB": 256,
+    "examples": [{"input": "3\n1 2 3", "output": "6", "explanation": "1 + 2 + 3 = 6"}],
+    "constraints": ["1 <= n <= 10^5", "-10^9 <= a[i] <= 10^9"],
+    "validator": {"language": "python", "source": "import sys\n..."},
     "languages": %s,
     "stub": {
 %s    }%s
@@ -2832,6 +3360,9 @@ Return ONLY a JSON array (no markdown, no code blocks, no explanations) with thi
     "title": "System Design: [Topic]",
     "statement": "Design a [system description]. [Optional: brief context or example]",
     "type": "system_design",
+    "difficulty": "easy | medium | hard",
+    "tags": ["design", "caching"],
+    "estimatedMinutes": 45,
     "languages": [],
     "stub": {}
   }
@@ -2884,7 +3415,9 @@ Requirements:
 - Focus on %s role-specific skills and technologies
 - Include a mix of algorithmic, data structure, and practical problems
 - Each question should have a clear problem statement with examples
-- Include sample input/output examples
+- Include 1-3 examples; their input and output must be the exact stdin and stdout of a correct program
+- List the input bounds as constraints, one per entry, like "1 <= n <= 10^5"
+- Include a Python input validator that reads one test input from stdin, exits 0 if it is well-formed and within the constraints, and otherwise prints "line N: reason" and exits 1
 - Provide starter code stubs for %s (with %s as the primary/default language)%s
 
 Return ONLY a JSON array (no markdown, no code blocks, no explanations) with this exact format:
@@ -2892,8 +3425,16 @@ Return ONLY a JSON array (no markdown, no code blocks, no explanations) with thi
   {
     "id": "unique-kebab-case-id",
     "title": "Descriptive Title",
-    "statement": "Detailed problem description with examples and constraints",
+    "statement": "Detailed problem description with the input and output format",
     "type": "coding",
+    "difficulty": "easy | medium | hard",
+    "tags": ["graph", "dp"],
+    "estimatedMinutes": 30,
+    "timeLimitMs": 2000,
+    "memoryLimitMB": 256,
+    "examples": [{"input": "3\n1 2 3", "output": "6", "explanation": "1 + 2 + 3 = 6"}],
+    "constraints": ["1 <= n <= 10^5", "-10^9 <= a[i] <= 10^9"],
+    "validator": {"language": "python", "source": "import sys\n..."},
     "languages": %s,
     "stub": {
 %s    }%s
@@ -2997,6 +3538,16 @@ func parseAIResponse(responseText string, req AgentRequest) ([]Problem, error) {
 		DrawingData string            `json:"drawingData,omitempty"`
 		IsMultiPart bool              `json:"isMultiPart,omitempty"`
 		Parts       []Part            `json:"parts,omitempty"`
+
+		Difficulty       string   `json:"difficulty,omitempty"`
+		Tags             []string `json:"tags,omitempty"`
+		EstimatedMinutes int      `json:"estimatedMinutes,omitempty"`
+		TimeLimitMs      int      `json:"timeLimitMs,omitempty"`
+		MemoryLimitMB    int      `json:"memoryLimitMB,omitempty"`
+
+		Examples    []Example    `json:"examples,omitempty"`
+		Constraints []Constraint `json:"constraints,omitempty"`
+		Validator   *Validator   `json:"validator,omitempty"`
 	}
 
 	if err := json.Unmarshal([]byte(cleanText), &aiProblems); err != nil {
@@ -3051,6 +3602,22 @@ func parseAIResponse(responseText string, req AgentRequest) ([]Problem, error) {
 			DrawingData: aiProblem.DrawingData,
 			IsMultiPart: aiProblem.IsMultiPart,
 			Parts:       aiProblem.Parts,
+
+			Difficulty:       aiProblem.Difficulty,
+			Tags:             aiProblem.Tags,
+			EstimatedMinutes: aiProblem.EstimatedMinutes,
+			TimeLimitMs:      aiProblem.TimeLimitMs,
+			MemoryLimitMB:    aiProblem.MemoryLimitMB,
+
+			Examples: normalizeExamples(aiProblem.Examples),
+		}
+		if constraints, err := normalizeConstraints(aiProblem.Constraints); err == nil {
+			problems[i].Constraints = constraints
+		}
+		if err := checkValidator(aiProblem.Validator); err == nil {
+			problems[i].Validator = aiProblem.Validator
+		} else {
+			log.Printf("Dropping validator of generated problem %s: %v", problems[i].ID, err)
 		}
 	}
 
@@ -3087,15 +3654,17 @@ func cleanAIGuestions(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Check if this is an AI-generated problem
-		if isAIGeneratedProblem(entry.Name()) {
-			problemPath := filepath.Join(dataDir, entry.Name())
+		if isProblemDirName(entry.Name()) && isAIGeneratedProblem(entry.Name()) {
 			log.Printf("Removing AI-generated problem: %s", entry.Name())
 
-			// Remove the entire problem directory
-			if err := os.RemoveAll(problemPath); err != nil {
+			// Move the problem directory to the trash
+			before := loadProblem(entry.Name())
+			trashed, err := moveToTrash(entry.Name(), "cleaned")
+			if err != nil {
 				log.Printf("Error removing problem %s: %v", entry.Name(), err)
 				continue
 			}
+			recordAudit(r, "problem.delete", entry.Name(), before, nil, map[string]interface{}{"reason": trashed.Reason, "trashId": trashed.TrashID})
 			cleanedCount++
 		}
 	}
@@ -3118,6 +3687,35 @@ func clearAllProblems(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var req struct {
+		ConfirmToken string `json:"confirmToken"`
+	}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&req)
+	}
+	if req.ConfirmToken == "" {
+		req.ConfirmToken = r.URL.Query().Get("confirmToken")
+	}
+
+	// Without a valid token, hand out one the client has to send back
+	if !consumeClearToken(req.ConfirmToken) {
+		token, expires := issueClearToken()
+		message := "Clearing all problems requires confirmation; repeat the request with this confirmToken"
+		if req.ConfirmToken != "" {
+			message = "Invalid or expired confirmToken; repeat the request with this new one"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":       "confirmation_required",
+			"message":      message,
+			"confirmToken": token,
+			"expiresAt":    expires.UTC(),
+			"count":        len(problemCache.list()),
+		})
+		return
+	}
+
 	log.Printf("Clearing all problems from %s", dataDir)
 
 	// Get list of all problems
@@ -3136,25 +3734,27 @@ func clearAllProblems(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		// Skip the uploads directory
-		if entry.Name() == "uploads" {
+		// Skip the uploads directory and the trash itself
+		if !isProblemDirName(entry.Name()) {
 			continue
 		}
 
-		problemPath := filepath.Join(dataDir, entry.Name())
 		log.Printf("Removing problem: %s", entry.Name())
 
-		// Remove the entire problem directory
-		if err := os.RemoveAll(problemPath); err != nil {
+		// Move the problem directory to the trash
+		before := loadProblem(entry.Name())
+		trashed, err := moveToTrash(entry.Name(), "cleared")
+		if err != nil {
 			log.Printf("Error removing problem %s: %v", entry.Name(), err)
 			continue
 		}
+		recordAudit(r, "problem.delete", entry.Name(), before, nil, map[string]interface{}{"reason": trashed.Reason, "trashId": trashed.TrashID})
 		clearedCount++
 	}
 
 	response := map[string]interface{}{
 		"status":  "success",
-		"message": fmt.Sprintf("Cleared %d problems", clearedCount),
+		"message": fmt.Sprintf("Moved %d problems to the trash", clearedCount),
 		"count":   clearedCount,
 	}
 
@@ -3252,19 +3852,17 @@ func generateMockQuestions(req AgentRequest) []Problem {
 
 This is a fundamental problem for %s level %s positions at %s.
 
-Example:
-Input: nums = [1,2,3,4,5,6,7], k = 3
-Output: [5,6,7,1,2,3,4]
-
-Explanation:
-- Rotate 1 step to the right: [7,1,2,3,4,5,6]
-- Rotate 2 steps to the right: [6,7,1,2,3,4,5]
-- Rotate 3 steps to the right: [5,6,7,1,2,3,4]
-
-Constraints:
-- 1 <= nums.length <= 10^5
-- -2^31 <= nums[i] <= 2^31 - 1
-- 0 <= k <= 10^5`, req.Level, req.Role, req.Company),
+Input: n, then the n integers of nums on one line, then k.
+Output: the rotated array, space-separated.`, req.Level, req.Role, req.Company),
+			Examples: []Example{
+				{Input: "7\n1 2 3 4 5 6 7\n3", Output: "5 6 7 1 2 3 4", Explanation: "Rotating 1, 2 and 3 steps to the right gives [7,1,2,3,4,5,6], [6,7,1,2,3,4,5] and [5,6,7,1,2,3,4]."},
+			},
+			Constraints: []Constraint{
+				parseConstraint("1 <= nums.length <= 10^5"),
+				parseConstraint("-2^31 <= nums[i] <= 2^31 - 1"),
+				parseConstraint("0 <= k <= 10^5"),
+			},
+			Tags:      []string{"array", "two-pointers"},
 			Languages: []string{"python", "java", "cpp"},
 			Stub: map[string]string{
 				"python": "def rotate(nums, k):\n    # Your code here\n    pass",
@@ -3279,20 +3877,19 @@ Constraints:
 
 This problem tests your understanding of efficient search algorithms, important for %s level %s roles at %s.
 
-Example:
-Input: nums = [-1,0,3,5,9,12], target = 9
-Output: 4
-Explanation: 9 exists in nums and its index is 4
-
-Input: nums = [-1,0,3,5,9,12], target = 2
-Output: -1
-Explanation: 2 does not exist in nums so return -1
-
-Constraints:
-- 1 <= nums.length <= 10^4
-- -10^4 < nums[i], target < 10^4
-- All the integers in nums are unique
-- nums is sorted in ascending order`, req.Level, req.Role, req.Company),
+Input: n, then the n integers of nums on one line, then target.
+Output: the index of target in nums, or -1 if it is not there.`, req.Level, req.Role, req.Company),
+			Examples: []Example{
+				{Input: "6\n-1 0 3 5 9 12\n9", Output: "4", Explanation: "9 exists in nums and its index is 4"},
+				{Input: "6\n-1 0 3 5 9 12\n2", Output: "-1", Explanation: "2 does not exist in nums so return -1"},
+			},
+			Constraints: []Constraint{
+				parseConstraint("1 <= nums.length <= 10^4"),
+				parseConstraint("-10^4 < nums[i], target < 10^4"),
+				parseConstraint("All the integers in nums are unique"),
+				parseConstraint("nums is sorted in ascending order"),
+			},
+			Tags:      []string{"binary-search", "array"},
 			Languages: []string{"python", "java", "cpp"},
 			Stub: map[string]string{
 				"python": "def search(nums, target):\n    # Your code here\n    pass",
@@ -3307,22 +3904,18 @@ Constraints:
 
 This problem demonstrates string manipulation skills essential for %s level %s positions at %s.
 
-Example:
-Input: s = "abcabcbb"
-Output: 3
-Explanation: The answer is "abc", with the length of 3.
-
-Input: s = "bbbbb"
-Output: 1
-Explanation: The answer is "b", with the length of 1.
-
-Input: s = "pwwkew"
-Output: 3
-Explanation: The answer is "wke", with the length of 3.
-
-Constraints:
-- 0 <= s.length <= 5 * 10^4
-- s consists of English letters, digits, symbols and spaces`, req.Level, req.Role, req.Company),
+Input: the string s on one line.
+Output: the length of the longest substring of s without repeating characters.`, req.Level, req.Role, req.Company),
+			Examples: []Example{
+				{Input: "abcabcbb", Output: "3", Explanation: "The answer is \"abc\", with the length of 3."},
+				{Input: "bbbbb", Output: "1", Explanation: "The answer is \"b\", with the length of 1."},
+				{Input: "pwwkew", Output: "3", Explanation: "The answer is \"wke\", with the length of 3."},
+			},
+			Constraints: []Constraint{
+				parseConstraint("0 <= s.length <= 5 * 10^4"),
+				parseConstraint("s consists of English letters, digits, symbols and spaces"),
+			},
+			Tags:      []string{"string", "sliding-window", "hash-table"},
 			Languages: []string{"python", "java", "cpp"},
 			Stub: map[string]string{
 				"python": "def lengthOfLongestSubstring(s):\n    # Your code here\n    pass",
@@ -3355,6 +3948,24 @@ Constraints:
 }
 
 func saveGeneratedProblem(problem Problem) error {
+	// Work out the test cases first so that the validator can reject them
+	// before anything is written
+	var testCases []TestCase
+	if shouldGenerateTestCases(problem.ID) {
+		testCases = generateTestCases(problem.ID)
+		// The examples are better tests than placeholders
+		if len(problem.Examples) > 0 && reflect.DeepEqual(testCases, placeholderTestCases) {
+			testCases = nil
+		}
+	}
+	allTests := make([]TestCase, 0, len(problem.Examples)+len(testCases))
+	for _, ex := range problem.Examples {
+		allTests = append(allTests, TestCase{Input: ex.Input, Output: ex.Output})
+	}
+	if err := validateTestCases(problem, append(allTests, testCases...)); err != nil {
+		return err
+	}
+
 	// Create problem directory
 	problemDir := filepath.Join(dataDir, problem.ID)
 	if err := os.MkdirAll(problemDir, 0755); err != nil {
@@ -3377,6 +3988,7 @@ func saveGeneratedProblem(problem Problem) error {
 	if err := os.WriteFile(manifestPath, manifestData, 0644); err != nil {
 		return fmt.Errorf("failed to write manifest: %v", err)
 	}
+	problemCache.invalidate(problem.ID)
 
 	// Create basic test files
 	publicDir := filepath.Join(v1Dir, "public")
@@ -3385,9 +3997,7 @@ func saveGeneratedProblem(problem Problem) error {
 	}
 
 	// Generate test cases only if requested (optional)
-	if shouldGenerateTestCases(problem.ID) {
-		testCases := generateTestCases(problem.ID)
-
+	if len(testCases) > 0 {
 		for i, testCase := range testCases {
 			testNum := fmt.Sprintf("%02d", i+1)
 			inputFile := filepath.Join(publicDir, testNum+".in")
@@ -3403,7 +4013,7 @@ func saveGeneratedProblem(problem Problem) error {
 		}
 	}
 
-	return nil
+	return syncExampleTests(problem, nil)
 }
 
 func shouldGenerateTestCases(problemID string) bool {
@@ -3717,16 +4327,19 @@ func generateTestCases(problemID string) []TestCase {
 		}
 	} else {
 		// Default test cases
-		testCases = []TestCase{
-			{"test input 1", "test output 1"},
-			{"test input 2", "test output 2"},
-			{"test input 3", "test output 3"},
-		}
+		testCases = placeholderTestCases
 	}
 
 	return testCases
 }
 
+// placeholderTestCases are generated when nothing is known about a problem
+var placeholderTestCases = []TestCase{
+	{"test input 1", "test output 1"},
+	{"test input 2", "test output 2"},
+	{"test input 3", "test output 3"},
+}
+
 func generateSampleTestCase(problemID string) (string, string) {
 	// Generate comprehensive test cases based on problem type
 	if strings.Contains(problemID, "array-rotation") {
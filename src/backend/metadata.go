@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Problem metadata (difficulty, tags, company, estimated duration, author)
+// is stored in the manifest next to the statement. These helpers keep the
+// values consistent no matter whether they come from the editor, an AI
+// provider or an imported package.
+
+var validDifficulties = map[string]bool{"easy": true, "medium": true, "hard": true}
+
+// normalizeDifficulty maps free-form difficulty strings onto easy/medium/hard.
+// Unknown values are dropped.
+func normalizeDifficulty(d string) string {
+	d = strings.ToLower(strings.TrimSpace(d))
+	switch d {
+	case "beginner", "simple", "low":
+		d = "easy"
+	case "intermediate", "moderate", "mid":
+		d = "medium"
+	case "difficult", "advanced", "high", "expert":
+		d = "hard"
+	}
+	if !validDifficulties[d] {
+		return ""
+	}
+	return d
+}
+
+// difficultyForLevel guesses a difficulty from the seniority of the position
+func difficultyForLevel(level string) string {
+	level = strings.ToLower(level)
+	for _, s := range []string{"intern", "junior", "entry", "new grad", "graduate"} {
+		if strings.Contains(level, s) {
+			return "easy"
+		}
+	}
+	for _, s := range []string{"senior", "staff", "principal", "lead", "architect", "distinguished"} {
+		if strings.Contains(level, s) {
+			return "hard"
+		}
+	}
+	return "medium"
+}
+
+// normalizeTags lowercases, kebab-cases and de-duplicates topic tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range tags {
+		t = strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(t, "_", " "))), "-")
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// applyGeneratedMetadata fills in the metadata of a generated question that
+// the provider left out
+func applyGeneratedMetadata(p *Problem, req AgentRequest) {
+	p.Difficulty = normalizeDifficulty(p.Difficulty)
+	if p.Difficulty == "" {
+		p.Difficulty = difficultyForLevel(req.Level)
+	}
+	p.Tags = normalizeTags(p.Tags)
+	if p.Type == "system_design" && len(p.Tags) == 0 {
+		p.Tags = []string{"design"}
+	}
+	if p.Company == "" {
+		p.Company = req.Company
+	}
+	if p.EstimatedMinutes <= 0 {
+		p.EstimatedMinutes = 30
+		if p.Type == "system_design" {
+			p.EstimatedMinutes = 45
+		}
+	}
+	if p.Author == "" {
+		provider := strings.ToLower(req.Provider)
+		if provider == "" {
+			provider = "gemini"
+		}
+		p.Author = "ai:" + provider
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// startProcessGroup does nothing on this platform; only cmd's own process
+// is killed there
+func startProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills a started cmd
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
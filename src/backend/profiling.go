@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// /api/run can profile a program with "profile": true. The runner samples
+// the program's CPU use while it runs and the stacks are returned folded,
+// one "root;caller;callee count" line per distinct stack, which flame graph
+// tools (flamegraph.pl, speedscope, d3-flame-graph) read directly.
+//
+//	Python        a SIGPROF sampler in the interpreter, like py-spy
+//	Go            runtime/pprof, started around the program's main
+//	C, C++, Rust  perf record, when perf is installed
+
+const (
+	profileFileName     = ".ceesarcode-profile"
+	profileEnvVar       = "CEESARCODE_PROFILE_OUT"
+	pythonSampleRateHz  = 200
+	perfSampleRateHz    = 999
+	pprofSamplePeriod   = 10 * time.Millisecond // runtime/pprof samples at 100 Hz
+	goProfileMainName   = "ceesarcodeMain"
+	goProfileSourceName = "ceesarcode_profile.go"
+)
+
+// CPUProfile is the CPU profile of a run
+type CPUProfile struct {
+	Profiler     string `json:"profiler,omitempty"` // "sampler", "pprof" or "perf"
+	SampleRateHz int    `json:"sampleRateHz,omitempty"`
+	Samples      int    `json:"samples"`
+	Folded       string `json:"folded"`          // folded stacks, heaviest first
+	Error        string `json:"error,omitempty"` // why there is no profile
+}
+
+// pythonProfiler runs the program given as its first argument and writes
+// the stacks it samples on SIGPROF, which counts CPU time, as folded stacks
+const pythonProfiler = `
+import collections, os, runpy, signal, sys
+
+OUT = os.environ.pop("CEESARCODE_PROFILE_OUT")
+RATE = int(os.environ.pop("CEESARCODE_PROFILE_RATE", "200"))
+main = os.path.abspath(sys.argv[1])
+root = os.path.dirname(main)
+skip = {"<string>", "<frozen runpy>", runpy.__file__}
+counts = collections.Counter()
+
+
+def label(code):
+    name = code.co_filename
+    if name.startswith(root + os.sep):
+        name = name[len(root) + 1:]
+    return "%s (%s:%d)" % (getattr(code, "co_qualname", code.co_name), name, code.co_firstlineno)
+
+
+def sample(signum, frame):
+    stack = []
+    while frame is not None:
+        if frame.f_code.co_filename not in skip:
+            stack.append(label(frame.f_code))
+        frame = frame.f_back
+    if stack:
+        counts[";".join(reversed(stack))] += 1
+
+
+def dump():
+    signal.setitimer(signal.ITIMER_PROF, 0, 0)
+    with open(OUT, "w") as f:
+        for stack, n in counts.items():
+            f.write("%s %d\n" % (stack, n))
+
+
+sys.argv = sys.argv[1:]
+sys.path[0] = root
+signal.signal(signal.SIGPROF, sample)
+signal.setitimer(signal.ITIMER_PROF, 1.0 / RATE, 1.0 / RATE)
+try:
+    runpy.run_path(main, run_name="__main__")
+finally:
+    dump()
+`
+
+// goProfileMain replaces the program's main, which is renamed
+// goProfileMainName, and profiles it. os.Exit skips deferred calls, so the
+// program's calls to os.Exit and log.Fatal are rewritten to the functions
+// here (see goExitWrappers), which write the profile before exiting.
+const goProfileMain = `package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime/pprof"
+)
+
+var ceesarcodeProfile *os.File
+
+func main() {
+	if f, err := os.Create(os.Getenv("` + profileEnvVar + `")); err == nil {
+		if pprof.StartCPUProfile(f) == nil {
+			ceesarcodeProfile = f
+		} else {
+			f.Close()
+		}
+	}
+	defer ceesarcodeStopProfile()
+	` + goProfileMainName + `()
+}
+
+func ceesarcodeStopProfile() {
+	if ceesarcodeProfile != nil {
+		pprof.StopCPUProfile()
+		ceesarcodeProfile.Close()
+		ceesarcodeProfile = nil
+	}
+}
+
+func ceesarcodeExit(code int) {
+	ceesarcodeStopProfile()
+	os.Exit(code)
+}
+
+func ceesarcodeFatal(v ...any) {
+	log.Output(2, fmt.Sprint(v...))
+	ceesarcodeExit(1)
+}
+
+func ceesarcodeFatalf(format string, v ...any) {
+	log.Output(2, fmt.Sprintf(format, v...))
+	ceesarcodeExit(1)
+}
+
+func ceesarcodeFatalln(v ...any) {
+	log.Output(2, fmt.Sprintln(v...))
+	ceesarcodeExit(1)
+}
+`
+
+// goExitWrappers maps the functions that exit without running deferred
+// calls, by import path, to their replacements in goProfileMain
+var goExitWrappers = map[string]map[string]string{
+	"os":  {"Exit": "ceesarcodeExit"},
+	"log": {"Fatal": "ceesarcodeFatal", "Fatalf": "ceesarcodeFatalf", "Fatalln": "ceesarcodeFatalln"},
+}
+
+// injectGoProfiler renames func main in the package in pkgDir, points its
+// calls to os.Exit and log.Fatal at goExitWrappers, and adds a main that
+// runs it under runtime/pprof
+func injectGoProfiler(pkgDir string) error {
+	files, _ := filepath.Glob(filepath.Join(pkgDir, "*.go"))
+	found := false
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil // the build reports it
+		}
+		changed := rewriteGoExits(file)
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+				fn.Name.Name = goProfileMainName
+				found, changed = true, true
+			}
+		}
+		if !changed {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, file); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("func main not found")
+	}
+	return os.WriteFile(filepath.Join(pkgDir, goProfileSourceName), []byte(goProfileMain), 0644)
+}
+
+// rewriteGoExits replaces the calls in file to the functions of
+// goExitWrappers and reports whether it changed anything. The imports stay
+// in use through a blank variable, in case nothing else refers to them.
+func rewriteGoExits(file *ast.File) bool {
+	names := map[string]string{} // import name to path
+	for _, spec := range file.Imports {
+		path := strings.Trim(spec.Path.Value, `"`)
+		if _, ok := goExitWrappers[path]; !ok {
+			continue
+		}
+		name := path
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name != "_" && name != "." {
+			names[name] = path
+		}
+	}
+	if len(names) == 0 {
+		return false
+	}
+	used := map[string]string{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		// Obj is only set for identifiers declared in the file, which
+		// shadow the import
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok || pkg.Obj != nil {
+			return true
+		}
+		if wrapper, ok := goExitWrappers[names[pkg.Name]][sel.Sel.Name]; ok {
+			used[pkg.Name] = sel.Sel.Name
+			call.Fun = ast.NewIdent(wrapper)
+		}
+		return true
+	})
+	for name, fn := range used {
+		file.Decls = append(file.Decls, &ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{&ast.ValueSpec{
+			Names:  []*ast.Ident{ast.NewIdent("_")},
+			Values: []ast.Expr{&ast.SelectorExpr{X: ast.NewIdent(name), Sel: ast.NewIdent(fn)}},
+		}}})
+	}
+	return len(used) > 0
+}
+
+// readFoldedProfile fills p from the folded stacks the Python sampler
+// wrote in dir
+func readFoldedProfile(p *CPUProfile, dir string) {
+	data, err := os.ReadFile(filepath.Join(dir, profileFileName))
+	if err != nil {
+		p.Error = "the program exited before the profile was written"
+		return
+	}
+	counts := map[string]int{}
+	for _, line := range strings.Split(string(data), "\n") {
+		i := strings.LastIndexByte(line, ' ')
+		if i <= 0 {
+			continue
+		}
+		var n int
+		if _, err := fmt.Sscan(line[i+1:], &n); err == nil {
+			counts[line[:i]] += n
+		}
+	}
+	setFoldedStacks(p, counts)
+}
+
+// readPprofProfile fills p from the runtime/pprof profile exe wrote in dir
+func readPprofProfile(p *CPUProfile, exe, dir string) {
+	path := filepath.Join(dir, profileFileName)
+	// The file is created empty and only written when the profile stops,
+	// which a crash or a signal skips
+	if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+		p.Error = "the program exited before the profile was written"
+		return
+	}
+	out, err := exec.Command("go", "tool", "pprof", "-traces", exe, path).Output()
+	if err != nil {
+		p.Error = fmt.Sprintf("go tool pprof failed: %v", err)
+		return
+	}
+	// Each trace is a "-----+-----" separator, then the sample's value
+	// and leaf function, then one line per caller
+	counts := map[string]int{}
+	var stack []string
+	var value time.Duration
+	flush := func() {
+		if len(stack) > 0 && value > 0 {
+			for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
+				stack[i], stack[j] = stack[j], stack[i]
+			}
+			counts[strings.Join(stack, ";")] += int((value + pprofSamplePeriod/2) / pprofSamplePeriod)
+		}
+		stack, value = nil, 0
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "---"):
+			flush()
+		case strings.HasPrefix(line, " "):
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			if len(stack) == 0 {
+				d, err := time.ParseDuration(fields[0])
+				if err != nil || len(fields) < 2 {
+					continue
+				}
+				value, fields = d, fields[1:]
+			}
+			switch fn := strings.TrimSuffix(strings.Join(fields, " "), " (inline)"); fn {
+			case "main.main":
+				// goProfileMain, which runs the program's main
+			case "main." + goProfileMainName:
+				stack = append(stack, "main.main")
+			default:
+				stack = append(stack, fn)
+			}
+		}
+	}
+	flush()
+	setFoldedStacks(p, counts)
+}
+
+// profileCommand makes cmd write its profile to the file the program's
+// profiler reads from the environment
+func profileCommand(cmd *exec.Cmd, dir string) {
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, profileEnvVar+"="+filepath.Join(dir, profileFileName))
+}
+
+// nativeProfileFlags are added to the compile flags of C, C++ and Rust
+// when profiling, so perf can walk the stack
+func nativeProfileFlags(language string) []string {
+	if language == "rust" {
+		return []string{"-C", "force-frame-pointers=yes"}
+	}
+	return []string{"-fno-omit-frame-pointer"}
+}
+
+// perfCommand wraps cmd in perf record when p is set and perf is installed
+func perfCommand(cmd *exec.Cmd, dir string, p *CPUProfile) *exec.Cmd {
+	if p == nil {
+		return cmd
+	}
+	if _, err := exec.LookPath("perf"); err != nil {
+		p.Error = "perf is not installed"
+		return cmd
+	}
+	p.Profiler, p.SampleRateHz = "perf", perfSampleRateHz
+	args := []string{"record", "-q", "-F", fmt.Sprint(perfSampleRateHz), "-g", "-o", filepath.Join(dir, profileFileName), "--", cmd.Path}
+	wrapped := exec.Command("perf", append(args, cmd.Args[1:]...)...)
+	wrapped.Dir, wrapped.Env, wrapped.Stdin = cmd.Dir, cmd.Env, cmd.Stdin
+	return wrapped
+}
+
+// readPerfProfile fills p from the samples perfCommand recorded in dir,
+// folding the output of perf script like stackcollapse-perf.pl
+func readPerfProfile(p *CPUProfile, dir string) {
+	if p == nil || p.Profiler != "perf" {
+		return
+	}
+	out, err := exec.Command("perf", "script", "-i", filepath.Join(dir, profileFileName)).Output()
+	if err != nil {
+		p.Error = fmt.Sprintf("perf script failed: %v", err)
+		return
+	}
+	counts := map[string]int{}
+	var stack []string
+	flush := func() {
+		if len(stack) > 0 {
+			for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
+				stack[i], stack[j] = stack[j], stack[i]
+			}
+			counts[strings.Join(stack, ";")]++
+		}
+		stack = nil
+	}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			continue // the sample's header
+		}
+		// "\t    55d4c1a0 fib+0x10 (/tmp/.../main)"
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		symbol := strings.Join(fields[1:], " ")
+		if i := strings.LastIndex(symbol, " ("); i > 0 {
+			symbol = symbol[:i]
+		}
+		if i := strings.LastIndex(symbol, "+0x"); i > 0 {
+			symbol = symbol[:i]
+		}
+		stack = append(stack, symbol)
+	}
+	flush()
+	setFoldedStacks(p, counts)
+}
+
+// setFoldedStacks stores counts in p, heaviest stack first
+func setFoldedStacks(p *CPUProfile, counts map[string]int) {
+	stacks := make([]string, 0, len(counts))
+	p.Samples = 0
+	for s, n := range counts {
+		if n > 0 {
+			stacks = append(stacks, s)
+			p.Samples += n
+		}
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		if counts[stacks[i]] != counts[stacks[j]] {
+			return counts[stacks[i]] > counts[stacks[j]]
+		}
+		return stacks[i] < stacks[j]
+	})
+	var b strings.Builder
+	for _, s := range stacks {
+		fmt.Fprintf(&b, "%s %d\n", s, counts[s])
+	}
+	p.Folded = b.String()
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Submissions to /api/run and /api/submit are projects: a map of paths
+// relative to the project root to file contents. Paths keep their
+// directories so that Java packages, C++ headers and Python packages work,
+// and the runners build the whole tree. The file the program starts from is
+// the request's entry point, else the language's conventional file name,
+// else the shallowest source file of the language.
+
+const (
+	maxProjectFiles = 200
+	maxProjectBytes = 8 << 20
+	maxProjectDepth = 16
+)
+
+// sourceExtensions are the extensions of each language's source files
+var sourceExtensions = map[string][]string{
+	"python":     {".py"},
+	"javascript": {".js", ".mjs", ".cjs"},
+	"typescript": {".ts"},
+	"java":       {".java"},
+	"cpp":        {".cpp", ".cc", ".cxx"},
+	"c":          {".c"},
+	"go":         {".go"},
+	"rust":       {".rs"},
+	"swift":      {".swift"},
+	"ruby":       {".rb"},
+	"bash":       {".sh", ".bash"},
+	"sh":         {".sh", ".bash"},
+}
+
+// entryPointNames are tried in order before any other source file
+var entryPointNames = map[string][]string{
+	"bash": {"main.sh", "script.sh", "Main.sh", "Script.sh"},
+	"sh":   {"main.sh", "script.sh", "Main.sh", "Script.sh"},
+}
+
+var javaPackageRe = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)\s*;`)
+
+// cleanProjectPath validates a path of a project file and returns it in
+// slash-separated form
+func cleanProjectPath(name string) (string, error) {
+	p := strings.ReplaceAll(name, "\\", "/")
+	switch {
+	case p == "":
+		return "", fmt.Errorf("file name must not be empty")
+	case strings.ContainsRune(p, 0):
+		return "", fmt.Errorf("file name %q contains a NUL byte", name)
+	case strings.HasPrefix(p, "/") || filepath.VolumeName(p) != "":
+		return "", fmt.Errorf("file name %q must be relative", name)
+	}
+	segments := strings.Split(p, "/")
+	if len(segments) > maxProjectDepth {
+		return "", fmt.Errorf("file name %q is nested too deeply", name)
+	}
+	for _, s := range segments {
+		if s == "" || s == "." || s == ".." || len(s) > 255 {
+			return "", fmt.Errorf("invalid file name %q", name)
+		}
+	}
+	if segments[0] == uploadsMountDir {
+		return "", fmt.Errorf("%s/ is reserved for the problem's uploaded files", uploadsMountDir)
+	}
+	return p, nil
+}
+
+// cleanProject validates the files of a project and returns them by
+// cleaned path
+func cleanProject(files map[string]string) (map[string]string, error) {
+	if len(files) > maxProjectFiles {
+		return nil, fmt.Errorf("too many files (%d, limit %d)", len(files), maxProjectFiles)
+	}
+	cleaned := make(map[string]string, len(files))
+	total := 0
+	for name, content := range files {
+		p, err := cleanProjectPath(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := cleaned[p]; dup {
+			return nil, fmt.Errorf("file %q is given more than once", p)
+		}
+		cleaned[p] = content
+		total += len(content)
+	}
+	if total > maxProjectBytes {
+		return nil, fmt.Errorf("files are larger than the %d MB limit", maxProjectBytes>>20)
+	}
+	for p := range cleaned {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			if _, ok := cleaned[dir]; ok {
+				return nil, fmt.Errorf("%q is both a file and a directory", dir)
+			}
+		}
+	}
+	return cleaned, nil
+}
+
+// writeProject validates files and writes them under dir, keeping their
+// directories
+func writeProject(dir string, files map[string]string) error {
+	cleaned, err := cleanProject(files)
+	if err != nil {
+		return err
+	}
+	for p, content := range cleaned {
+		target := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkEntryPoint validates a requested entry point against the project's
+// files and returns it cleaned
+func checkEntryPoint(entryPoint string, files map[string]string) (string, error) {
+	if entryPoint == "" {
+		return "", nil
+	}
+	p, err := cleanProjectPath(entryPoint)
+	if err != nil {
+		return "", err
+	}
+	for name := range files {
+		if n, err := cleanProjectPath(name); err == nil && n == p {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("entry point %q is not one of the files", entryPoint)
+}
+
+// projectSources lists the source files of language under dir as
+// slash-separated relative paths, shallowest first. Hidden directories and
+// the uploads mount are skipped.
+func projectSources(dir, language string) []string {
+	exts := sourceExtensions[language]
+	var sources []string
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && (strings.HasPrefix(d.Name(), ".") || rel == uploadsMountDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, ext := range exts {
+			if strings.HasSuffix(d.Name(), ext) {
+				sources = append(sources, rel)
+				break
+			}
+		}
+		return nil
+	})
+	sort.SliceStable(sources, func(i, j int) bool {
+		di, dj := strings.Count(sources[i], "/"), strings.Count(sources[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return sources[i] < sources[j]
+	})
+	return sources
+}
+
+// findEntryPoint returns the absolute path of the file a program in dir
+// starts from: entryPoint when given, else the language's conventional
+// file name at the root, else the shallowest source file
+func findEntryPoint(dir, language, entryPoint string) (string, error) {
+	if entryPoint != "" {
+		p := filepath.Join(dir, filepath.FromSlash(entryPoint))
+		if _, err := os.Stat(p); err != nil {
+			return "", fmt.Errorf("entry point %s not found", entryPoint)
+		}
+		return p, nil
+	}
+	names := entryPointNames[language]
+	if name, ok := programFileNames[language]; ok && len(names) == 0 {
+		names = []string{name}
+	}
+	for _, name := range names {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	if sources := projectSources(dir, language); len(sources) > 0 {
+		return filepath.Join(dir, filepath.FromSlash(sources[0])), nil
+	}
+	return "", fmt.Errorf("no %s source file found", language)
+}
+
+// sourcePaths returns the absolute paths of the project's sources of
+// language
+func sourcePaths(dir, language string) []string {
+	sources := projectSources(dir, language)
+	for i, s := range sources {
+		sources[i] = filepath.Join(dir, filepath.FromSlash(s))
+	}
+	return sources
+}
+
+// javaMainClass returns the fully qualified name of the class in a Java
+// source file, from its package declaration and file name
+func javaMainClass(file string) string {
+	class := strings.TrimSuffix(filepath.Base(file), ".java")
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return class
+	}
+	if m := javaPackageRe.FindSubmatch(src); m != nil {
+		return string(m[1]) + "." + class
+	}
+	return class
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// limitMemory caps the data segment (RLIMIT_DATA) of a started process at
+// limitMB, so allocations past it fail while the program runs. Processes
+// it starts afterwards inherit the limit.
+func limitMemory(p *os.Process, limitMB int) error {
+	bytes := uint64(limitMB) << 20
+	return unix.Prlimit(p.Pid, unix.RLIMIT_DATA, &unix.Rlimit{Cur: bytes, Max: bytes}, nil)
+}
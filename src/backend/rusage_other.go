@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// peakMemoryMB is not available on this platform; memory limits are only
+// enforced by the executor there
+func peakMemoryMB(ps *os.ProcessState) int {
+	return 0
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// peakMemoryMB returns the peak resident set size of a finished process
+func peakMemoryMB(ps *os.ProcessState) int {
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// ru_maxrss is in bytes on macOS and in kilobytes everywhere else
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int(ru.Maxrss >> 20)
+	}
+	return int(ru.Maxrss >> 10)
+}
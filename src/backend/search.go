@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query parameters understood by GET /api/problems:
+//
+//	q           full-text search over title and statement (all terms must match)
+//	type        coding | system_design
+//	tag         topic tag; repeat or comma-separate to require several
+//	difficulty  easy | medium | hard; comma-separate to allow several
+//	language    problems that accept this language
+//	company     case-insensitive company match
+//	sort        id | title | difficulty | duration, "-" prefix for descending
+//	limit       page size (all matches when omitted)
+//	cursor      X-Next-Cursor value of the previous page
+//	view        full (default) | summary
+//
+// The response body stays a JSON array; X-Total-Count carries the number of
+// matches and X-Next-Cursor is set when there are more pages.
+
+const maxProblemPageSize = 500
+
+// ProblemSummary is the lightweight projection used by the sidebar
+type ProblemSummary struct {
+	ID               string   `json:"ID"`
+	Title            string   `json:"Title"`
+	Type             string   `json:"Type,omitempty"`
+	Difficulty       string   `json:"Difficulty,omitempty"`
+	Tags             []string `json:"Tags,omitempty"`
+	Company          string   `json:"Company,omitempty"`
+	Languages        []string `json:"Languages,omitempty"`
+	EstimatedMinutes int      `json:"EstimatedMinutes,omitempty"`
+	IsMultiPart      bool     `json:"IsMultiPart,omitempty"`
+	Locales          []string `json:"Locales,omitempty"` // set when the statement has translations
+}
+
+func summarizeProblem(p Problem) ProblemSummary {
+	s := ProblemSummary{
+		ID:               p.ID,
+		Title:            p.Title,
+		Type:             p.Type,
+		Difficulty:       p.Difficulty,
+		Tags:             p.Tags,
+		Company:          p.Company,
+		Languages:        p.Languages,
+		EstimatedMinutes: p.EstimatedMinutes,
+		IsMultiPart:      p.IsMultiPart,
+	}
+	if len(p.Statements) > 0 {
+		s.Locales = problemLocales(p)
+	}
+	return s
+}
+
+// ProblemQuery is a parsed /api/problems query
+type ProblemQuery struct {
+	Terms        []string
+	Type         string
+	Tags         []string
+	Difficulties []string
+	Language     string
+	Company      string
+	Sort         string
+	Desc         bool
+	Limit        int
+	After        *problemCursor
+	Summary      bool
+}
+
+// problemCursor holds the sort keys of the last problem of a page, so the
+// next page starts right after it even if problems were added or removed
+type problemCursor struct {
+	ID      string `json:"id"`
+	Title   string `json:"t,omitempty"`
+	Rank    int    `json:"d,omitempty"`
+	Minutes int    `json:"m,omitempty"`
+}
+
+var difficultyRank = map[string]int{"easy": 1, "medium": 2, "hard": 3}
+
+func parseProblemQuery(v url.Values) (ProblemQuery, error) {
+	q := ProblemQuery{
+		Terms:    strings.Fields(strings.ToLower(v.Get("q"))),
+		Type:     v.Get("type"),
+		Language: strings.ToLower(v.Get("language")),
+		Company:  strings.ToLower(strings.TrimSpace(v.Get("company"))),
+		Sort:     "id",
+	}
+	for _, t := range splitListParam(v["tag"]) {
+		q.Tags = append(q.Tags, normalizeTags([]string{t})...)
+	}
+	for _, d := range splitListParam(v["difficulty"]) {
+		nd := normalizeDifficulty(d)
+		if nd == "" {
+			return q, fmt.Errorf("unknown difficulty %q", d)
+		}
+		q.Difficulties = append(q.Difficulties, nd)
+	}
+
+	if s := v.Get("sort"); s != "" {
+		q.Desc = strings.HasPrefix(s, "-")
+		q.Sort = strings.TrimPrefix(s, "-")
+		switch q.Sort {
+		case "id", "title", "difficulty", "duration":
+		default:
+			return q, fmt.Errorf("sort must be one of id, title, difficulty, duration")
+		}
+	}
+
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("limit must be a positive integer")
+		}
+		q.Limit = min(n, maxProblemPageSize)
+	}
+
+	if s := v.Get("cursor"); s != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return q, fmt.Errorf("invalid cursor")
+		}
+		var c problemCursor
+		if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" {
+			return q, fmt.Errorf("invalid cursor")
+		}
+		q.After = &c
+	}
+
+	switch v.Get("view") {
+	case "", "full":
+	case "summary":
+		q.Summary = true
+	default:
+		return q, fmt.Errorf("view must be full or summary")
+	}
+	return q, nil
+}
+
+// splitListParam flattens repeated and comma-separated query values
+func splitListParam(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// matches reports whether p passes every filter of the query
+func (q ProblemQuery) matches(p Problem) bool {
+	if q.Type != "" {
+		t := p.Type
+		if t == "" {
+			t = "coding"
+		}
+		if t != q.Type {
+			return false
+		}
+	}
+	if len(q.Difficulties) > 0 && !containsString(q.Difficulties, p.Difficulty) {
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !containsString(p.Tags, tag) {
+			return false
+		}
+	}
+	if q.Language != "" && !containsString(p.Languages, q.Language) {
+		return false
+	}
+	if q.Company != "" && strings.ToLower(p.Company) != q.Company {
+		return false
+	}
+	if len(q.Terms) > 0 {
+		text := p.Title + "\n" + p.Statement
+		for _, t := range p.Statements {
+			text += "\n" + t
+		}
+		text = strings.ToLower(text)
+		for _, term := range q.Terms {
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func cursorFor(p Problem) problemCursor {
+	return problemCursor{
+		ID:      p.ID,
+		Title:   strings.ToLower(p.Title),
+		Rank:    difficultyRank[p.Difficulty],
+		Minutes: p.EstimatedMinutes,
+	}
+}
+
+// less orders two problems by the query's sort key, breaking ties by ID so
+// that the order (and therefore the cursor) is stable
+func (q ProblemQuery) less(a, b problemCursor) bool {
+	cmp := 0
+	switch q.Sort {
+	case "title":
+		cmp = strings.Compare(a.Title, b.Title)
+	case "difficulty":
+		cmp = a.Rank - b.Rank
+	case "duration":
+		cmp = a.Minutes - b.Minutes
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.ID, b.ID)
+		if q.Sort != "id" {
+			return cmp < 0
+		}
+	}
+	if q.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// apply filters, sorts and pages problems. It returns the page, the total
+// number of matches and the cursor of the next page ("" on the last page).
+func (q ProblemQuery) apply(problems []Problem) ([]Problem, int, string) {
+	matched := make([]Problem, 0, len(problems))
+	for _, p := range problems {
+		if q.matches(p) {
+			matched = append(matched, p)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return q.less(cursorFor(matched[i]), cursorFor(matched[j]))
+	})
+	total := len(matched)
+
+	page := matched
+	if q.After != nil {
+		start := sort.Search(len(page), func(i int) bool {
+			return q.less(*q.After, cursorFor(page[i]))
+		})
+		page = page[start:]
+	}
+
+	next := ""
+	if q.Limit > 0 && len(page) > q.Limit {
+		page = page[:q.Limit]
+		raw, _ := json.Marshal(cursorFor(page[len(page)-1]))
+		next = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return page, total, next
+}
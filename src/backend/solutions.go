@@ -0,0 +1,573 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Reference solutions live next to the tests in v1/solutions/{name}.{ext},
+// never in the manifest, so that they are not served to candidates with
+// the problem. They are used to write the expected outputs: POST
+// /api/problems/{id}/outputs runs every reference solution over every test
+// input of every part, writes the primary solution's output to the .out
+// files and flags tests on which the solutions disagree.
+
+const (
+	solutionsDirName     = "solutions"
+	primarySolutionName  = "main" // used as the primary solution unless another is named
+	maxOutputPreviewSize = 1 << 10
+)
+
+var (
+	solutionNameRe     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+	solutionNameCharRe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// Program is a single-file program stored with a problem, such as a
+// reference solution or a test generator
+type Program struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Source   string `json:"source,omitempty"`
+}
+
+// languageForFile maps a source file name to the language that runs it
+func languageForFile(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".cc", ".cxx", ".c++":
+		return "cpp"
+	case ".bash":
+		return "bash"
+	}
+	for lang, file := range programFileNames {
+		if filepath.Ext(file) == ext {
+			return lang
+		}
+	}
+	return ""
+}
+
+// programKind is a directory of programs under v1
+type programKind struct {
+	dir   string // also the plural used in routes and responses
+	noun  string
+	title string
+}
+
+var (
+	solutionPrograms  = programKind{dir: solutionsDirName, noun: "solution", title: "Solution"}
+	generatorPrograms = programKind{dir: generatorsDirName, noun: "generator", title: "Generator"}
+)
+
+func (k programKind) path(problemID string) string {
+	return filepath.Join(dataDir, problemID, "v1", k.dir)
+}
+
+// list reads the programs of a problem sorted by name
+func (k programKind) list(problemID string) ([]Program, error) {
+	entries, err := os.ReadDir(k.path(problemID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var progs []Program
+	for _, e := range entries {
+		lang := languageForFile(e.Name())
+		if e.IsDir() || lang == "" {
+			continue
+		}
+		src, err := os.ReadFile(filepath.Join(k.path(problemID), e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		progs = append(progs, Program{Name: name, Language: lang, Source: string(src)})
+	}
+	return progs, nil
+}
+
+// file finds the file a named program is stored in
+func (k programKind) file(problemID, name string) string {
+	matches, _ := filepath.Glob(filepath.Join(k.path(problemID), name+".*"))
+	for _, m := range matches {
+		if languageForFile(m) != "" {
+			return m
+		}
+	}
+	return ""
+}
+
+// get reads a named program; ok is false when there is none
+func (k programKind) get(problemID, name string) (Program, bool) {
+	file := k.file(problemID, name)
+	if file == "" {
+		return Program{}, false
+	}
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return Program{}, false
+	}
+	return Program{Name: name, Language: languageForFile(file), Source: string(src)}, true
+}
+
+// validate checks the name, language and source of a program before it is
+// written
+func (k programKind) validate(p Program) error {
+	if !solutionNameRe.MatchString(p.Name) {
+		return fmt.Errorf("invalid %s name %q", k.noun, p.Name)
+	}
+	if _, ok := programFileNames[p.Language]; !ok {
+		return fmt.Errorf("language %q is not supported", p.Language)
+	}
+	if strings.TrimSpace(p.Source) == "" {
+		return fmt.Errorf("source must not be empty")
+	}
+	return nil
+}
+
+// write stores a program, replacing one of the same name in any language
+func (k programKind) write(problemID string, p Program) error {
+	if err := k.validate(p); err != nil {
+		return err
+	}
+	file := programFileNames[p.Language]
+	if err := os.MkdirAll(k.path(problemID), 0755); err != nil {
+		return err
+	}
+	if old := k.file(problemID, p.Name); old != "" {
+		os.Remove(old)
+	}
+	return os.WriteFile(filepath.Join(k.path(problemID), p.Name+filepath.Ext(file)), []byte(p.Source), 0644)
+}
+
+// listSolutions reads a problem's reference solutions sorted by name, the
+// primary one first
+func listSolutions(problemID string) ([]Program, error) {
+	sols, err := solutionPrograms.list(problemID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(sols, func(i, j int) bool {
+		if (sols[i].Name == primarySolutionName) != (sols[j].Name == primarySolutionName) {
+			return sols[i].Name == primarySolutionName
+		}
+		return sols[i].Name < sols[j].Name
+	})
+	return sols, nil
+}
+
+// solutionFileName turns an imported file name into a valid solution file
+// name, keeping its extension
+func solutionFileName(file string) string {
+	ext := filepath.Ext(file)
+	name := solutionNameCharRe.ReplaceAllString(strings.TrimSuffix(file, ext), "-")
+	name = strings.Trim(name, "-_")
+	if name == "" {
+		name = "solution"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name + strings.ToLower(ext)
+}
+
+// OutputMismatch is a test on which the reference solutions disagree;
+// Outputs maps solution names to (possibly truncated) outputs
+type OutputMismatch struct {
+	Test    string            `json:"test"`
+	Outputs map[string]string `json:"outputs"`
+}
+
+// SolutionFailure is a reference solution that crashed or timed out on a test
+type SolutionFailure struct {
+	Test     string `json:"test"`
+	Solution string `json:"solution"`
+	Error    string `json:"error"`
+}
+
+// OutputReport is the result of running the reference solutions
+type OutputReport struct {
+	Primary       string            `json:"primary"`
+	Solutions     []string          `json:"solutions"`
+	Tests         int               `json:"tests"`
+	Updated       []string          `json:"updated"`
+	Disagreements []OutputMismatch  `json:"disagreements"`
+	Failures      []SolutionFailure `json:"failures"`
+}
+
+// outputsAgree compares outputs line by line, ignoring trailing whitespace
+func outputsAgree(a, b string) bool {
+	al := strings.Split(strings.TrimRight(a, " \t\r\n"), "\n")
+	bl := strings.Split(strings.TrimRight(b, " \t\r\n"), "\n")
+	if len(al) != len(bl) {
+		return false
+	}
+	for i := range al {
+		if strings.TrimRight(al[i], " \t\r") != strings.TrimRight(bl[i], " \t\r") {
+			return false
+		}
+	}
+	return true
+}
+
+func previewOutput(s string) string {
+	if len(s) <= maxOutputPreviewSize {
+		return s
+	}
+	return s[:maxOutputPreviewSize] + fmt.Sprintf("... (%d bytes)", len(s))
+}
+
+// runSolutions runs every solution over the inputs. outputs[s][i] is the
+// output of solution s on input i, and ok[s][i] whether it ran cleanly.
+func runSolutions(p Problem, sols []Program, inputs []string) (outputs [][]string, ok [][]bool, err error) {
+	outputs = make([][]string, len(sols))
+	ok = make([][]bool, len(sols))
+	for s, sol := range sols {
+		outputs[s] = make([]string, len(inputs))
+		ok[s] = make([]bool, len(inputs))
+		opts := runOptionsFromLimits(p.limitsFor(sol.Language))
+		opts.Uploads = p.ID
+		opts.Build, _ = p.buildFlagsFor("", sol.Language)
+		err := runProgram(sol.Language, sol.Source, inputs, opts, func(i int, stdout, stderr string, runErr error) bool {
+			if runErr != nil {
+				outputs[s][i] = strings.TrimSpace(stderr + "\n" + runErr.Error())
+				return true
+			}
+			outputs[s][i] = stdout
+			ok[s][i] = true
+			return true
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("solution %s could not be run: %v", sol.Name, err)
+		}
+	}
+	return outputs, ok, nil
+}
+
+// compareSolutions builds the report of a run; the primary solution is
+// sols[0]. Tests where the primary failed or any solution disagrees are
+// left out of the returned outputs.
+func compareSolutions(names []string, sols []Program, outputs [][]string, ok [][]bool) (OutputReport, map[int]string) {
+	report := OutputReport{
+		Primary:       sols[0].Name,
+		Tests:         len(names),
+		Disagreements: []OutputMismatch{},
+		Failures:      []SolutionFailure{},
+	}
+	for _, s := range sols {
+		report.Solutions = append(report.Solutions, s.Name)
+	}
+	good := make(map[int]string)
+	for i, name := range names {
+		for s, sol := range sols {
+			if !ok[s][i] {
+				report.Failures = append(report.Failures, SolutionFailure{Test: name, Solution: sol.Name, Error: previewOutput(outputs[s][i])})
+			}
+		}
+		if !ok[0][i] {
+			continue
+		}
+		agree := true
+		for s := 1; s < len(sols); s++ {
+			if ok[s][i] && !outputsAgree(outputs[0][i], outputs[s][i]) {
+				agree = false
+			}
+		}
+		if !agree {
+			m := OutputMismatch{Test: name, Outputs: make(map[string]string)}
+			for s, sol := range sols {
+				if ok[s][i] {
+					m.Outputs[sol.Name] = previewOutput(outputs[s][i])
+				}
+			}
+			report.Disagreements = append(report.Disagreements, m)
+			continue
+		}
+		good[i] = outputs[0][i]
+	}
+	return report, good
+}
+
+// orderSolutions moves the named solution to the front
+func orderSolutions(sols []Program, primary string) ([]Program, error) {
+	if primary == "" {
+		return sols, nil
+	}
+	for i, s := range sols {
+		if s.Name == primary {
+			ordered := append([]Program{s}, sols[:i]...)
+			return append(ordered, sols[i+1:]...), nil
+		}
+	}
+	return nil, fmt.Errorf("no reference solution named %q", primary)
+}
+
+// regenerateOutputs runs the reference solutions over the tests of every
+// test directory of a problem. Unless dryRun is set it writes the new expected outputs; tests
+// the solutions disagree on are only written when force is set, and then
+// get the primary solution's output.
+func regenerateOutputs(problemID, primary string, dryRun, force bool) (OutputReport, error) {
+	p, found := readProblemManifest(dataDir, problemID)
+	if !found {
+		return OutputReport{}, os.ErrNotExist
+	}
+	sols, err := listSolutions(problemID)
+	if err != nil {
+		return OutputReport{}, err
+	}
+	if len(sols) == 0 {
+		return OutputReport{}, fmt.Errorf("problem has no reference solutions")
+	}
+	if sols, err = orderSolutions(sols, primary); err != nil {
+		return OutputReport{}, err
+	}
+
+	// Inputs of every test directory are run in one batch; tests of parts
+	// are reported as part{N}/{test}
+	dirs := findTestDirs(problemID)
+	if len(dirs) == 0 {
+		return OutputReport{}, fmt.Errorf("problem has no tests")
+	}
+	var names, paths, inputs []string
+	var inMain []bool // whether the test is in the main test directory
+	for d, dir := range dirs {
+		for _, name := range listTestNames(dir) {
+			in, err := os.ReadFile(filepath.Join(dir, name+".in"))
+			if err != nil {
+				return OutputReport{}, err
+			}
+			label := name
+			if d > 0 {
+				label = filepath.Base(filepath.Dir(dir)) + "/" + name
+			}
+			names = append(names, label)
+			paths = append(paths, filepath.Join(dir, name+".out"))
+			inputs = append(inputs, string(in))
+			inMain = append(inMain, d == 0)
+		}
+	}
+	if len(names) == 0 {
+		return OutputReport{}, fmt.Errorf("problem has no tests")
+	}
+
+	outputs, ok, err := runSolutions(p, sols, inputs)
+	if err != nil {
+		return OutputReport{}, err
+	}
+	report, good := compareSolutions(names, sols, outputs, ok)
+	if force {
+		for _, m := range report.Disagreements {
+			for i, name := range names {
+				if name == m.Test {
+					good[i] = outputs[0][i]
+				}
+			}
+		}
+	}
+
+	report.Updated = []string{}
+	newOutputs := make(map[string]string) // input -> output, for the examples
+	for i, name := range names {
+		out, write := good[i]
+		if !write {
+			continue
+		}
+		if inMain[i] {
+			newOutputs[inputs[i]] = out
+		}
+		path := paths[i]
+		dir, test := filepath.Dir(path), strings.TrimSuffix(filepath.Base(path), ".out")
+		meta := testMetaByName(dir)[test]
+		if old, err := os.ReadFile(path); err == nil && outputsAgree(string(old), out) && !meta.Pending {
+			continue
+		}
+		report.Updated = append(report.Updated, name)
+		if dryRun {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+			return report, err
+		}
+		if meta.Pending {
+			meta.Pending = false
+			if err := setTestMeta(dir, test, meta); err != nil {
+				log.Printf("Failed to clear pending output of %s: %v", name, err)
+			}
+		}
+	}
+	if !dryRun && len(report.Updated) > 0 {
+		updateExampleOutputs(problemID, newOutputs)
+	}
+	return report, nil
+}
+
+// updateExampleOutputs copies regenerated outputs into the manifest
+// examples with the same input, so that they stay in sync with the tests
+func updateExampleOutputs(problemID string, outputs map[string]string) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	p, ok := readProblemManifest(dataDir, problemID)
+	if !ok {
+		return
+	}
+	changed := false
+	for i, ex := range p.Examples {
+		out, ok := outputs[ex.Input]
+		if ok && !outputsAgree(out, ex.Output) {
+			p.Examples[i].Output = strings.TrimRight(out, " \t\r\n")
+			changed = true
+		}
+	}
+	if changed {
+		if err := saveProblemManifest(p); err != nil {
+			log.Printf("Failed to update example outputs of %s: %v", problemID, err)
+		}
+	}
+}
+
+// handleProgramRoutes serves /api/problems/{id}/{solutions|generators}[/{name}]
+func handleProgramRoutes(w http.ResponseWriter, r *http.Request, k programKind, problemID, name string) {
+	if decoded, err := url.QueryUnescape(problemID); err == nil {
+		problemID = decoded
+	}
+	if !isValidProblemID(problemID) || !problemExists(problemID) {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+
+	if name == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		list := k.list
+		if k == solutionPrograms {
+			list = listSolutions
+		}
+		progs, err := list(problemID)
+		if err != nil {
+			log.Printf("Error listing %s of %s: %v", k.dir, problemID, err)
+			http.Error(w, "Internal server error", 500)
+			return
+		}
+		if progs == nil {
+			progs = []Program{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", k.dir: progs})
+		return
+	}
+
+	if !solutionNameRe.MatchString(name) {
+		http.Error(w, "Invalid "+k.noun+" name", 400)
+		return
+	}
+	var before interface{}
+	if p, ok := k.get(problemID, name); ok {
+		before = p
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if before == nil {
+			http.Error(w, k.title+" not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(before)
+
+	case http.MethodPut:
+		var p Program
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, "Invalid JSON", 400)
+			return
+		}
+		p.Name = name
+		p.Language = strings.ToLower(strings.TrimSpace(p.Language))
+		if err := k.write(problemID, p); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		recordAudit(r, k.noun+".update", problemID, before, p, map[string]interface{}{k.noun: name})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", k.noun: Program{Name: name, Language: p.Language}})
+
+	case http.MethodDelete:
+		if before == nil {
+			http.Error(w, k.title+" not found", http.StatusNotFound)
+			return
+		}
+		if err := os.Remove(k.file(problemID, name)); err != nil {
+			log.Printf("Error deleting %s %s of %s: %v", k.noun, name, problemID, err)
+			http.Error(w, "Internal server error", 500)
+			return
+		}
+		recordAudit(r, k.noun+".delete", problemID, before, nil, map[string]interface{}{k.noun: name})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+
+	default:
+		http.Error(w, "GET, PUT or DELETE only", 405)
+	}
+}
+
+// generateOutputsHandler handles POST /api/problems/{id}/outputs
+func generateOutputsHandler(w http.ResponseWriter, r *http.Request, problemID string) {
+	if decoded, err := url.QueryUnescape(problemID); err == nil {
+		problemID = decoded
+	}
+	if !isValidProblemID(problemID) {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Solution string `json:"solution"` // primary solution (default "main", else the first by name)
+		DryRun   bool   `json:"dryRun"`
+		Force    bool   `json:"force"` // also write tests the solutions disagree on
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", 400)
+			return
+		}
+	}
+
+	before := snapshotTestCases(problemID)
+	report, err := regenerateOutputs(problemID, req.Solution, req.DryRun, req.Force)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "Problem not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+		return
+	}
+	if !req.DryRun && len(report.Updated) > 0 {
+		recordAudit(r, "testcases.outputs", problemID, before, snapshotTestCases(problemID), map[string]interface{}{"solution": report.Primary})
+		log.Printf("Regenerated %d outputs of %s with solution %s", len(report.Updated), problemID, report.Primary)
+	}
+
+	status := "success"
+	if len(report.Disagreements) > 0 || len(report.Failures) > 0 {
+		status = "conflict"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Status string `json:"status"`
+		OutputReport
+	}{status, report})
+}
@@ -0,0 +1,659 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Test cases can be managed one at a time and uploaded in bulk as well as
+// through PUT /api/problem/{id}/testcases:
+//
+//	POST   /api/problem/{id}/testcases/upload       zip of NN.in/NN.out files
+//	POST   /api/problem/{id}/testcases              add a case as the next number
+//	GET    /api/problem/{id}/testcases/{name}       one case
+//	PUT    /api/problem/{id}/testcases/{name}       add or replace one case
+//	DELETE /api/problem/{id}/testcases/{name}       delete one case
+//	GET    /api/problem/{id}/testcases/{name}/input (or output) raw file
+//	PUT    /api/problem/{id}/testcases/{name}/input (or output) raw file
+//
+// Listings inline small files only; larger ones come back as a preview with
+// their size and are fetched through the raw file routes.
+
+const (
+	maxInlineTestBytes = 64 << 10  // files up to this size are listed in full
+	testPreviewBytes   = 1 << 10   // preview of larger files
+	maxTestFileBytes   = 256 << 20 // one .in or .out file
+	maxTestJSONBytes   = 16 << 20  // JSON body of a single test case
+)
+
+// TestCaseInfo describes a stored test case. Input and Output hold the
+// whole file unless Truncated is set, in which case they are previews.
+type TestCaseInfo struct {
+	Name       string `json:"name"`
+	Input      string `json:"input"`
+	Output     string `json:"output"`
+	InputSize  int64  `json:"inputSize"`
+	OutputSize int64  `json:"outputSize"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	TestMeta
+}
+
+// readTestFile reads a test file, or only its first testPreviewBytes when
+// full is false or the file is larger than maxInlineTestBytes
+func readTestFile(file string, full bool) (content string, size int64, truncated bool, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, false, err
+	}
+	size = info.Size()
+	limit := size
+	if !full || size > maxInlineTestBytes {
+		limit = testPreviewBytes
+	}
+	data, err := io.ReadAll(io.LimitReader(f, limit))
+	if err != nil {
+		return "", 0, false, err
+	}
+	if int64(len(data)) < size {
+		// don't cut a UTF-8 sequence in half
+		for len(data) > 0 && !utf8.Valid(data) {
+			data = data[:len(data)-1]
+		}
+		truncated = true
+	}
+	return string(data), size, truncated, nil
+}
+
+// readTestCaseInfo reads the named test from dir
+func readTestCaseInfo(dir, name string, meta TestMeta, full bool) (TestCaseInfo, error) {
+	tc := TestCaseInfo{Name: name, TestMeta: meta}
+	if group := testGroupOf(name); group != "" {
+		tc.Group = group
+	}
+	in, inSize, inCut, err := readTestFile(filepath.Join(dir, name+".in"), full)
+	if err != nil {
+		return tc, err
+	}
+	out, outSize, outCut, err := readTestFile(filepath.Join(dir, name+".out"), full)
+	if err != nil {
+		return tc, err
+	}
+	tc.Input, tc.InputSize, tc.Output, tc.OutputSize = in, inSize, out, outSize
+	tc.Truncated = inCut || outCut
+	return tc, nil
+}
+
+// isValidTestName accepts names for hand-written tests; "group-NN" names
+// are reserved for generated test groups
+func isValidTestName(name string) bool {
+	return solutionNameRe.MatchString(name) && testGroupOf(name) == ""
+}
+
+// ensurePublicTestDir returns the public test directory, creating v1/public
+// when the problem has none
+func ensurePublicTestDir(problemID string) (string, error) {
+	if dir := findPublicTestDir(problemID); dir != "" {
+		return dir, nil
+	}
+	dir := filepath.Join(dataDir, problemID, "v1", "public")
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// nextTestName returns the number after the highest numbered test in dir
+func nextTestName(dir string) string {
+	highest := 0
+	for _, name := range listTestNames(dir) {
+		if n, err := strconv.Atoi(name); err == nil && n > highest {
+			highest = n
+		}
+	}
+	return fmt.Sprintf("%02d", highest+1)
+}
+
+// writeTestFile streams src into file through a temporary file, so that a
+// failed or oversized upload leaves the old file in place
+func writeTestFile(file string, src io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".test-*.tmp")
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(tmp, io.LimitReader(src, maxTestFileBytes+1))
+	if err == nil && n > maxTestFileBytes {
+		err = fmt.Errorf("%s is larger than %d MB", filepath.Base(file), maxTestFileBytes>>20)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	os.Chmod(tmp.Name(), 0644)
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// validateTestInput runs the problem's validator over one named input
+func validateTestInput(p Problem, name, input string) error {
+	if p.Validator == nil {
+		return nil
+	}
+	errs, err := validateInputs(*p.Validator, []string{name}, []string{input})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &InputValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// handleTestCaseRoutes serves /api/problem/{id}/testcases/... except for
+// the list-wide GET, PUT and DELETE
+func handleTestCaseRoutes(w http.ResponseWriter, r *http.Request, problemID string, rest []string) {
+	if decoded, err := url.QueryUnescape(problemID); err == nil {
+		problemID = decoded
+	}
+	if !isValidProblemID(problemID) || !problemExists(problemID) {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodPost:
+		addTestCase(w, r, problemID)
+	case len(rest) == 1 && rest[0] == "upload":
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", 405)
+			return
+		}
+		uploadTestArchive(w, r, problemID)
+	case len(rest) == 1:
+		handleTestCase(w, r, problemID, rest[0])
+	case len(rest) == 2 && (rest[1] == "input" || rest[1] == "output"):
+		handleTestFile(w, r, problemID, rest[0], rest[1])
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// addTestCase handles POST /api/problem/{id}/testcases
+func addTestCase(w http.ResponseWriter, r *http.Request, problemID string) {
+	dir, err := ensurePublicTestDir(problemID)
+	if err != nil {
+		log.Printf("Failed to create test directory of %s: %v", problemID, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	putTestCase(w, r, problemID, nextTestName(dir))
+}
+
+// handleTestCase serves GET, PUT and DELETE of one test case
+func handleTestCase(w http.ResponseWriter, r *http.Request, problemID, name string) {
+	if !solutionNameRe.MatchString(name) {
+		http.Error(w, "Invalid test name", 400)
+		return
+	}
+	dir := findPublicTestDir(problemID)
+	exists := false
+	if dir != "" {
+		_, err := os.Stat(filepath.Join(dir, name+".in"))
+		exists = err == nil
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			http.Error(w, "Test case not found", http.StatusNotFound)
+			return
+		}
+		tc, err := readTestCaseInfo(dir, name, testMetaByName(dir)[name], r.URL.Query().Get("preview") == "")
+		if err != nil {
+			http.Error(w, "Test case not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tc)
+
+	case http.MethodPut:
+		if !isValidTestName(name) && !exists {
+			http.Error(w, "Invalid test name: names like group-01 are reserved for generated tests", 400)
+			return
+		}
+		putTestCase(w, r, problemID, name)
+
+	case http.MethodDelete:
+		if !exists {
+			http.Error(w, "Test case not found", http.StatusNotFound)
+			return
+		}
+		before := snapshotTestCases(problemID)
+		os.Remove(filepath.Join(dir, name+".in"))
+		os.Remove(filepath.Join(dir, name+".out"))
+		pruneTestMeta(dir)
+		recordAudit(r, "testcases.delete", problemID, before, snapshotTestCases(problemID), map[string]interface{}{"test": name})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+
+	default:
+		http.Error(w, "GET, PUT or DELETE only", 405)
+	}
+}
+
+// putTestCase stores one test case from a JSON body with its input, output
+// and metadata. Input and output may be left out to keep the stored files,
+// and so may metadata fields.
+func putTestCase(w http.ResponseWriter, r *http.Request, problemID, name string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxTestJSONBytes)
+	var raw map[string]json.RawMessage
+	var tc TestCaseInfo
+	data, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(data, &raw)
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &tc)
+	}
+	if err != nil {
+		http.Error(w, "Invalid JSON (upload larger files with PUT .../input and .../output)", 400)
+		return
+	}
+	dir, err := ensurePublicTestDir(problemID)
+	if err != nil {
+		log.Printf("Failed to create test directory of %s: %v", problemID, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	_, hasInput := raw["input"]
+	_, hasOutput := raw["output"]
+	if _, err := os.Stat(filepath.Join(dir, name+".in")); err != nil && (!hasInput || !hasOutput) {
+		http.Error(w, "input and output are required", 400)
+		return
+	}
+	meta := mergeTestMeta(raw, tc.TestMeta, testMetaByName(dir)[name])
+	if err := checkTestMeta(name, meta); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if hasInput {
+		if err := validateTestInput(loadProblem(problemID), name, tc.Input); err != nil {
+			writeValidationFailure(w, err)
+			return
+		}
+	}
+
+	before := snapshotTestCases(problemID)
+	if hasInput {
+		err = writeTestFile(filepath.Join(dir, name+".in"), strings.NewReader(tc.Input))
+	}
+	if err == nil && hasOutput {
+		err = writeTestFile(filepath.Join(dir, name+".out"), strings.NewReader(tc.Output))
+	}
+	if err == nil {
+		err = setTestMeta(dir, name, meta)
+	}
+	if err == nil {
+		recordAudit(r, "testcases.update", problemID, before, snapshotTestCases(problemID), map[string]interface{}{"test": name, "meta": meta})
+	}
+	if err != nil {
+		log.Printf("Failed to write test %s of %s: %v", name, problemID, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "name": name})
+}
+
+// handleTestFile serves the raw .in or .out file of a test; PUT streams the
+// request body into it
+func handleTestFile(w http.ResponseWriter, r *http.Request, problemID, name, which string) {
+	if !solutionNameRe.MatchString(name) {
+		http.Error(w, "Invalid test name", 400)
+		return
+	}
+	ext := ".in"
+	if which == "output" {
+		ext = ".out"
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		dir := findPublicTestDir(problemID)
+		if dir == "" {
+			http.Error(w, "Test case not found", http.StatusNotFound)
+			return
+		}
+		f, err := os.Open(filepath.Join(dir, name+ext))
+		if err != nil {
+			http.Error(w, "Test case not found", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+		modTime := time.Time{}
+		if info, err := f.Stat(); err == nil {
+			modTime = info.ModTime()
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+ext))
+		http.ServeContent(w, r, name+ext, modTime, f)
+
+	case http.MethodPut:
+		dir, err := ensurePublicTestDir(problemID)
+		if err != nil {
+			log.Printf("Failed to create test directory of %s: %v", problemID, err)
+			http.Error(w, "Internal server error", 500)
+			return
+		}
+		file := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(file); err != nil && !isValidTestName(name) {
+			http.Error(w, "Invalid test name: names like group-01 are reserved for generated tests", 400)
+			return
+		}
+		before := snapshotTestCases(problemID)
+		if err := writeTestFile(file, r.Body); err != nil {
+			log.Printf("Failed to write %s%s of %s: %v", name, ext, problemID, err)
+			http.Error(w, "Failed to store file: "+err.Error(), 400)
+			return
+		}
+		if ext == ".in" {
+			if in, err := os.ReadFile(file); err == nil {
+				if err := validateTestInput(loadProblem(problemID), name, string(in)); err != nil {
+					// put the previous input back rather than keep a rejected one
+					if old, ok := before[name]; ok {
+						os.WriteFile(file, []byte(old.Input), 0644)
+					} else {
+						os.Remove(file)
+					}
+					writeValidationFailure(w, err)
+					return
+				}
+			}
+		}
+		recordAudit(r, "testcases.update", problemID, before, snapshotTestCases(problemID), map[string]interface{}{"test": name, "file": which})
+		info, _ := os.Stat(file)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "name": name, "size": info.Size()})
+
+	default:
+		http.Error(w, "GET or PUT only", 405)
+	}
+}
+
+// uploadTestArchive handles POST /api/problem/{id}/testcases/upload. The
+// archive is streamed to a temporary file and its tests are extracted into
+// a staging directory; only once every pair is complete and valid are they
+// moved into place. ?mode=replace removes the hand-written tests first,
+// the default (merge) keeps tests the archive doesn't contain.
+func uploadTestArchive(w http.ResponseWriter, r *http.Request, problemID string) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "merge"
+	}
+	if mode != "merge" && mode != "replace" {
+		http.Error(w, "mode must be merge or replace", 400)
+		return
+	}
+
+	archivePath, err := receiveArchive(w, r)
+	if err != nil {
+		http.Error(w, "Failed to read archive: "+err.Error(), 400)
+		return
+	}
+	defer os.Remove(archivePath)
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		http.Error(w, "Invalid zip archive: "+err.Error(), 400)
+		return
+	}
+	defer zr.Close()
+
+	fail := func(err error) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+	}
+
+	files, metaFile, err := archiveTestFiles(&zr.Reader)
+	if err != nil {
+		fail(err)
+		return
+	}
+	var archiveMeta []testMetaEntry
+	if metaFile != nil {
+		if archiveMeta, err = readArchiveTestMeta(metaFile); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	dir, err := ensurePublicTestDir(problemID)
+	if err != nil {
+		log.Printf("Failed to create test directory of %s: %v", problemID, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(dir), ".upload-")
+	if err != nil {
+		log.Printf("Failed to create staging directory for %s: %v", problemID, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	defer os.RemoveAll(staging)
+
+	for file, f := range files {
+		rc, err := f.Open()
+		if err == nil {
+			err = writeTestFile(filepath.Join(staging, file), rc)
+			rc.Close()
+		}
+		if err != nil {
+			fail(fmt.Errorf("%s: %v", f.Name, err))
+			return
+		}
+	}
+	names := listTestNames(staging)
+	uploaded := make(map[string]bool)
+	for _, name := range names {
+		uploaded[name] = true
+	}
+	var listed []testMetaEntry // archive entries in archive order
+	for _, e := range archiveMeta {
+		if uploaded[e.Test] {
+			listed = append(listed, e)
+		}
+	}
+	names = orderByEntries(names, listed)
+
+	if p := loadProblem(problemID); p.Validator != nil {
+		inputs := make([]string, len(names))
+		for i, name := range names {
+			in, err := os.ReadFile(filepath.Join(staging, name+".in"))
+			if err != nil {
+				fail(err)
+				return
+			}
+			inputs[i] = string(in)
+		}
+		errs, err := validateInputs(*p.Validator, names, inputs)
+		if err == nil && len(errs) > 0 {
+			err = &InputValidationError{Errors: errs}
+		}
+		if err != nil {
+			writeValidationFailure(w, err)
+			return
+		}
+	}
+
+	before := snapshotTestCases(problemID)
+	oldMeta := readTestMeta(dir)
+	if mode == "replace" {
+		removePlainTests(dir)
+	}
+	var bytes int64
+	for file := range files {
+		if info, err := os.Stat(filepath.Join(staging, file)); err == nil {
+			bytes += info.Size()
+		}
+		if err := os.Rename(filepath.Join(staging, file), filepath.Join(dir, file)); err != nil {
+			log.Printf("Failed to move uploaded test %s of %s: %v", file, problemID, err)
+			http.Error(w, "Internal server error", 500)
+			return
+		}
+	}
+	// Metadata from the archive's tests.json replaces that of the uploaded
+	// tests, which are listed after the tests that are kept
+	metaOf := make(map[string]TestMeta)
+	for _, e := range listed {
+		metaOf[e.Test] = e.TestMeta
+	}
+	var entries []testMetaEntry
+	if mode == "merge" {
+		for _, e := range oldMeta {
+			if !uploaded[e.Test] {
+				entries = append(entries, e)
+			}
+		}
+		// tests that had no entry keep their place before the upload
+		for _, name := range listTestNames(dir) {
+			if !uploaded[name] && !hasEntry(entries, name) {
+				entries = append(entries, testMetaEntry{Test: name})
+			}
+		}
+	}
+	for _, name := range names {
+		entries = append(entries, testMetaEntry{Test: name, TestMeta: metaOf[name]})
+	}
+	if mode == "replace" {
+		for _, e := range oldMeta {
+			if testGroupOf(e.Test) != "" {
+				entries = append(entries, e)
+			}
+		}
+	}
+	if err := writeTestMeta(dir, entries); err != nil {
+		log.Printf("Failed to store test metadata of %s: %v", problemID, err)
+	}
+	recordAudit(r, "testcases.upload", problemID, before, snapshotTestCases(problemID), map[string]interface{}{"mode": mode, "tests": len(names)})
+	log.Printf("Uploaded %d tests (%d bytes) to %s", len(names), bytes, problemID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"mode":   mode,
+		"tests":  names,
+		"bytes":  bytes,
+	})
+}
+
+// archiveTestFiles picks the test files out of an uploaded archive, keyed
+// by the name they are stored under, and its tests.json if there is one.
+// Directories inside the archive are ignored and .ans files are taken as
+// .out; every input needs an output.
+func archiveTestFiles(zr *zip.Reader) (map[string]*zip.File, *zip.File, error) {
+	files := make(map[string]*zip.File)
+	var metaFile *zip.File
+	var total uint64
+	for _, f := range zr.File {
+		base := path.Base(f.Name)
+		if f.FileInfo().IsDir() || strings.HasPrefix(base, ".") || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		if base == testMetaFileName {
+			if metaFile != nil {
+				return nil, nil, fmt.Errorf("archive contains more than one %s", testMetaFileName)
+			}
+			metaFile = f
+			continue
+		}
+		ext := path.Ext(base)
+		name := strings.TrimSuffix(base, ext)
+		switch ext {
+		case ".in", ".out":
+		case ".ans":
+			ext = ".out"
+		default:
+			continue
+		}
+		if !isValidTestName(name) {
+			return nil, nil, fmt.Errorf("%s: invalid test name (use letters, digits, - and _; names like group-01 are reserved for generated tests)", f.Name)
+		}
+		if _, dup := files[name+ext]; dup {
+			return nil, nil, fmt.Errorf("%s: test %s appears twice", f.Name, name+ext)
+		}
+		if f.UncompressedSize64 > maxTestFileBytes {
+			return nil, nil, fmt.Errorf("%s is larger than %d MB", f.Name, maxTestFileBytes>>20)
+		}
+		total += f.UncompressedSize64
+		if total > maxBundleUncompressedBytes {
+			return nil, nil, fmt.Errorf("archive is larger than %d MB uncompressed", maxBundleUncompressedBytes>>20)
+		}
+		files[name+ext] = f
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("archive contains no .in/.out files")
+	}
+	var unpaired []string
+	for file := range files {
+		name := strings.TrimSuffix(file, path.Ext(file))
+		if _, ok := files[name+".in"]; !ok {
+			unpaired = append(unpaired, name+".in")
+		}
+		if _, ok := files[name+".out"]; !ok {
+			unpaired = append(unpaired, name+".out")
+		}
+	}
+	if len(unpaired) > 0 {
+		sort.Strings(unpaired)
+		return nil, nil, fmt.Errorf("missing %s", strings.Join(unpaired, ", "))
+	}
+	return files, metaFile, nil
+}
+
+// readArchiveTestMeta reads a tests.json uploaded with an archive
+func readArchiveTestMeta(f *zip.File) ([]testMetaEntry, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var entries []testMetaEntry
+	if err := json.NewDecoder(io.LimitReader(rc, 1<<20)).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", f.Name, err)
+	}
+	for _, e := range entries {
+		if err := checkTestMeta(e.Test, e.TestMeta); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+	}
+	return entries, nil
+}
+
+func hasEntry(entries []testMetaEntry, name string) bool {
+	for _, e := range entries {
+		if e.Test == name {
+			return true
+		}
+	}
+	return false
+}
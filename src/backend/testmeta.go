@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// What authors record about individual tests is kept next to the test files
+// in tests.json, so that it survives edits that rewrite the files:
+//
+//	[
+//	  {"test": "01", "title": "Example 1", "sample": true},
+//	  {"test": "big", "title": "n = 10^5", "description": "all equal", "timeLimitMs": 4000}
+//	]
+//
+// The order of the entries is the order the tests are listed and judged in;
+// tests without an entry follow, sorted by name.
+
+const testMetaFileName = "tests.json"
+
+// TestMeta describes one test
+type TestMeta struct {
+	Title       string `json:"title,omitempty"`       // human-readable name
+	Description string `json:"description,omitempty"` // what the test exercises
+	Sample      bool   `json:"sample,omitempty"`      // shown to candidates with the statement
+	Group       string `json:"group,omitempty"`       // e.g. "edge cases"; the group of generated tests
+	TimeLimitMs int    `json:"timeLimitMs,omitempty"` // overrides the problem's time limit for this test
+	Pending     bool   `json:"pending,omitempty"`     // the .out file is a placeholder until the reference solutions generate it
+}
+
+// testMetaEntry is one entry of tests.json
+type testMetaEntry struct {
+	Test string `json:"test"`
+	TestMeta
+}
+
+// storedTest is a hand-written test with its file name and metadata
+type storedTest struct {
+	Name string
+	TestCase
+	Meta TestMeta
+}
+
+// readTestMeta reads the tests.json of a test directory; a missing or
+// unreadable file means no metadata
+func readTestMeta(dir string) []testMetaEntry {
+	data, err := os.ReadFile(filepath.Join(dir, testMetaFileName))
+	if err != nil {
+		return nil
+	}
+	var entries []testMetaEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Printf("Ignoring invalid %s: %v", filepath.Join(dir, testMetaFileName), err)
+		return nil
+	}
+	return entries
+}
+
+// testMetaByName indexes the metadata of a test directory by test name
+func testMetaByName(dir string) map[string]TestMeta {
+	metas := make(map[string]TestMeta)
+	for _, e := range readTestMeta(dir) {
+		metas[e.Test] = e.TestMeta
+	}
+	return metas
+}
+
+// writeTestMeta stores the metadata of a test directory, dropping entries
+// of tests that no longer exist
+func writeTestMeta(dir string, entries []testMetaEntry) error {
+	kept := make([]testMetaEntry, 0, len(entries))
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if seen[e.Test] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Test+".in")); err != nil {
+			continue
+		}
+		seen[e.Test] = true
+		kept = append(kept, e)
+	}
+	file := filepath.Join(dir, testMetaFileName)
+	if len(kept) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeTestFile(file, strings.NewReader(string(data)))
+}
+
+// pruneTestMeta drops the metadata of deleted tests
+func pruneTestMeta(dir string) {
+	if err := writeTestMeta(dir, readTestMeta(dir)); err != nil {
+		log.Printf("Failed to update %s: %v", filepath.Join(dir, testMetaFileName), err)
+	}
+}
+
+// setTestMeta stores the metadata of one test, keeping its position
+func setTestMeta(dir, name string, meta TestMeta) error {
+	entries := readTestMeta(dir)
+	for i := range entries {
+		if entries[i].Test == name {
+			entries[i].TestMeta = meta
+			return writeTestMeta(dir, entries)
+		}
+	}
+	if meta == (TestMeta{}) {
+		return nil
+	}
+	// list every test so that the new entry doesn't move ahead of tests
+	// that had none
+	metas := testMetaByName(dir)
+	entries = entries[:0]
+	for _, test := range listTestNames(dir) {
+		if test == name {
+			entries = append(entries, testMetaEntry{Test: test, TestMeta: meta})
+		} else {
+			entries = append(entries, testMetaEntry{Test: test, TestMeta: metas[test]})
+		}
+	}
+	return writeTestMeta(dir, entries)
+}
+
+// checkTestMeta rejects metadata the backend can't use
+func checkTestMeta(name string, meta TestMeta) error {
+	if meta.TimeLimitMs < 0 {
+		return fmt.Errorf("test %s: timeLimitMs must not be negative", name)
+	}
+	if len(meta.Title) > 200 || strings.ContainsAny(meta.Title, "\r\n") {
+		return fmt.Errorf("test %s: title must be a single line of at most 200 characters", name)
+	}
+	return nil
+}
+
+// mergeTestMeta returns meta with the fields missing from raw (a test as
+// sent by a client) taken from old, so that clients which don't know about
+// metadata don't clear it
+func mergeTestMeta(raw map[string]json.RawMessage, meta, old TestMeta) TestMeta {
+	if _, ok := raw["title"]; !ok {
+		meta.Title = old.Title
+	}
+	if _, ok := raw["description"]; !ok {
+		meta.Description = old.Description
+	}
+	if _, ok := raw["sample"]; !ok {
+		meta.Sample = old.Sample
+	}
+	if _, ok := raw["group"]; !ok {
+		meta.Group = old.Group
+	}
+	if _, ok := raw["timeLimitMs"]; !ok {
+		meta.TimeLimitMs = old.TimeLimitMs
+	}
+	if _, ok := raw["pending"]; !ok {
+		meta.Pending = old.Pending
+	}
+	return meta
+}
+
+// readStoredTests reads the hand-written tests of dir in order
+func readStoredTests(dir string) []storedTest {
+	metas := testMetaByName(dir)
+	var tests []storedTest
+	for _, name := range listTestNames(dir) {
+		if testGroupOf(name) != "" {
+			continue
+		}
+		in, _ := os.ReadFile(filepath.Join(dir, name+".in"))
+		out, _ := os.ReadFile(filepath.Join(dir, name+".out"))
+		tests = append(tests, storedTest{Name: name, TestCase: TestCase{Input: string(in), Output: string(out)}, Meta: metas[name]})
+	}
+	return tests
+}
+
+// nameStoredTests gives tests without a usable name the lowest free numbers
+func nameStoredTests(tests []storedTest) {
+	used := make(map[string]bool)
+	for i, tc := range tests {
+		if isValidTestName(tc.Name) && !used[tc.Name] {
+			used[tc.Name] = true
+		} else {
+			tests[i].Name = ""
+		}
+	}
+	n := 0
+	for i := range tests {
+		if tests[i].Name != "" {
+			continue
+		}
+		for {
+			n++
+			name := fmt.Sprintf("%02d", n)
+			if !used[name] {
+				used[name] = true
+				tests[i].Name = name
+				break
+			}
+		}
+	}
+}
+
+// writeStoredTests makes tests, in order, the hand-written tests of dir.
+// Files whose content didn't change are left alone, and generated tests
+// keep their place after the hand-written ones.
+func writeStoredTests(dir string, tests []storedTest) error {
+	keep := make(map[string]bool)
+	for _, tc := range tests {
+		keep[tc.Name] = true
+	}
+	for _, name := range listTestNames(dir) {
+		if testGroupOf(name) == "" && !keep[name] {
+			os.Remove(filepath.Join(dir, name+".in"))
+			os.Remove(filepath.Join(dir, name+".out"))
+		}
+	}
+
+	entries := make([]testMetaEntry, 0, len(tests))
+	for _, tc := range tests {
+		for ext, content := range map[string]string{".in": tc.Input, ".out": tc.Output} {
+			file := filepath.Join(dir, tc.Name+ext)
+			if old, err := os.ReadFile(file); err == nil && string(old) == content {
+				continue
+			}
+			if err := writeTestFile(file, strings.NewReader(content)); err != nil {
+				return fmt.Errorf("failed to write %s%s: %v", tc.Name, ext, err)
+			}
+			if ext == ".out" {
+				// An output written by hand is no longer a placeholder
+				tc.Meta.Pending = false
+			}
+		}
+		entries = append(entries, testMetaEntry{Test: tc.Name, TestMeta: tc.Meta})
+	}
+	for _, e := range readTestMeta(dir) {
+		if testGroupOf(e.Test) != "" {
+			entries = append(entries, e)
+		}
+	}
+	return writeTestMeta(dir, entries)
+}
+
+// orderTestNames sorts test names by their position in tests.json
+func orderTestNames(dir string, names []string) []string {
+	entries := readTestMeta(dir)
+	if len(entries) == 0 {
+		return names
+	}
+	return orderByEntries(names, entries)
+}
+
+// orderByEntries orders names like entries; names without an entry follow
+func orderByEntries(names []string, entries []testMetaEntry) []string {
+	pos := make(map[string]int, len(entries))
+	for i, e := range entries {
+		if _, dup := pos[e.Test]; !dup {
+			pos[e.Test] = i
+		}
+	}
+	ordered := append([]string{}, names...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		pi, iok := pos[ordered[i]]
+		pj, jok := pos[ordered[j]]
+		if iok != jok {
+			return iok
+		}
+		return iok && pi < pj
+	})
+	return ordered
+}
+
+// testTimeLimits returns the per-test time limits of a test directory
+func testTimeLimits(dir string) map[string]int {
+	limits := make(map[string]int)
+	for _, e := range readTestMeta(dir) {
+		if e.TimeLimitMs > 0 {
+			limits[e.Test] = e.TimeLimitMs
+		}
+	}
+	return limits
+}
+
+// sampleTests returns the tests flagged as samples that aren't already
+// among the problem's examples, for display with the statement
+func sampleTests(p Problem) []Example {
+	dir := findPublicTestDir(p.ID)
+	if dir == "" {
+		return nil
+	}
+	metas := testMetaByName(dir)
+	var samples []Example
+	for _, name := range listTestNames(dir) {
+		meta := metas[name]
+		if !meta.Sample {
+			continue
+		}
+		in, err1 := os.ReadFile(filepath.Join(dir, name+".in"))
+		out, err2 := os.ReadFile(filepath.Join(dir, name+".out"))
+		if err1 != nil || err2 != nil || len(in) > maxExampleBytes || len(out) > maxExampleBytes {
+			continue
+		}
+		isExample := false
+		for _, ex := range p.Examples {
+			if sameTestData(string(in), ex.Input) && sameTestData(string(out), ex.Output) {
+				isExample = true
+				break
+			}
+		}
+		if !isExample {
+			samples = append(samples, Example{Input: string(in), Output: strings.TrimRight(string(out), " \t\r\n"), Explanation: meta.Description})
+		}
+	}
+	return samples
+}
+
+// isSampleTest tells whether the i-th test is a sample; without tests.json
+// the tests of the examples (the first ones) are
+func isSampleTest(metas map[string]TestMeta, name string, i int, p Problem) bool {
+	if len(metas) == 0 {
+		return i < sampleCount(p)
+	}
+	return metas[name].Sample
+}
+
+// exampleTestName suggests "Example N" as the title of the test of the
+// N-th example
+func exampleTestName(i int) string {
+	return "Example " + strconv.Itoa(i+1)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The compilers and interpreters the runners use are probed at startup and
+// on demand. GET /api/languages reports which are installed, their versions
+// and the flags the runners pass, so the frontend only offers languages
+// that can run.
+
+const toolchainProbeTimeout = 10 * time.Second
+
+// toolchainSpec describes how a language is run and how to ask its tool
+// for a version
+type toolchainSpec struct {
+	Language    string
+	Name        string
+	Command     string         // tool the runner invokes
+	VersionArgs []string       // arguments that make it print its version
+	VersionRe   *regexp.Regexp // first group is the version; versionRe when nil
+	Requires    []string       // other tools the runner needs
+	Flags       []string       // flags the runner passes by default
+}
+
+// Toolchain is what discovery found for one language
+type Toolchain struct {
+	Language   string                `json:"language"`
+	Name       string                `json:"name"`
+	Available  bool                  `json:"available"`
+	Version    string                `json:"version,omitempty"`
+	Command    string                `json:"command"`
+	Path       string                `json:"path,omitempty"`
+	Flags      []string              `json:"flags"`    // passed by the runner whatever the profile
+	Profiles   map[string]BuildFlags `json:"profiles"` // flags of each build profile
+	Extensions []string              `json:"extensions"`
+	EntryPoint string                `json:"entryPoint,omitempty"` // conventional file name, see findEntryPoint
+	Error      string                `json:"error,omitempty"`      // why the language isn't available
+}
+
+var versionRe = regexp.MustCompile(`(\d+\.\d+(?:\.\d+)?)`)
+
+// toolchainSpecs lists the languages runLanguage supports, in the order
+// they are reported
+var toolchainSpecs = []toolchainSpec{
+	{Language: "python", Name: "Python", VersionArgs: []string{"--version"}},
+	{Language: "javascript", Name: "JavaScript (Node.js)", Command: "node", VersionArgs: []string{"--version"}},
+	{Language: "typescript", Name: "TypeScript", Command: "tsc", VersionArgs: []string{"--version"}, Requires: []string{"node"}},
+	{Language: "java", Name: "Java", Command: "javac", VersionArgs: []string{"-version"}, Requires: []string{"java"},
+		Flags: []string{"-d", ".classes"}},
+	{Language: "cpp", Name: "C++", Command: "g++", VersionArgs: []string{"--version"}, Flags: []string{"-I", "."},
+		VersionRe: regexp.MustCompile(`\)\s+(\d+\.\d+(?:\.\d+)?)|version\s+(\d+\.\d+(?:\.\d+)?)`)},
+	{Language: "c", Name: "C", Command: "gcc", VersionArgs: []string{"--version"}, Flags: []string{"-I", "."},
+		VersionRe: regexp.MustCompile(`\)\s+(\d+\.\d+(?:\.\d+)?)|version\s+(\d+\.\d+(?:\.\d+)?)`)},
+	{Language: "go", Name: "Go", Command: "go", VersionArgs: []string{"version"},
+		VersionRe: regexp.MustCompile(`go(\d+\.\d+(?:\.\d+)?)`)},
+	{Language: "rust", Name: "Rust", Command: "rustc", VersionArgs: []string{"--version"}},
+	{Language: "swift", Name: "Swift", Command: "swift", VersionArgs: []string{"--version"}, Requires: []string{"swiftc"},
+		VersionRe: regexp.MustCompile(`Swift version (\d+\.\d+(?:\.\d+)?)`)},
+	{Language: "ruby", Name: "Ruby", Command: "ruby", VersionArgs: []string{"--version"}},
+	{Language: "bash", Name: "Bash", Command: "bash", VersionArgs: []string{"--version"}},
+}
+
+var (
+	toolchainsMu sync.Mutex
+	toolchains   map[string]Toolchain // by language; nil until discovered
+	toolchainsAt time.Time            // when toolchains were discovered
+)
+
+// probeToolchain looks up the tools of a language and asks for the version
+func probeToolchain(spec toolchainSpec) Toolchain {
+	command := spec.Command
+	args := spec.VersionArgs
+	if spec.Language == "python" {
+		fields := strings.Fields(findPythonCommand())
+		command, args = fields[0], append(fields[1:], args...)
+	}
+	tc := Toolchain{
+		Language:   spec.Language,
+		Name:       spec.Name,
+		Command:    command,
+		Flags:      append([]string{}, spec.Flags...),
+		Profiles:   map[string]BuildFlags{},
+		Extensions: sourceExtensions[spec.Language],
+		EntryPoint: programFileNames[spec.Language],
+	}
+	for name, byLanguage := range buildProfiles {
+		tc.Profiles[name] = byLanguage[spec.Language]
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		tc.Error = command + " is not installed"
+		return tc
+	}
+	tc.Path = path
+	for _, tool := range spec.Requires {
+		if _, err := exec.LookPath(tool); err != nil {
+			tc.Error = tool + " is not installed"
+			return tc
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), toolchainProbeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		tc.Error = fmt.Sprintf("%s %s failed: %v", command, strings.Join(args, " "), err)
+		return tc
+	}
+	re := spec.VersionRe
+	if re == nil {
+		re = versionRe
+	}
+	if m := re.FindStringSubmatch(string(out)); m != nil {
+		for _, g := range m[1:] {
+			if g != "" {
+				tc.Version = g
+				break
+			}
+		}
+	}
+	tc.Available = true
+	return tc
+}
+
+// discoverToolchains probes every language's toolchain and records the
+// results
+func discoverToolchains() map[string]Toolchain {
+	found := make(map[string]Toolchain, len(toolchainSpecs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, spec := range toolchainSpecs {
+		wg.Add(1)
+		go func(spec toolchainSpec) {
+			defer wg.Done()
+			tc := probeToolchain(spec)
+			mu.Lock()
+			found[spec.Language] = tc
+			mu.Unlock()
+		}(spec)
+	}
+	wg.Wait()
+
+	for _, spec := range toolchainSpecs {
+		if tc := found[spec.Language]; tc.Available {
+			log.Printf("Toolchain %s: %s %s (%s)", tc.Language, tc.Command, tc.Version, tc.Path)
+		} else {
+			log.Printf("Toolchain %s: unavailable, %s", tc.Language, tc.Error)
+		}
+	}
+	toolchainsMu.Lock()
+	toolchains, toolchainsAt = found, time.Now()
+	toolchainsMu.Unlock()
+	return found
+}
+
+// currentToolchains returns the discovered toolchains, discovering them
+// first if that hasn't happened yet
+func currentToolchains() (map[string]Toolchain, time.Time) {
+	toolchainsMu.Lock()
+	found, at := toolchains, toolchainsAt
+	toolchainsMu.Unlock()
+	if found == nil {
+		found = discoverToolchains()
+		toolchainsMu.Lock()
+		at = toolchainsAt
+		toolchainsMu.Unlock()
+	}
+	return found, at
+}
+
+// languageAvailable reports whether language's toolchain is installed.
+// Languages without a toolchain spec, like sql, need nothing installed and
+// are always available
+func languageAvailable(language string) bool {
+	if language == "sh" {
+		language = "bash"
+	}
+	found, _ := currentToolchains()
+	t, ok := found[language]
+	return !ok || t.Available
+}
+
+// installedLanguages returns the languages whose toolchain is installed,
+// keeping their order
+func installedLanguages(languages []string) []string {
+	installed := []string{}
+	for _, lang := range languages {
+		if languageAvailable(lang) {
+			installed = append(installed, lang)
+		}
+	}
+	return installed
+}
+
+// languagesResponse lists the toolchains in toolchainSpecs order, and the
+// third-party packages Go programs may import
+func languagesResponse(found map[string]Toolchain, at time.Time) map[string]interface{} {
+	list := make([]Toolchain, 0, len(toolchainSpecs))
+	for _, spec := range toolchainSpecs {
+		list = append(list, found[spec.Language])
+	}
+	allow := currentGoAllowlist()
+	return map[string]interface{}{
+		"languages":      list,
+		"defaultProfile": defaultBuildProfile,
+		"discoveredAt":   at.UTC().Format(time.RFC3339),
+		"goModules":      append([]string{}, allow.Modules...),
+		"goPackages":     allow.packageList(),
+	}
+}
+
+// handleLanguageRoutes serves the toolchain endpoints:
+//
+//	GET  /api/languages          installed languages, versions and flags
+//	POST /api/languages/refresh  probe the toolchains again
+func handleLanguageRoutes(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/api/languages":
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		found, at := currentToolchains()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(languagesResponse(found, at))
+	case "/api/languages/refresh":
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", 405)
+			return
+		}
+		found := discoverToolchains()
+		_, at := currentToolchains()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(languagesResponse(found, at))
+	default:
+		http.Error(w, "not found", 404)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Deleted problems are moved to a trash area inside dataDir instead of being
+// removed right away:
+//
+//	.trash/{trashId}/trash.json   TrashEntry (problem ID, deletion and expiry time)
+//	.trash/{trashId}/problem/...  the problem directory as it was
+//
+// Entries can be restored until they expire after TRASH_RETENTION_DAYS,
+// after which they are purged automatically.
+
+const (
+	trashDirName       = ".trash"
+	trashEntryFile     = "trash.json"
+	trashProblemDir    = "problem"
+	clearTokenTTL      = 5 * time.Minute
+	trashPurgeInterval = time.Hour
+)
+
+// TrashEntry describes one deleted problem
+type TrashEntry struct {
+	TrashID   string    `json:"trashId"`
+	ProblemID string    `json:"problemId"`
+	Title     string    `json:"title,omitempty"`
+	Reason    string    `json:"reason,omitempty"` // "deleted", "cleared", "cleaned", "overwritten by import"
+	DeletedAt time.Time `json:"deletedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+var (
+	errTrashNotFound = errors.New("trash entry not found")
+	errProblemExists = errors.New("a problem with this ID already exists")
+
+	// trashMu serializes moves into and out of the trash
+	trashMu sync.Mutex
+)
+
+func trashRoot() string {
+	return filepath.Join(dataDir, trashDirName)
+}
+
+// moveToTrash moves a problem directory into the trash
+func moveToTrash(problemID, reason string) (TrashEntry, error) {
+	trashMu.Lock()
+	defer trashMu.Unlock()
+
+	src := filepath.Join(dataDir, problemID)
+	if _, err := os.Stat(src); err != nil {
+		return TrashEntry{}, err
+	}
+
+	now := time.Now().UTC()
+	entry := TrashEntry{
+		TrashID:   uuid.NewString(),
+		ProblemID: problemID,
+		Title:     loadProblem(problemID).Title,
+		Reason:    reason,
+		DeletedAt: now,
+		ExpiresAt: now.AddDate(0, 0, config.TrashRetentionDays),
+	}
+
+	entryDir := filepath.Join(trashRoot(), entry.TrashID)
+	if err := os.MkdirAll(entryDir, 0755); err != nil {
+		return TrashEntry{}, err
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	if err := os.WriteFile(filepath.Join(entryDir, trashEntryFile), data, 0644); err != nil {
+		os.RemoveAll(entryDir)
+		return TrashEntry{}, err
+	}
+	if err := os.Rename(src, filepath.Join(entryDir, trashProblemDir)); err != nil {
+		os.RemoveAll(entryDir)
+		return TrashEntry{}, err
+	}
+	problemCache.invalidate(problemID)
+
+	log.Printf("Moved problem %s to trash as %s (%s)", problemID, entry.TrashID, reason)
+	return entry, nil
+}
+
+func readTrashEntry(trashID string) (TrashEntry, error) {
+	if !isValidProblemID(trashID) {
+		return TrashEntry{}, errTrashNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(trashRoot(), trashID, trashEntryFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return TrashEntry{}, errTrashNotFound
+		}
+		return TrashEntry{}, err
+	}
+	var entry TrashEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return TrashEntry{}, fmt.Errorf("invalid trash entry %s: %v", trashID, err)
+	}
+	return entry, nil
+}
+
+// listTrash returns all trash entries, most recently deleted first
+func listTrash() ([]TrashEntry, error) {
+	ents, err := os.ReadDir(trashRoot())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []TrashEntry{}, nil
+		}
+		return nil, err
+	}
+	entries := make([]TrashEntry, 0, len(ents))
+	for _, e := range ents {
+		if !e.IsDir() {
+			continue
+		}
+		entry, err := readTrashEntry(e.Name())
+		if err != nil {
+			log.Printf("Skipping trash entry %s: %v", e.Name(), err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].DeletedAt.After(entries[j].DeletedAt) })
+	return entries, nil
+}
+
+// restoreFromTrash moves a trashed problem back into dataDir. With
+// onConflict "rename" a problem whose ID was taken in the meantime is
+// restored under the next free ID; otherwise errProblemExists is returned.
+func restoreFromTrash(trashID, onConflict string) (string, error) {
+	trashMu.Lock()
+	defer trashMu.Unlock()
+
+	entry, err := readTrashEntry(trashID)
+	if err != nil {
+		return "", err
+	}
+	entryDir := filepath.Join(trashRoot(), trashID)
+	problemDir := filepath.Join(entryDir, trashProblemDir)
+
+	id := entry.ProblemID
+	if problemExists(id) {
+		if onConflict != "rename" {
+			return "", errProblemExists
+		}
+		id = nextFreeProblemID(id)
+		if err := rewriteManifestID(filepath.Join(problemDir, "manifest.json"), id); err != nil {
+			return "", fmt.Errorf("failed to rename %s: %v", entry.ProblemID, err)
+		}
+	}
+
+	if err := os.Rename(problemDir, filepath.Join(dataDir, id)); err != nil {
+		return "", err
+	}
+	os.RemoveAll(entryDir)
+	problemCache.invalidate(id)
+
+	log.Printf("Restored problem %s from trash %s as %s", entry.ProblemID, trashID, id)
+	return id, nil
+}
+
+// purgeTrashEntry permanently deletes one trash entry
+func purgeTrashEntry(trashID string) error {
+	trashMu.Lock()
+	defer trashMu.Unlock()
+
+	if _, err := readTrashEntry(trashID); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(trashRoot(), trashID))
+}
+
+// purgeTrash permanently deletes expired trash entries, or all of them
+func purgeTrash(all bool) (int, error) {
+	entries, err := listTrash()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	purged := 0
+	for _, entry := range entries {
+		if !all && now.Before(entry.ExpiresAt) {
+			continue
+		}
+		if err := purgeTrashEntry(entry.TrashID); err != nil {
+			log.Printf("Failed to purge trash entry %s: %v", entry.TrashID, err)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		log.Printf("Purged %d problems from trash", purged)
+	}
+	return purged, nil
+}
+
+// purgeExpiredTrashLoop enforces the retention period in the background
+func purgeExpiredTrashLoop() {
+	for {
+		purged, err := purgeTrash(false)
+		if err != nil {
+			log.Printf("Trash purge failed: %v", err)
+		} else if purged > 0 {
+			recordAudit(nil, "trash.purge", "", nil, nil, map[string]interface{}{"expired": true, "count": purged})
+		}
+		time.Sleep(trashPurgeInterval)
+	}
+}
+
+// handleTrashRoutes serves /api/problems/trash and /api/problems/trash/...
+//
+//	GET    /api/problems/trash                     list trashed problems
+//	POST   /api/problems/trash/purge[?all=true]    purge expired (or all) entries
+//	POST   /api/problems/trash/{trashId}/restore   restore a problem
+//	DELETE /api/problems/trash/{trashId}           purge one entry
+func handleTrashRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/problems/trash"), "/")
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+	if len(parts) > 0 {
+		if decoded, err := url.QueryUnescape(parts[0]); err == nil {
+			parts[0] = decoded
+		}
+	}
+
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		entries, err := listTrash()
+		if err != nil {
+			log.Printf("Error listing trash: %v", err)
+			http.Error(w, "Internal server error", 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":        "success",
+			"retentionDays": config.TrashRetentionDays,
+			"entries":       entries,
+		})
+
+	case len(parts) == 1 && parts[0] == "purge" && r.Method == http.MethodPost:
+		all := r.URL.Query().Get("all") == "true"
+		purged, err := purgeTrash(all)
+		if err != nil {
+			log.Printf("Error purging trash: %v", err)
+			http.Error(w, "Internal server error", 500)
+			return
+		}
+		recordAudit(r, "trash.purge", "", nil, nil, map[string]interface{}{"all": all, "count": purged})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "success",
+			"message": fmt.Sprintf("Purged %d problems", purged),
+			"count":   purged,
+		})
+
+	case len(parts) == 2 && parts[1] == "restore" && r.Method == http.MethodPost:
+		entry, _ := readTrashEntry(parts[0])
+		id, err := restoreFromTrash(parts[0], r.URL.Query().Get("onConflict"))
+		switch {
+		case errors.Is(err, errTrashNotFound):
+			http.Error(w, "Trash entry not found", http.StatusNotFound)
+			return
+		case errors.Is(err, errProblemExists):
+			http.Error(w, "A problem with this ID already exists; use onConflict=rename", http.StatusConflict)
+			return
+		case err != nil:
+			log.Printf("Error restoring %s: %v", parts[0], err)
+			http.Error(w, "Failed to restore problem", 500)
+			return
+		}
+		recordAudit(r, "problem.restore", id, nil, loadProblem(id), map[string]interface{}{"trashId": entry.TrashID, "restoredFrom": entry.ProblemID})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success", "id": id})
+
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		entry, _ := readTrashEntry(parts[0])
+		if err := purgeTrashEntry(parts[0]); err != nil {
+			if errors.Is(err, errTrashNotFound) {
+				http.Error(w, "Trash entry not found", http.StatusNotFound)
+				return
+			}
+			log.Printf("Error purging %s: %v", parts[0], err)
+			http.Error(w, "Failed to purge problem", 500)
+			return
+		}
+		recordAudit(r, "trash.purge", entry.ProblemID, nil, nil, map[string]interface{}{"trashId": entry.TrashID})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// Confirmation tokens for clearAllProblems. A token is handed out by a first
+// POST without one and is valid once, for a few minutes.
+var (
+	clearTokensMu sync.Mutex
+	clearTokens   = make(map[string]time.Time)
+)
+
+func issueClearToken() (string, time.Time) {
+	clearTokensMu.Lock()
+	defer clearTokensMu.Unlock()
+
+	now := time.Now()
+	for token, expires := range clearTokens {
+		if now.After(expires) {
+			delete(clearTokens, token)
+		}
+	}
+	token := uuid.NewString()
+	expires := now.Add(clearTokenTTL)
+	clearTokens[token] = expires
+	return token, expires
+}
+
+// consumeClearToken reports whether token is valid and invalidates it
+func consumeClearToken(token string) bool {
+	clearTokensMu.Lock()
+	defer clearTokensMu.Unlock()
+
+	expires, ok := clearTokens[token]
+	if !ok {
+		return false
+	}
+	delete(clearTokens, token)
+	return time.Now().Before(expires)
+}
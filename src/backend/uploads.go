@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files uploaded for a problem (datasets for ML and data problems) live in
+// {dataDir}/{id}/uploads and are served from /api/problem/{id}/files/{name}.
+// When a program runs for a problem they are mounted read-only in its
+// working directory as data/, whose absolute path is also exported as
+// CEESARCODE_DATA_DIR.
+
+const (
+	uploadsDirName  = "uploads"
+	uploadsMountDir = "data"
+	dataDirEnvVar   = "CEESARCODE_DATA_DIR"
+)
+
+// uploadsDir is where the uploads of a problem are stored
+func uploadsDir(problemID string) string {
+	return filepath.Join(dataDir, problemID, uploadsDirName)
+}
+
+// isValidUploadName rejects names that would leave the uploads directory
+// or hide the file
+func isValidUploadName(name string) bool {
+	return name != "" && len(name) <= 255 && !strings.HasPrefix(name, ".") &&
+		!strings.ContainsAny(name, "/\\\x00") && filepath.Base(name) == name
+}
+
+// detectFileType returns the content type of an uploaded file. The
+// extension decides unless the content contradicts it, e.g. a .csv that is
+// really a binary file or an .xlsx that isn't a zip archive.
+func detectFileType(path string) string {
+	byExt := getFileType(filepath.Base(path))
+	f, err := os.Open(path)
+	if err != nil {
+		return byExt
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	if n == 0 {
+		return byExt
+	}
+	sniffed := http.DetectContentType(head[:n])
+	sniffedText := strings.HasPrefix(sniffed, "text/") || strings.HasPrefix(sniffed, "application/json")
+
+	switch {
+	case byExt == "application/octet-stream":
+		return strings.TrimSuffix(sniffed, "; charset=utf-8")
+	case byExt == "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		if sniffed != "application/zip" {
+			return sniffed
+		}
+	case byExt == "application/vnd.ms-excel":
+		// legacy Excel files are OLE2 containers, which aren't sniffed
+		if sniffedText {
+			return sniffed
+		}
+	case !sniffedText:
+		// text by name, binary by content
+		return sniffed
+	}
+	return byExt
+}
+
+// uploadsUsage returns the total size of a problem's uploads
+func uploadsUsage(problemID string) int64 {
+	entries, err := os.ReadDir(uploadsDir(problemID))
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && !e.IsDir() {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// checkUploadQuota rejects an upload of size bytes replacing the file name
+// when it is too large or would take the problem over its quota
+func checkUploadQuota(problemID, name string, size int64) error {
+	maxFile := int64(config.MaxUploadFileMB) << 20
+	if maxFile > 0 && size > maxFile {
+		return fmt.Errorf("file is larger than the %d MB limit", config.MaxUploadFileMB)
+	}
+	quota := int64(config.UploadQuotaMB) << 20
+	if quota <= 0 {
+		return nil
+	}
+	used := uploadsUsage(problemID)
+	if info, err := os.Stat(filepath.Join(uploadsDir(problemID), name)); err == nil {
+		used -= info.Size() // replaced by the upload
+	}
+	if used+size > quota {
+		return fmt.Errorf("upload would exceed the problem's %d MB quota (%d MB used)", config.UploadQuotaMB, used>>20)
+	}
+	return nil
+}
+
+// serveUploadedFile handles GET /api/problem/{id}/files/{name}
+func serveUploadedFile(w http.ResponseWriter, r *http.Request, problemID, name string) {
+	if !isValidProblemID(problemID) || !isValidUploadName(name) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	path := filepath.Join(uploadsDir(problemID), name)
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", detectFileType(path))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	disposition := "attachment"
+	if r.URL.Query().Get("inline") != "" {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, name))
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// uploadURL is where an uploaded file can be downloaded
+func uploadURL(problemID, name string) string {
+	return "/api/problem/" + url.PathEscape(problemID) + "/files/" + url.PathEscape(name)
+}
+
+// mountUploads makes the uploads of a problem available read-only in
+// dir/data and returns that directory's absolute path, or "" when the
+// problem has no uploads. The files are copied rather than linked: the
+// program owns its working directory and could otherwise make a link
+// writable and change the stored dataset.
+func mountUploads(problemID, dir string) (string, error) {
+	src := uploadsDir(problemID)
+	entries, err := os.ReadDir(src)
+	if err != nil || len(entries) == 0 {
+		return "", nil
+	}
+	mount, err := filepath.Abs(filepath.Join(dir, uploadsMountDir))
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(mount); err == nil {
+		return mount, nil // mounted by an earlier run in the same directory
+	}
+	if err := os.MkdirAll(mount, 0755); err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !isValidUploadName(e.Name()) {
+			continue
+		}
+		from, to := filepath.Join(src, e.Name()), filepath.Join(mount, e.Name())
+		if err := copyFile(from, to); err != nil {
+			return "", fmt.Errorf("failed to mount %s: %v", e.Name(), err)
+		}
+	}
+	return mount, nil
+}
+
+// copyFile copies from to a new read-only file
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0444)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// uploadsResponse lists the uploads of a problem
+func uploadsResponse(problemID string) ([]map[string]interface{}, error) {
+	entries, err := os.ReadDir(uploadsDir(problemID))
+	if err != nil {
+		return nil, err
+	}
+	files := []map[string]interface{}{}
+	for _, e := range entries {
+		if e.IsDir() || !isValidUploadName(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		file := map[string]interface{}{
+			"name": e.Name(),
+			"size": info.Size(),
+			"type": detectFileType(filepath.Join(uploadsDir(problemID), e.Name())),
+			"url":  uploadURL(problemID, e.Name()),
+			"path": uploadsMountDir + "/" + e.Name(),
+		}
+		if datasetFormat(e.Name()) != "" {
+			file["previewUrl"] = uploadURL(problemID, e.Name()) + "/preview"
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// writeUploadQuotaError responds 413 with why an upload was refused
+func writeUploadQuotaError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": err.Error()})
+}
+
+// storeUpload streams an uploaded file into the problem's uploads, read-only
+func storeUpload(problemID, name string, src io.Reader) (int64, error) {
+	dir := uploadsDir(problemID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0444)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	log.Printf("Stored upload %s of %s (%d bytes)", name, problemID, n)
+	return n, nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// A problem may carry an input validator in its manifest: a program that
+// reads one test input on stdin and exits 0 when the input is well-formed
+// and satisfies the constraints. Otherwise it exits non-zero and explains
+// why, one problem per line, preferably as "line N: message" or
+// "N:C: message" so that the error can be pinned to the input line.
+//
+// Test uploads (PUT /api/problem/{id}/testcases) and AI test generation are
+// rejected when an input fails; POST /api/problems/{id}/validate checks the
+// tests that are already stored.
+
+const maxInputErrorsPerTest = 20
+
+// Validator is the source of a problem's input validator
+type Validator struct {
+	Language string `json:"language"`
+	Source   string `json:"source"`
+}
+
+// programFileNames are the file names the language runners look for first
+var programFileNames = map[string]string{
+	"python":     "Main.py",
+	"javascript": "Main.js",
+	"typescript": "Main.ts",
+	"java":       "Main.java",
+	"cpp":        "Main.cpp",
+	"c":          "Main.c",
+	"go":         "main.go",
+	"rust":       "main.rs",
+	"swift":      "main.swift",
+	"ruby":       "main.rb",
+	"bash":       "main.sh",
+}
+
+// InputError is one reason a test input was rejected; Line and Column are
+// 1-based and 0 when the validator didn't say
+type InputError struct {
+	Test    string `json:"test"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e InputError) String() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("test %s line %d:%d: %s", e.Test, e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("test %s line %d: %s", e.Test, e.Line, e.Message)
+	}
+	return fmt.Sprintf("test %s: %s", e.Test, e.Message)
+}
+
+// InputValidationError is returned when test inputs fail validation
+type InputValidationError struct {
+	Errors []InputError
+}
+
+func (e *InputValidationError) Error() string {
+	tests := make(map[string]bool)
+	msgs := make([]string, 0, len(e.Errors))
+	for _, ie := range e.Errors {
+		tests[ie.Test] = true
+		msgs = append(msgs, ie.String())
+	}
+	return fmt.Sprintf("%d test inputs failed validation: %s", len(tests), strings.Join(msgs, "; "))
+}
+
+// checkValidator rejects validators the backend can't run
+func checkValidator(v *Validator) error {
+	if v == nil {
+		return nil
+	}
+	v.Language = strings.ToLower(strings.TrimSpace(v.Language))
+	if _, ok := programFileNames[v.Language]; !ok {
+		return fmt.Errorf("Validator.language %q is not supported", v.Language)
+	}
+	if strings.TrimSpace(v.Source) == "" {
+		return fmt.Errorf("Validator.source must not be empty")
+	}
+	return nil
+}
+
+// runProgram writes a single-file program to a scratch directory and runs
+// it once per input, calling fn with each result until fn returns false.
+// Compiled languages are built by the first run only; later inputs reuse
+// the binary. An error is returned only when the program can't be run at all.
+func runProgram(language, source string, inputs []string, opts RunOptions, fn func(i int, stdout, stderr string, err error) bool) error {
+	name, ok := programFileNames[language]
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnsupportedLanguage, language)
+	}
+	dir := filepath.Join(os.TempDir(), "ceesarcode-run", uuid.NewString())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0644); err != nil {
+		return err
+	}
+
+	for i, input := range inputs {
+		stdout, stderr, err := runLanguage(language, dir, input, opts)
+		if errors.Is(err, errBuildFailed) {
+			return fmt.Errorf("%w\n%s", err, strings.TrimSpace(stderr))
+		}
+		opts.Prebuilt = true
+		if !fn(i, stdout, stderr, err) {
+			break
+		}
+	}
+	return nil
+}
+
+// validateInputs runs the validator over the inputs; names label the
+// inputs in the returned errors
+func validateInputs(v Validator, names, inputs []string) ([]InputError, error) {
+	var errs []InputError
+	opts := runOptionsFromLimits(Problem{}.limitsFor(v.Language))
+	opts.Build, _ = Problem{}.buildFlagsFor("", v.Language)
+	err := runProgram(v.Language, v.Source, inputs, opts, func(i int, stdout, stderr string, err error) bool {
+		if err == nil {
+			return true
+		}
+		errs = append(errs, parseValidatorOutput(names[i], stdout+"\n"+stderr, err)...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("validator could not be run: %v", err)
+	}
+	return errs, nil
+}
+
+var (
+	lineColPrefixRe = regexp.MustCompile(`^(?:line\s+)?(\d+)(?::(\d+))?\s*:\s*(.*)$`)
+	lineMentionRe   = regexp.MustCompile(`(?i)\bline\s+(\d+)(?:,?\s*(?:col(?:umn)?|pos(?:ition)?)\s+(\d+))?`)
+)
+
+// parseValidatorOutput turns a failed validator run into input errors,
+// picking up "line N: msg", "N:C: msg" and messages that mention a line
+// (such as testlib's "... (stdin, line 3)")
+func parseValidatorOutput(test, output string, runErr error) []InputError {
+	var errs []InputError
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == runErr.Error() {
+			continue
+		}
+		ie := InputError{Test: test, Message: line}
+		if m := lineColPrefixRe.FindStringSubmatch(line); m != nil {
+			ie.Line, _ = strconv.Atoi(m[1])
+			ie.Column, _ = strconv.Atoi(m[2])
+			ie.Message = m[3]
+		} else if m := lineMentionRe.FindStringSubmatch(line); m != nil && !strings.HasPrefix(line, "File \"") {
+			ie.Line, _ = strconv.Atoi(m[1])
+			ie.Column, _ = strconv.Atoi(m[2])
+		}
+		errs = append(errs, ie)
+		if len(errs) == maxInputErrorsPerTest {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		errs = append(errs, InputError{Test: test, Message: "rejected by validator: " + runErr.Error()})
+	}
+	return errs
+}
+
+// validateTestCases checks the inputs of tests numbered from 01 against
+// the problem's validator; it returns an *InputValidationError when any
+// input is rejected
+func validateTestCases(p Problem, tests []TestCase) error {
+	if p.Validator == nil || len(tests) == 0 {
+		return nil
+	}
+	names := make([]string, len(tests))
+	inputs := make([]string, len(tests))
+	for i, tc := range tests {
+		names[i] = fmt.Sprintf("%02d", i+1)
+		inputs[i] = tc.Input
+	}
+	errs, err := validateInputs(*p.Validator, names, inputs)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &InputValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// writeValidationFailure responds 422 with the reasons tests were rejected
+func writeValidationFailure(w http.ResponseWriter, err error) {
+	resp := map[string]interface{}{
+		"status": "error",
+		"error":  err.Error(),
+	}
+	if ve, ok := err.(*InputValidationError); ok {
+		resp["error"] = "test inputs failed validation"
+		resp["errors"] = ve.Errors
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// validateStoredTests handles POST /api/problems/{id}/validate, which runs
+// the validator over the problem's current public tests
+func validateStoredTests(w http.ResponseWriter, r *http.Request, problemID string) {
+	if decoded, err := url.QueryUnescape(problemID); err == nil {
+		problemID = decoded
+	}
+	if !isValidProblemID(problemID) {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+	p, ok := readProblemManifest(dataDir, problemID)
+	if !ok {
+		http.Error(w, "Problem not found", http.StatusNotFound)
+		return
+	}
+	if p.Validator == nil {
+		http.Error(w, "Problem has no validator", 400)
+		return
+	}
+
+	var names, inputs []string
+	if dir := findPublicTestDir(problemID); dir != "" {
+		for _, name := range listTestNames(dir) {
+			in, err := os.ReadFile(filepath.Join(dir, name+".in"))
+			if err != nil {
+				continue
+			}
+			names = append(names, name)
+			inputs = append(inputs, string(in))
+		}
+	}
+
+	errs, err := validateInputs(*p.Validator, names, inputs)
+	if err != nil {
+		log.Printf("Validator of %s failed: %v", problemID, err)
+		writeValidationFailure(w, err)
+		return
+	}
+	if errs == nil {
+		errs = []InputError{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"valid":   len(errs) == 0,
+		"checked": len(inputs),
+		"errors":  errs,
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseValidatorOutput(t *testing.T) {
+	runErr := errors.New("exit status 1")
+	tests := []struct {
+		name   string
+		output string
+		want   []InputError
+	}{
+		{
+			name:   "line prefix",
+			output: "line 2: expected 3 integers, got 2\n",
+			want:   []InputError{{Test: "01", Line: 2, Message: "expected 3 integers, got 2"}},
+		},
+		{
+			name:   "line and column prefix",
+			output: "3:14: value 100001 out of range [1, 100000]\n",
+			want:   []InputError{{Test: "01", Line: 3, Column: 14, Message: "value 100001 out of range [1, 100000]"}},
+		},
+		{
+			name:   "testlib",
+			output: "FAIL Integer parameter [name=n] equals to 0, violates the range [1, 100000] (stdin, line 3)\n",
+			want:   []InputError{{Test: "01", Line: 3, Message: "FAIL Integer parameter [name=n] equals to 0, violates the range [1, 100000] (stdin, line 3)"}},
+		},
+		{
+			name:   "line and column mention",
+			output: "unexpected character at line 4, column 7\n",
+			want:   []InputError{{Test: "01", Line: 4, Column: 7, Message: "unexpected character at line 4, column 7"}},
+		},
+		{
+			// The line of a traceback frame is the validator's, not the input's
+			name: "python traceback",
+			output: `Traceback (most recent call last):
+  File "/tmp/validator/validator.py", line 5, in <module>
+    assert 1 <= n <= 10**5, "n out of range"
+AssertionError: n out of range
+`,
+			want: []InputError{
+				{Test: "01", Message: "Traceback (most recent call last):"},
+				{Test: "01", Message: `File "/tmp/validator/validator.py", line 5, in <module>`},
+				{Test: "01", Message: `assert 1 <= n <= 10**5, "n out of range"`},
+				{Test: "01", Message: "AssertionError: n out of range"},
+			},
+		},
+		{
+			name:   "no output",
+			output: "exit status 1\n",
+			want:   []InputError{{Test: "01", Message: "rejected by validator: exit status 1"}},
+		},
+	}
+	for _, tt := range tests {
+		got := parseValidatorOutput("01", tt.output, runErr)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: error %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParseValidatorOutputLimit(t *testing.T) {
+	output := strings.Repeat("line 1: bad\n", maxInputErrorsPerTest+5)
+	if got := parseValidatorOutput("01", output, errors.New("exit status 1")); len(got) != maxInputErrorsPerTest {
+		t.Errorf("got %d errors, want %d", len(got), maxInputErrorsPerTest)
+	}
+}
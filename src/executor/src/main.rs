@@ -1,14 +1,41 @@
 
 use anyhow::*;
 use serde::Deserialize;
+use std::collections::HashMap;
 use std::io::{Read, Write};
-use std::process::Command;
+#[cfg(unix)]
+use std::os::unix::process::CommandExt;
+use std::process::{Child, Command, Stdio};
 use std::fs;
-use std::path::Path;
-use std::time::Instant;
+use std::path::{Path, PathBuf};
+use std::thread;
+use std::time::{Duration, Instant};
 
 #[derive(Deserialize)]
-struct Job{ submission_id:String, problem_bundle:String, submission_dir:String, language:String }
+struct Job{
+    submission_id:String, problem_bundle:String, submission_dir:String, language:String,
+    // Limits from the problem manifest; 0 means no limit
+    #[serde(default)] time_limit_ms:u64,
+    #[serde(default)] memory_limit_mb:u64,
+    // Test order and per-test time limits from the problem's tests.json
+    #[serde(default)] test_order:Vec<String>,
+    #[serde(default)] test_time_limits_ms:HashMap<String,u64>,
+    // Uploaded datasets of the problem, mounted read-only in data/
+    #[serde(default)] uploads_dir:String,
+    // The project's entry point and, for compiled languages, the files built
+    // with it, relative to submission_dir
+    #[serde(default)] entry_point:String,
+    #[serde(default)] sources:Vec<String>,
+    #[serde(default)] main_class:String,
+    // Flags of the build profile, passed before the sources or the program
+    #[serde(default)] compile_flags:Vec<String>,
+    #[serde(default)] run_flags:Vec<String>,
+    // NAME=value pairs of the build profile, set when the program runs
+    #[serde(default)] env:Vec<String>,
+    // NAME=value pairs set while the program is built; for Go, the settings
+    // of the backend's hermetic builds against its module allowlist
+    #[serde(default)] build_env:Vec<String>,
+}
 
 fn main() -> Result<()> {
     let mut s=String::new(); std::io::stdin().read_to_string(&mut s)?;
@@ -76,7 +103,7 @@ fn execute_submission(job: &Job) -> Result<ExecutionResult> {
                 let entry = entry?;
                 let path = entry.path();
                 if let Some(extension) = path.extension() {
-                    if extension == "csv" || extension == "json" || extension == "txt" {
+                    if (extension == "csv" || extension == "json" || extension == "txt") && path.file_name().unwrap() != "tests.json" {
                         let file_name = path.file_name().unwrap();
                         let dest_path = Path::new(&job.submission_dir).join(file_name);
                         fs::copy(&path, &dest_path)?;
@@ -85,20 +112,24 @@ fn execute_submission(job: &Job) -> Result<ExecutionResult> {
             }
         }
 
-        // Copy uploaded files to submission directory from problem-specific uploads
-        let problem_uploads_dir = Path::new(&job.problem_bundle).join("uploads");
-        if problem_uploads_dir.exists() {
-            for entry in fs::read_dir(&problem_uploads_dir)? {
+        // Mount the problem's uploaded datasets read-only in data/
+        if !job.uploads_dir.is_empty() && Path::new(&job.uploads_dir).is_dir() {
+            let data_dir = Path::new(&job.submission_dir).join("data");
+            fs::create_dir_all(&data_dir)?;
+            for entry in fs::read_dir(&job.uploads_dir)? {
                 let entry = entry?;
                 let path = entry.path();
-                if let Some(extension) = path.extension() {
-                    if extension == "csv" || extension == "json" || extension == "txt" || extension == "xlsx" || extension == "xls" || extension == "py" || extension == "js" || extension == "java" || extension == "cpp" || extension == "sql" {
-                        let file_name = path.file_name().unwrap();
-                        let dest_path = Path::new(&job.submission_dir).join(file_name);
-                        fs::copy(&path, &dest_path)?;
-                    }
+                let file_name = entry.file_name();
+                if !path.is_file() || file_name.to_string_lossy().starts_with('.') {
+                    continue;
                 }
+                let dest_path = data_dir.join(&file_name);
+                fs::copy(&path, &dest_path)?;
+                let mut perms = fs::metadata(&dest_path)?.permissions();
+                perms.set_readonly(true);
+                fs::set_permissions(&dest_path, perms)?;
             }
+            std::env::set_var("CEESARCODE_DATA_DIR", fs::canonicalize(&data_dir)?);
         }
 
         // For other languages, try to find test cases
@@ -117,10 +148,16 @@ fn execute_submission(job: &Job) -> Result<ExecutionResult> {
         let mut test_results = Vec::new();
         let mut overall_verdict = "Accepted".to_string();
 
-        let test_files = fs::read_dir(&public_dir)?
+        let mut test_files = fs::read_dir(&public_dir)?
             .filter_map(|entry| entry.ok())
             .filter(|entry| entry.path().extension().and_then(|s| s.to_str()) == Some("in"))
             .collect::<Vec<_>>();
+        // Listed tests first, in the listed order, then the rest by name
+        test_files.sort_by_key(|entry| {
+            let name = entry.file_name().to_string_lossy().trim_end_matches(".in").to_string();
+            let pos = job.test_order.iter().position(|t| *t == name).unwrap_or(usize::MAX);
+            (pos, name)
+        });
 
         for test_entry in test_files {
             let test_name = test_entry.file_name()
@@ -145,22 +182,31 @@ fn execute_submission(job: &Job) -> Result<ExecutionResult> {
 
             let expected_output = fs::read_to_string(&output_file)?.trim().to_string();
 
-            // Run the submission
+            // Run the submission under the test's limits
+            let limits = Limits {
+                time_ms: job.test_time_limits_ms.get(&test_name).copied().unwrap_or(job.time_limit_ms),
+                memory_mb: job.memory_limit_mb,
+            };
             let test_start_time = Instant::now();
-            let run_result = run_code(&job, &input_file);
-
-            let actual_output = if run_result.is_ok() {
-                run_result.unwrap().trim().to_string()
-            } else {
-                let e = run_result.unwrap_err();
-                test_results.push(TestResult {
-                    name: test_name.clone(),
-                    status: "RE".to_string(),
-                    time_ms: test_start_time.elapsed().as_millis() as u64,
-                    message: format!("Runtime error: {}", e),
-                });
-                overall_verdict = "Rejected".to_string();
-                continue;
+            let run_result = run_code(&job, &input_file, &limits);
+
+            let actual_output = match run_result {
+                std::result::Result::Ok(output) => output.trim().to_string(),
+                std::result::Result::Err(e) => {
+                    let (status, message) = match e.downcast_ref::<LimitExceeded>() {
+                        Some(LimitExceeded::Time(ms)) => ("TLE", format!("Time limit exceeded ({} ms)", ms)),
+                        Some(LimitExceeded::Memory(mb)) => ("MLE", format!("Memory limit exceeded ({} MB)", mb)),
+                        None => ("RE", format!("Runtime error: {}", e)),
+                    };
+                    test_results.push(TestResult {
+                        name: test_name.clone(),
+                        status: status.to_string(),
+                        time_ms: test_start_time.elapsed().as_millis() as u64,
+                        message,
+                    });
+                    overall_verdict = "Rejected".to_string();
+                    continue;
+                }
             };
             let execution_time = test_start_time.elapsed().as_millis() as u64;
 
@@ -190,62 +236,221 @@ fn execute_submission(job: &Job) -> Result<ExecutionResult> {
     }
 }
 
-fn run_code(job: &Job, input_file: &Path) -> Result<String> {
+fn run_code(job: &Job, input_file: &Path, limits: &Limits) -> Result<String> {
     let input_content = fs::read_to_string(input_file)?;
 
     match job.language.as_str() {
-        "python" => run_python(&job.submission_dir, &input_content),
-        "cpp" => run_cpp(&job.submission_dir, &input_content),
-        "c" => run_c(&job.submission_dir, &input_content),
-        "java" => run_java(&job.submission_dir, &input_content),
-        "kotlin" => run_kotlin(&job.submission_dir, &input_content),
-        "scala" => run_scala(&job.submission_dir, &input_content),
-        "go" => run_go(&job.submission_dir, &input_content),
-        "rust" => run_rust(&job.submission_dir, &input_content),
-        "swift" => run_swift(&job.submission_dir, &input_content),
-        "ruby" => run_ruby(&job.submission_dir, &input_content),
-        "javascript" => run_javascript(&job.submission_dir, &input_content),
-        "typescript" => run_typescript(&job.submission_dir, &input_content),
-        "bash" | "sh" => run_bash(&job.submission_dir, &input_content),
+        "python" => run_python(job, &input_content, limits),
+        "cpp" => run_cpp(job, &input_content, limits),
+        "c" => run_c(job, &input_content, limits),
+        "java" => run_java(job, &input_content, limits),
+        "kotlin" => run_kotlin(job, &input_content, limits),
+        "scala" => run_scala(job, &input_content, limits),
+        "go" => run_go(job, &input_content, limits),
+        "rust" => run_rust(job, &input_content, limits),
+        "swift" => run_swift(job, &input_content, limits),
+        "ruby" => run_ruby(job, &input_content, limits),
+        "javascript" => run_javascript(job, &input_content, limits),
+        "typescript" => run_typescript(job, &input_content, limits),
+        "bash" | "sh" => run_bash(job, &input_content, limits),
         "sql" => run_sql(&job.submission_dir, &input_content),
         _ => Err(anyhow!("Unsupported language: {}", job.language)),
     }
 }
 
-fn run_python(submission_dir: &str, input: &str) -> Result<String> {
-    let main_py = Path::new(submission_dir).join("Main.py");
-    if !main_py.exists() {
-        return Err(anyhow!("Main.py not found"));
+// Limits a program runs under; 0 means no limit. Compilation is not limited.
+struct Limits {
+    time_ms: u64,
+    memory_mb: u64,
+}
+
+// Returned by run_limited when the program was stopped by one of its limits
+#[derive(Debug)]
+enum LimitExceeded {
+    Time(u64),
+    Memory(u64),
+}
+
+impl std::fmt::Display for LimitExceeded {
+    fn fmt(&self, f: &mut std::fmt::Formatter) -> std::fmt::Result {
+        match self {
+            LimitExceeded::Time(ms) => write!(f, "time limit exceeded ({} ms)", ms),
+            LimitExceeded::Memory(mb) => write!(f, "memory limit exceeded ({} MB)", mb),
+        }
+    }
+}
+
+impl std::error::Error for LimitExceeded {}
+
+// Messages runtimes print when an allocation fails
+const OUT_OF_MEMORY_MARKERS: &[&str] = &[
+    "std::bad_alloc",                // C++
+    "MemoryError",                   // Python
+    "OutOfMemoryError",              // Java, Kotlin, Scala
+    "out of memory",                 // Go, Swift, C
+    "memory allocation of",          // Rust
+    "failed to allocate memory",     // Ruby
+    "Cannot allocate memory",        // ENOMEM from libc
+];
+
+// confine puts the program in its own process group and caps its data
+// segment (RLIMIT_DATA) at limits.memory_mb before it execs
+#[cfg(unix)]
+fn confine(cmd: &mut Command, limits: &Limits) {
+    cmd.process_group(0);
+    if limits.memory_mb > 0 {
+        let bytes = (limits.memory_mb * 1024 * 1024) as libc::rlim_t;
+        unsafe {
+            cmd.pre_exec(move || {
+                let limit = libc::rlimit { rlim_cur: bytes, rlim_max: bytes };
+                if libc::setrlimit(libc::RLIMIT_DATA, &limit) != 0 {
+                    return Err(std::io::Error::last_os_error());
+                }
+                std::result::Result::Ok(())
+            });
+        }
     }
+}
 
-    let output = Command::new("/opt/homebrew/bin/python3")
-        .arg(&main_py)
-        .current_dir(submission_dir)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
+// Elsewhere only the time limit is enforced
+#[cfg(not(unix))]
+fn confine(_cmd: &mut Command, _limits: &Limits) {}
 
-    output.stdin.as_ref().unwrap().write_all(input.as_bytes())?;
+// kill_group kills the program's process group, with whatever it started
+#[cfg(unix)]
+fn kill_group(child: &mut Child) {
+    unsafe {
+        libc::kill(-(child.id() as libc::pid_t), libc::SIGKILL);
+    }
+}
 
-    let output = output.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
+#[cfg(not(unix))]
+fn kill_group(child: &mut Child) {
+    let _ = child.kill();
+}
+
+// run_limited runs a program with input on stdin and returns its stdout.
+// The program is confined (see confine); when limits.time_ms passes, its
+// group is killed and LimitExceeded::Time is returned. A program that fails
+// because an allocation hit the memory limit returns LimitExceeded::Memory.
+// env holds the NAME=value pairs of the build profile.
+fn run_limited(cmd: &mut Command, input: &str, limits: &Limits, env: &[String], language: &str) -> Result<String> {
+    set_env(cmd, env);
+    cmd.stdin(Stdio::piped())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped());
+    confine(cmd, limits);
+
+    let mut child = cmd.spawn().map_err(|e| anyhow!("Failed to run {}: {}", language, e))?;
+
+    // Feed stdin and drain stdout/stderr on their own threads so a program
+    // that never reads its input or fills a pipe cannot block the deadline
+    let mut stdin = child.stdin.take().unwrap();
+    let input = input.as_bytes().to_vec();
+    let writer = thread::spawn(move || {
+        let _ = stdin.write_all(&input);
+    });
+    let mut stdout = child.stdout.take().unwrap();
+    let stdout_reader = thread::spawn(move || {
+        let mut buf = Vec::new();
+        let _ = stdout.read_to_end(&mut buf);
+        buf
+    });
+    let mut stderr = child.stderr.take().unwrap();
+    let stderr_reader = thread::spawn(move || {
+        let mut buf = Vec::new();
+        let _ = stderr.read_to_end(&mut buf);
+        buf
+    });
+
+    let deadline = if limits.time_ms > 0 {
+        Some(Instant::now() + Duration::from_millis(limits.time_ms))
     } else {
-        Err(anyhow!("Python execution failed: {}", String::from_utf8_lossy(&output.stderr)))
+        None
+    };
+    let status = loop {
+        if let Some(status) = child.try_wait()? {
+            break Some(status);
+        }
+        if deadline.map_or(false, |d| Instant::now() >= d) {
+            break None;
+        }
+        thread::sleep(Duration::from_millis(5));
+    };
+    // Kill the group on timeout, and afterwards in any case so that
+    // processes the program left behind do not keep the pipes open
+    kill_group(&mut child);
+    if status.is_none() {
+        child.wait()?;
+    }
+
+    let _ = writer.join();
+    let stdout = stdout_reader.join().unwrap_or_default();
+    let stderr = String::from_utf8_lossy(&stderr_reader.join().unwrap_or_default()).to_string();
+
+    match status {
+        None => Err(LimitExceeded::Time(limits.time_ms).into()),
+        Some(status) if status.success() => Ok(String::from_utf8_lossy(&stdout).to_string()),
+        Some(_) if limits.memory_mb > 0 && OUT_OF_MEMORY_MARKERS.iter().any(|m| stderr.contains(m)) => {
+            Err(LimitExceeded::Memory(limits.memory_mb).into())
+        }
+        Some(_) => Err(anyhow!("{} execution failed: {}", language, stderr)),
     }
 }
 
-fn run_cpp(submission_dir: &str, input: &str) -> Result<String> {
-    let main_cpp = Path::new(submission_dir).join("Main.cpp");
+// Sets NAME=value pairs in cmd's environment
+fn set_env(cmd: &mut Command, env: &[String]) {
+    for pair in env {
+        if let Some((name, value)) = pair.split_once('=') {
+            cmd.env(name, value);
+        }
+    }
+}
+
+// The file a program starts from: the submission's entry point, or the
+// language's conventional file name
+fn entry_file(job: &Job, default: &str) -> PathBuf {
+    let name = if job.entry_point.is_empty() { default } else { job.entry_point.as_str() };
+    Path::new(&job.submission_dir).join(name)
+}
+
+// The files compiled together: the submission's sources, or the entry file
+fn source_files(job: &Job, default: &str) -> Vec<PathBuf> {
+    if job.sources.is_empty() {
+        return vec![entry_file(job, default)];
+    }
+    job.sources.iter().map(|s| Path::new(&job.submission_dir).join(s)).collect()
+}
+
+fn run_python(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_py = entry_file(job, "Main.py");
+    if !main_py.exists() {
+        return Err(anyhow!("{} not found", main_py.display()));
+    }
+
+    let mut cmd = Command::new("/opt/homebrew/bin/python3");
+    cmd.args(&job.run_flags)
+        .arg(&main_py)
+        .current_dir(submission_dir)
+        .env("PYTHONPATH", submission_dir);
+    run_limited(&mut cmd, input, limits, &job.env, "Python")
+}
+
+fn run_cpp(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_cpp = entry_file(job, "Main.cpp");
     if !main_cpp.exists() {
-        return Err(anyhow!("Main.cpp not found"));
+        return Err(anyhow!("{} not found", main_cpp.display()));
     }
 
-    // Compile
+    // Compile every source file; headers are included from the project root
     let exe_path = Path::new(submission_dir).join("main");
     let compile_output = Command::new("g++")
-        .args(&["-o", exe_path.to_str().unwrap(), main_cpp.to_str().unwrap()])
+        .args(&["-o", exe_path.to_str().unwrap(), "-I", submission_dir])
+        .args(&job.compile_flags)
+        .args(source_files(job, "Main.cpp"))
+        .current_dir(submission_dir)
         .output()?;
 
     if !compile_output.status.success() {
@@ -253,32 +458,24 @@ fn run_cpp(submission_dir: &str, input: &str) -> Result<String> {
     }
 
     // Run
-    let output = Command::new(&exe_path)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
-
-    output.stdin.as_ref().unwrap().write_all(input.as_bytes())?;
-
-    let output = output.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("C++ execution failed: {}", String::from_utf8_lossy(&output.stderr)))
-    }
+    let mut cmd = Command::new(&exe_path);
+    run_limited(&mut cmd, input, limits, &job.env, "C++")
 }
 
-fn run_c(submission_dir: &str, input: &str) -> Result<String> {
-    let main_c = Path::new(submission_dir).join("Main.cpp"); // Using .cpp for C files too for simplicity
+fn run_c(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_c = entry_file(job, "Main.c");
     if !main_c.exists() {
-        return Err(anyhow!("Main.c not found"));
+        return Err(anyhow!("{} not found", main_c.display()));
     }
 
-    // Compile
+    // Compile every source file; headers are included from the project root
     let exe_path = Path::new(submission_dir).join("main");
     let compile_output = Command::new("gcc")
-        .args(&["-o", exe_path.to_str().unwrap(), main_c.to_str().unwrap()])
+        .args(&["-o", exe_path.to_str().unwrap(), "-I", submission_dir])
+        .args(&job.compile_flags)
+        .args(source_files(job, "Main.c"))
+        .current_dir(submission_dir)
         .output()?;
 
     if !compile_output.status.success() {
@@ -286,31 +483,22 @@ fn run_c(submission_dir: &str, input: &str) -> Result<String> {
     }
 
     // Run
-    let output = Command::new(&exe_path)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
-
-    output.stdin.as_ref().unwrap().write_all(input.as_bytes())?;
-
-    let output = output.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("C execution failed: {}", String::from_utf8_lossy(&output.stderr)))
-    }
+    let mut cmd = Command::new(&exe_path);
+    run_limited(&mut cmd, input, limits, &job.env, "C")
 }
 
-fn run_java(submission_dir: &str, input: &str) -> Result<String> {
-    let main_java = Path::new(submission_dir).join("Main.java");
+fn run_java(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_java = entry_file(job, "Main.java");
     if !main_java.exists() {
-        return Err(anyhow!("Main.java not found"));
+        return Err(anyhow!("{} not found", main_java.display()));
     }
 
-    // Compile
+    // Compile the whole source tree; classes land in their package directories
     let compile_output = Command::new("javac")
-        .arg(main_java.to_str().unwrap())
+        .args(&["-d", ".classes"])
+        .args(&job.compile_flags)
+        .args(source_files(job, "Main.java"))
         .current_dir(submission_dir)
         .output()?;
 
@@ -319,54 +507,28 @@ fn run_java(submission_dir: &str, input: &str) -> Result<String> {
     }
 
     // Run
-    let output = Command::new("java")
-        .arg("Main")
-        .current_dir(submission_dir)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
-
-    output.stdin.as_ref().unwrap().write_all(input.as_bytes())?;
-
-    let output = output.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("Java execution failed: {}", String::from_utf8_lossy(&output.stderr)))
-    }
+    let main_class = if job.main_class.is_empty() { "Main" } else { job.main_class.as_str() };
+    let mut cmd = Command::new("java");
+    cmd.args(&job.run_flags)
+        .args(&["-cp", ".classes", main_class])
+        .current_dir(submission_dir);
+    run_limited(&mut cmd, input, limits, &job.env, "Java")
 }
 
-fn run_bash(submission_dir: &str, input: &str) -> Result<String> {
-    let script_sh = Path::new(submission_dir).join("script.sh");
+fn run_bash(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let script_sh = entry_file(job, "script.sh");
     if !script_sh.exists() {
-        return Err(anyhow!("script.sh not found"));
-    }
-
-    // Make the script executable
-    let chmod_output = Command::new("chmod")
-        .args(&["+x", script_sh.to_str().unwrap()])
-        .output()?;
-
-    if !chmod_output.status.success() {
-        return Err(anyhow!("Failed to make script executable: {}", String::from_utf8_lossy(&chmod_output.stderr)));
+        return Err(anyhow!("{} not found", script_sh.display()));
     }
 
-    // Run the script
-    let output = Command::new(script_sh.to_str().unwrap())
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
-
-    output.stdin.as_ref().unwrap().write_all(input.as_bytes())?;
-
-    let output = output.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("Bash execution failed: {}", String::from_utf8_lossy(&output.stderr)))
-    }
+    // Run the script through bash, so it needs neither a shebang nor the
+    // executable bit
+    let mut cmd = Command::new("bash");
+    cmd.args(&job.run_flags)
+        .arg(&script_sh)
+        .current_dir(submission_dir);
+    run_limited(&mut cmd, input, limits, &job.env, "Bash")
 }
 
 fn run_sql(submission_dir: &str, _input: &str) -> Result<String> {
@@ -387,10 +549,11 @@ fn run_sql(submission_dir: &str, _input: &str) -> Result<String> {
     }
 }
 
-fn run_kotlin(submission_dir: &str, input: &str) -> Result<String> {
-    let main_kt = Path::new(submission_dir).join("Main.kt");
+fn run_kotlin(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_kt = entry_file(job, "Main.kt");
     if !main_kt.exists() {
-        return Err(anyhow!("Main.kt not found"));
+        return Err(anyhow!("{} not found", main_kt.display()));
     }
 
     // Check if kotlinc is available
@@ -400,6 +563,7 @@ fn run_kotlin(submission_dir: &str, input: &str) -> Result<String> {
 
     // Compile
     let compile_output = Command::new("kotlinc")
+        .args(&job.compile_flags)
         .args(&[main_kt.to_str().unwrap(), "-include-runtime", "-d", "Main.jar"])
         .current_dir(submission_dir)
         .output();
@@ -414,33 +578,18 @@ fn run_kotlin(submission_dir: &str, input: &str) -> Result<String> {
     }
 
     // Run
-    let mut child = Command::new("java")
+    let mut cmd = Command::new("java");
+    cmd.args(&job.run_flags)
         .args(&["-jar", "Main.jar"])
-        .current_dir(submission_dir)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn();
-
-    let mut child = match child {
-        std::result::Result::Ok(child) => child,
-        std::result::Result::Err(e) => return Err(anyhow!("Failed to run Kotlin: {}. Please ensure Java is properly installed.", e)),
-    };
-
-    child.stdin.as_mut().unwrap().write_all(input.as_bytes())?;
-
-    let output = child.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("Kotlin execution failed: {}", String::from_utf8_lossy(&output.stderr)))
-    }
+        .current_dir(submission_dir);
+    run_limited(&mut cmd, input, limits, &job.env, "Kotlin")
 }
 
-fn run_scala(submission_dir: &str, input: &str) -> Result<String> {
-    let main_scala = Path::new(submission_dir).join("Main.scala");
+fn run_scala(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_scala = entry_file(job, "Main.scala");
     if !main_scala.exists() {
-        return Err(anyhow!("Main.scala not found"));
+        return Err(anyhow!("{} not found", main_scala.display()));
     }
 
     // Check if scala is available
@@ -448,65 +597,72 @@ fn run_scala(submission_dir: &str, input: &str) -> Result<String> {
         return Err(anyhow!("Scala runtime (scala) is not installed. Please install Scala to run Scala code."));
     }
 
-    // Run directly with Scala 3 (no separate compilation needed)
-    let mut child = Command::new("scala")
-        .args(&["run", "Main.scala"])
-        .current_dir(submission_dir)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn();
-
-    let mut child = match child {
-        std::result::Result::Ok(child) => child,
-        std::result::Result::Err(e) => return Err(anyhow!("Failed to run Scala: {}. Please ensure Scala is properly installed.", e)),
-    };
-
-    child.stdin.as_mut().unwrap().write_all(input.as_bytes())?;
-
-    let output = child.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("Scala execution failed: {}", String::from_utf8_lossy(&output.stderr)))
+    // Run directly with Scala 3 (no separate compilation needed); the
+    // profile's flags go to the compiler and the JVM
+    let mut cmd = Command::new("scala");
+    cmd.arg("run");
+    for flag in &job.compile_flags {
+        cmd.args(&["--scalac-option", flag]);
     }
+    for flag in &job.run_flags {
+        cmd.args(&["--java-opt", flag]);
+    }
+    cmd.arg(&main_scala)
+        .current_dir(submission_dir);
+    run_limited(&mut cmd, input, limits, &job.env, "Scala")
 }
 
-fn run_go(submission_dir: &str, input: &str) -> Result<String> {
-    let main_go = Path::new(submission_dir).join("main.go");
+fn run_go(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_go = entry_file(job, "main.go");
     if !main_go.exists() {
-        return Err(anyhow!("main.go not found"));
+        return Err(anyhow!("{} not found", main_go.display()));
     }
 
-    // Run directly (Go can run without explicit compilation)
-    let mut child = Command::new("go")
-        .args(&["run", "main.go"])
-        .current_dir(submission_dir)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
-
-    child.stdin.as_mut().unwrap().write_all(input.as_bytes())?;
+    // Build the entry point's package as part of the module. The backend
+    // prepares go.mod and vendor/ and sends the build's settings in
+    // build_env; a bare submission becomes a module of its own.
+    if !Path::new(submission_dir).join("go.mod").exists() {
+        let mut init = Command::new("go");
+        init.args(&["mod", "init", "solution"]).current_dir(submission_dir);
+        set_env(&mut init, &job.build_env);
+        init.output()?;
+    }
+    let package = match Path::new(&job.entry_point).parent() {
+        Some(dir) if !dir.as_os_str().is_empty() => format!("./{}", dir.display()),
+        _ => ".".to_string(),
+    };
+    let exe_path = Path::new(submission_dir).join("main");
+    let mut build = Command::new("go");
+    build.args(&["build", "-o", exe_path.to_str().unwrap()])
+        .args(&job.compile_flags)
+        .arg(&package)
+        .current_dir(submission_dir);
+    set_env(&mut build, &job.build_env);
+    let build_output = build.output()?;
 
-    let output = child.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("Go execution failed: {}", String::from_utf8_lossy(&output.stderr)))
+    if !build_output.status.success() {
+        return Err(anyhow!("Go build failed: {}", String::from_utf8_lossy(&build_output.stderr)));
     }
+
+    let mut cmd = Command::new(&exe_path);
+    cmd.current_dir(submission_dir);
+    run_limited(&mut cmd, input, limits, &job.env, "Go")
 }
 
-fn run_rust(submission_dir: &str, input: &str) -> Result<String> {
-    let main_rs = Path::new(submission_dir).join("main.rs");
+fn run_rust(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_rs = entry_file(job, "main.rs");
     if !main_rs.exists() {
-        return Err(anyhow!("main.rs not found"));
+        return Err(anyhow!("{} not found", main_rs.display()));
     }
 
-    // Compile
+    // Compile; rustc finds the crate's other files through its mod declarations
     let exe_path = Path::new(submission_dir).join("main");
     let compile_output = Command::new("rustc")
+        .args(&job.compile_flags)
         .args(&["-o", exe_path.to_str().unwrap(), main_rs.to_str().unwrap()])
+        .current_dir(submission_dir)
         .output()?;
 
     if !compile_output.status.success() {
@@ -514,99 +670,71 @@ fn run_rust(submission_dir: &str, input: &str) -> Result<String> {
     }
 
     // Run
-    let mut child = Command::new(&exe_path)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
-
-    child.stdin.as_mut().unwrap().write_all(input.as_bytes())?;
-
-    let output = child.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("Rust execution failed: {}", String::from_utf8_lossy(&output.stderr)))
-    }
+    let mut cmd = Command::new(&exe_path);
+    run_limited(&mut cmd, input, limits, &job.env, "Rust")
 }
 
-fn run_swift(submission_dir: &str, input: &str) -> Result<String> {
-    let main_swift = Path::new(submission_dir).join("main.swift");
+fn run_swift(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_swift = entry_file(job, "main.swift");
     if !main_swift.exists() {
-        return Err(anyhow!("main.swift not found"));
-    }
-
-    // Run directly (Swift can run without explicit compilation)
-    let mut child = Command::new("swift")
-        .arg(main_swift.to_str().unwrap())
-        .current_dir(submission_dir)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
-
-    child.stdin.as_mut().unwrap().write_all(input.as_bytes())?;
-
-    let output = child.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("Swift execution failed: {}", String::from_utf8_lossy(&output.stderr)))
+        return Err(anyhow!("{} not found", main_swift.display()));
+    }
+
+    // A single file runs directly; several are compiled into one module first
+    let mut cmd = Command::new("swift");
+    cmd.args(&job.compile_flags).arg(&main_swift);
+    if job.sources.len() > 1 {
+        let exe_path = Path::new(submission_dir).join("main");
+        let compile_output = Command::new("swiftc")
+            .args(&job.compile_flags)
+            .args(&["-o", exe_path.to_str().unwrap()])
+            .args(source_files(job, "main.swift"))
+            .current_dir(submission_dir)
+            .output()?;
+
+        if !compile_output.status.success() {
+            return Err(anyhow!("Swift compilation failed: {}", String::from_utf8_lossy(&compile_output.stderr)));
+        }
+        cmd = Command::new(&exe_path);
     }
+    cmd.current_dir(submission_dir);
+    run_limited(&mut cmd, input, limits, &job.env, "Swift")
 }
 
-fn run_ruby(submission_dir: &str, input: &str) -> Result<String> {
-    let main_rb = Path::new(submission_dir).join("main.rb");
+fn run_ruby(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_rb = entry_file(job, "main.rb");
     if !main_rb.exists() {
-        return Err(anyhow!("main.rb not found"));
+        return Err(anyhow!("{} not found", main_rb.display()));
     }
 
-    let mut child = Command::new("ruby")
+    let mut cmd = Command::new("ruby");
+    cmd.args(&job.run_flags)
         .arg(&main_rb)
-        .current_dir(submission_dir)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
-
-    child.stdin.as_mut().unwrap().write_all(input.as_bytes())?;
-
-    let output = child.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("Ruby execution failed: {}", String::from_utf8_lossy(&output.stderr)))
-    }
+        .current_dir(submission_dir);
+    run_limited(&mut cmd, input, limits, &job.env, "Ruby")
 }
 
-fn run_javascript(submission_dir: &str, input: &str) -> Result<String> {
-    let main_js = Path::new(submission_dir).join("main.js");
+fn run_javascript(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_js = entry_file(job, "main.js");
     if !main_js.exists() {
-        return Err(anyhow!("main.js not found"));
+        return Err(anyhow!("{} not found", main_js.display()));
     }
 
-    let mut child = Command::new("node")
+    let mut cmd = Command::new("node");
+    cmd.args(&job.run_flags)
         .arg(&main_js)
-        .current_dir(submission_dir)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
-
-    child.stdin.as_mut().unwrap().write_all(input.as_bytes())?;
-
-    let output = child.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("JavaScript execution failed: {}", String::from_utf8_lossy(&output.stderr)))
-    }
+        .current_dir(submission_dir);
+    run_limited(&mut cmd, input, limits, &job.env, "JavaScript")
 }
 
-fn run_typescript(submission_dir: &str, input: &str) -> Result<String> {
-    let main_ts = Path::new(submission_dir).join("main.ts");
+fn run_typescript(job: &Job, input: &str, limits: &Limits) -> Result<String> {
+    let submission_dir = job.submission_dir.as_str();
+    let main_ts = entry_file(job, "main.ts");
     if !main_ts.exists() {
-        return Err(anyhow!("main.ts not found"));
+        return Err(anyhow!("{} not found", main_ts.display()));
     }
 
     // Check if tsc is available
@@ -652,6 +780,7 @@ declare var __filename: string;
     // Compile TypeScript to JavaScript
     let compile_output = Command::new("tsc")
         .args(&["--allowJs", "--checkJs", "false"])
+        .args(&job.compile_flags)
         .current_dir(submission_dir)
         .output();
 
@@ -667,26 +796,15 @@ declare var __filename: string;
     }
 
     // Run the compiled JavaScript
-    let main_js = Path::new(submission_dir).join("main.js");
+    let main_js = main_ts.with_extension("js");
     if !main_js.exists() {
-        return Err(anyhow!("TypeScript compilation did not produce main.js"));
+        return Err(anyhow!("TypeScript compilation did not produce {}", main_js.display()));
     }
 
-    let mut child = Command::new("node")
+    let mut cmd = Command::new("node");
+    cmd.args(&job.run_flags)
         .arg(&main_js)
-        .current_dir(submission_dir)
-        .stdin(std::process::Stdio::piped())
-        .stdout(std::process::Stdio::piped())
-        .stderr(std::process::Stdio::piped())
-        .spawn()?;
-
-    child.stdin.as_mut().unwrap().write_all(input.as_bytes())?;
-
-    let output = child.wait_with_output()?;
-    if output.status.success() {
-        Ok(String::from_utf8_lossy(&output.stdout).to_string())
-    } else {
-        Err(anyhow!("TypeScript execution failed: {}", String::from_utf8_lossy(&output.stderr)))
-    }
+        .current_dir(submission_dir);
+    run_limited(&mut cmd, input, limits, &job.env, "TypeScript")
 }
 
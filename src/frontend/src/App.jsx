@@ -3741,11 +3741,16 @@ function AppContent() {
         })
 
         if (response.ok) {
+          const data = await response.json()
           uploadedFilesData.push({
-            name: file.name,
-            size: file.size,
-            type: file.type
+            name: data.filename,
+            size: data.size,
+            type: data.type,
+            url: data.url
           })
+        } else {
+          const data = await response.json().catch(() => ({}))
+          alert(`Failed to upload ${file.name}: ${data.error || response.statusText}`)
         }
       } catch (err) {
         console.error('Upload failed:', err)
@@ -3821,7 +3826,7 @@ function AppContent() {
 
     const fileToRemove = uploadedFiles[index]
     try {
-      const response = await fetch(`/api/problem/${selectedProblem.ID}/files/${fileToRemove.name}`, {
+      const response = await fetch(`/api/problem/${selectedProblem.ID}/files/${encodeURIComponent(fileToRemove.name)}`, {
         method: 'DELETE'
       })
 
@@ -3871,6 +3876,101 @@ function AppContent() {
     }
   }
 
+  // Python cells run in a notebook kernel on the backend, which keeps
+  // variables and imports between cells; other languages re-run the cells
+  // executed so far
+  const kernelIdRef = useRef(null)
+
+  const shutdownNotebookKernel = () => {
+    const id = kernelIdRef.current
+    kernelIdRef.current = null
+    if (id) {
+      fetch(`/api/kernels/${id}`, { method: 'DELETE' }).catch(() => {})
+    }
+  }
+
+  const ensureNotebookKernel = async () => {
+    if (kernelIdRef.current) return kernelIdRef.current
+    const response = await fetch('/api/kernels', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ problemId: selectedProblem?.ID || '' })
+    })
+    const data = await response.json().catch(() => ({}))
+    if (!response.ok) {
+      throw new Error(data.error || 'Failed to start the Python kernel')
+    }
+    kernelIdRef.current = data.id
+    return data.id
+  }
+
+  // Turns the outputs of a kernel cell into the cell's text and images
+  const formatKernelOutputs = (result) => {
+    let output = ''
+    const images = []
+    for (const out of result.outputs || []) {
+      if (out.type === 'stream') {
+        output += out.text
+      } else if (out.type === 'error') {
+        output += (out.traceback && out.traceback.length > 0 ? out.traceback.join('\n') : `${out.ename}: ${out.evalue}`) + '\n'
+      } else if (out.data) {
+        if (out.data['image/png']) {
+          images.push(out.data['image/png'])
+        } else if (out.data['text/plain']) {
+          output += out.data['text/plain'] + '\n'
+        }
+      }
+    }
+    if (result.status === 'timeout') {
+      output += result.restarted ? 'Cell timed out; the kernel was restarted and its state is gone\n' : 'Cell timed out\n'
+    }
+    if (!output && images.length === 0) {
+      output = 'Cell executed'
+    }
+    return { output, images }
+  }
+
+  const runKernelCell = async (cellCode) => {
+    const execute = async (id) => fetch(`/api/kernels/${id}/execute`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ code: cellCode })
+    })
+    let restarted = false
+    let response = await execute(await ensureNotebookKernel())
+    if (response.status === 404 || response.status === 409) {
+      // The kernel expired or died; continue in a fresh one
+      shutdownNotebookKernel()
+      restarted = true
+      response = await execute(await ensureNotebookKernel())
+    }
+    const result = await response.json().catch(() => ({}))
+    if (!response.ok) {
+      throw new Error(result.error || 'Cell execution failed')
+    }
+    const formatted = formatKernelOutputs(result)
+    if (restarted) {
+      formatted.output = '(The kernel was restarted; earlier cells have to be run again)\n' + formatted.output
+    }
+    return formatted
+  }
+
+  const restartNotebookKernel = async () => {
+    const id = kernelIdRef.current
+    if (id) {
+      const response = await fetch(`/api/kernels/${id}/restart`, { method: 'POST' }).catch(() => null)
+      if (!response || !response.ok) {
+        shutdownNotebookKernel()
+      }
+    }
+    setJupyterCells(jupyterCells.map(cell => ({ ...cell, output: '', images: [], hasExecuted: false })))
+  }
+
+  // A kernel belongs to one problem and lives while Jupyter mode is on
+  useEffect(() => {
+    return () => shutdownNotebookKernel()
+  }, [isJupyterMode, selectedProblem?.ID])
+
   // Jupyter cell functions
   const addJupyterCell = () => {
     const newCell = {
@@ -3893,13 +3993,23 @@ function AppContent() {
     if (jupyterCells.length === 0) return
 
     // Mark all cells as running
-    setJupyterCells(jupyterCells.map(cell => ({ ...cell, isRunning: true, output: '' })))
+    setJupyterCells(jupyterCells.map(cell => ({ ...cell, isRunning: true, output: '', images: [] })))
 
     for (let i = 0; i < jupyterCells.length; i++) {
       const cell = jupyterCells[i]
       if (!cell.code.trim()) continue
 
       try {
+        if (selectedLanguage === 'python') {
+          const { output, images } = await runKernelCell(cell.code)
+          setJupyterCells(prevCells =>
+            prevCells.map(c =>
+              c.id === cell.id ? { ...c, isRunning: false, output, images, hasExecuted: true } : c
+            )
+          )
+          continue
+        }
+
         // Get all previously executed cells up to this one
         const previousCells = jupyterCells.slice(0, i).filter(c => c.hasExecuted)
 
@@ -3979,6 +4089,14 @@ function AppContent() {
     ))
 
     try {
+      if (selectedLanguage === 'python') {
+        const { output, images } = await runKernelCell(cell.code)
+        setJupyterCells(prevCells => prevCells.map(c =>
+          c.id === id ? { ...c, isRunning: false, output, images, hasExecuted: true } : c
+        ))
+        return
+      }
+
       // Get all previously executed cells up to this one
       const cellIndex = jupyterCells.findIndex(c => c.id === id)
       const previousCells = jupyterCells.slice(0, cellIndex).filter(c => c.hasExecuted)
@@ -4320,7 +4438,9 @@ function AppContent() {
         setSubmittedParts({})
 
         // Update language and code based on problem
-        const firstLanguage = problem.Languages && problem.Languages[0] ? problem.Languages[0] : 'python'
+        // InstalledOnly problems list the languages that can run here separately
+        const languages = problem.AvailableLanguages || problem.Languages
+        const firstLanguage = languages && languages[0] ? languages[0] : 'python'
         setSelectedLanguage(firstLanguage)
 
         // Load stub code for the selected language
@@ -4503,7 +4623,8 @@ function AppContent() {
         body: JSON.stringify({
           language: selectedLanguage,
           files: files,
-          input: '' // Empty input for now - can be extended later
+          input: '', // Empty input for now - can be extended later
+          problemId: selectedProblem.ID // Applies the problem's time and memory limits
         })
       })
 
@@ -6029,16 +6150,27 @@ function AppContent() {
               <div style={{ display: 'flex', gap: '8px' }}>
                 <button
                   onClick={async () => {
-                    if (!window.confirm('This will remove ALL questions, including default and sample questions. Are you sure?')) {
-                      return
-                    }
-                    
                     setIsLoadingProblems(true)
                     try {
-                      const response = await fetch('/api/problems/clear', {
+                      // The first request only returns a confirmation token
+                      const tokenResponse = await fetch('/api/problems/clear', {
                         method: 'POST',
                         headers: { 'Content-Type': 'application/json' }
                       })
+                      const tokenResult = await tokenResponse.json()
+                      if (tokenResponse.status !== 428 || !tokenResult.confirmToken) {
+                        throw new Error(`HTTP ${tokenResponse.status}: ${tokenResult.message || 'Failed to request confirmation'}`)
+                      }
+
+                      if (!window.confirm(`This will move ALL ${tokenResult.count} questions, including default and sample questions, to the trash. Are you sure?`)) {
+                        return
+                      }
+
+                      const response = await fetch('/api/problems/clear', {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json' },
+                        body: JSON.stringify({ confirmToken: tokenResult.confirmToken })
+                      })
                       
                       if (!response.ok) {
                         const errorText = await response.text()
@@ -6857,6 +6989,44 @@ function AppContent() {
                     return selectedProblem.Statement
                   })()}
                 </pre>
+                {selectedPart === 0 && [...(selectedProblem.Examples || []), ...(selectedProblem.SampleTests || [])].map((example, index) => (
+                  <div key={index} style={{ marginTop: '16px' }}>
+                    <div style={{ fontWeight: 'bold', marginBottom: '6px' }}>Example {index + 1}</div>
+                    {[['Input', example.input], ['Output', example.output]].map(([label, value]) => (
+                      <div key={label} style={{ marginBottom: '6px' }}>
+                        <div style={{ fontSize: '12px', color: theme.textSecondary }}>{label}</div>
+                        <pre style={{
+                          margin: 0,
+                          padding: '8px',
+                          backgroundColor: theme.surface,
+                          border: `1px solid ${theme.border}`,
+                          borderRadius: '4px',
+                          fontSize: '13px',
+                          whiteSpace: 'pre-wrap'
+                        }}>{value}</pre>
+                      </div>
+                    ))}
+                    {example.explanation && (
+                      <div style={{ color: theme.textSecondary }}>Explanation: {example.explanation}</div>
+                    )}
+                  </div>
+                ))}
+                {selectedPart === 0 && (selectedProblem.Constraints || []).length > 0 && (
+                  <div style={{ marginTop: '16px' }}>
+                    <div style={{ fontWeight: 'bold', marginBottom: '6px' }}>Constraints</div>
+                    <ul style={{ margin: 0, paddingLeft: '20px' }}>
+                      {selectedProblem.Constraints.map((constraint, index) => (
+                        <li key={index}>
+                          {constraint.text || [
+                            constraint.min != null ? `${constraint.min} ≤ ` : '',
+                            (constraint.vars || []).join(', '),
+                            constraint.max != null ? ` ≤ ${constraint.max}` : ''
+                          ].join('')}
+                        </li>
+                      ))}
+                    </ul>
+                  </div>
+                )}
                   </div>
               </div>
 
@@ -7427,6 +7597,24 @@ function AppContent() {
                         <option value="scala">Scala</option>
                         <option value="javascript">JavaScript</option>
                       </select>
+                      {selectedLanguage === 'python' && (
+                        <button
+                          onClick={restartNotebookKernel}
+                          title="Start the Python kernel over; variables and imports are lost"
+                          style={{
+                            backgroundColor: theme.surface,
+                            color: theme.text,
+                            border: `1px solid ${theme.border}`,
+                            borderRadius: '4px',
+                            padding: '4px 10px',
+                            fontSize: '11px',
+                            cursor: 'pointer',
+                            fontWeight: '500'
+                          }}
+                        >
+                          Restart Kernel
+                        </button>
+                      )}
                       <button
                         onClick={runAllJupyterCells}
                         disabled={isRunning || jupyterCells.length === 0}
@@ -7559,7 +7747,7 @@ function AppContent() {
                             }}
                             placeholder="Enter code here..."
                           />
-                          {cell.output && (
+                          {(cell.output || (cell.images && cell.images.length > 0)) && (
                             <div style={{
                               marginTop: '8px',
                               padding: '8px',
@@ -7583,6 +7771,14 @@ function AppContent() {
                               }}>
                                 {cell.output}
                               </pre>
+                              {(cell.images || []).map((image, i) => (
+                                <img
+                                  key={i}
+                                  src={`data:image/png;base64,${image}`}
+                                  alt={`Figure ${i + 1}`}
+                                  style={{ maxWidth: '100%', marginTop: '8px', display: 'block' }}
+                                />
+                              ))}
                             </div>
                           )}
                         </div>